@@ -0,0 +1,278 @@
+// Package breaker stops calls to a failing dependency so requests fail fast instead of each waiting on it.
+//
+// A Breaker starts closed and counts the outcome of every call in a fixed window, the counts start over
+// once a window has lasted Window rather than sliding with each call. Once the share of failed or slow
+// calls in the current window passes its threshold it opens, and calls are rejected with ErrOpen
+// without being made. After the open duration it lets a few probe calls through while half-open: if
+// they all succeed it closes again, if any fails it opens for another open duration.
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var stateGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "Current state of each circuit breaker: 0 closed, 1 half-open, 2 open",
+	},
+	[]string{"name"},
+)
+var rejected = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "circuit_breaker_rejected_total",
+		Help: "Total number of calls rejected without being made because the circuit breaker was open",
+	},
+	[]string{"name"},
+)
+var transitions = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "circuit_breaker_transitions_total",
+		Help: "Total number of circuit breaker state changes by the state changed to",
+	},
+	[]string{"name", "state"},
+)
+
+// ErrOpen is returned for calls the breaker rejected
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a Breaker
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings configure when a Breaker opens and how it recovers
+type Settings struct {
+	// Window is how long outcomes are counted before the counts start over, windows are fixed and do not slide
+	Window time.Duration
+	// MinRequests is how many calls a window needs before the breaker may open
+	MinRequests int
+	// FailureRate is the percentage of failed calls in a window that opens the breaker, 0 disables it
+	FailureRate int
+	// SlowCall is how long a call may take before it counts as slow, even if it succeeds
+	SlowCall time.Duration
+	// SlowCallRate is the percentage of slow calls in a window that opens the breaker, 0 disables it
+	SlowCallRate int
+	// OpenDuration is how long the breaker rejects calls before probing the dependency again
+	OpenDuration time.Duration
+	// HalfOpenProbes is how many calls must succeed while half-open for the breaker to close
+	HalfOpenProbes int
+}
+
+// Status is a snapshot of a Breaker for status endpoints
+type Status struct {
+	State     string     `json:"state"`
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+	Requests  int        `json:"requests"`
+	Failures  int        `json:"failures"`
+	SlowCalls int        `json:"slowCalls"`
+}
+
+// Breaker is a circuit breaker for a single dependency. It is safe for concurrent use.
+type Breaker struct {
+	name     string
+	settings Settings
+
+	mu    sync.Mutex
+	state State
+	// generation changes with every state change, outcomes of calls allowed in an earlier one are ignored
+	generation  uint64
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	slowCalls   int
+	probes      int
+	successes   int
+}
+
+// New returns a closed Breaker. The name labels its metrics and should be unique.
+func New(name string, settings Settings) *Breaker {
+	settings.HalfOpenProbes = max(settings.HalfOpenProbes, 1)
+	b := &Breaker{name: name, settings: settings, windowStart: time.Now()}
+	stateGauge.WithLabelValues(name).Set(float64(StateClosed))
+	return b
+}
+
+// Allow reports whether a call may be made. Allowed calls must report their outcome by calling done
+// exactly once, with whether the call failed.
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.settings.OpenDuration {
+		b.setState(StateHalfOpen, now)
+	}
+
+	switch b.state {
+	case StateOpen:
+		rejected.WithLabelValues(b.name).Inc()
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.settings.HalfOpenProbes {
+			rejected.WithLabelValues(b.name).Inc()
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() { b.record(generation, failed, time.Since(now)) })
+	}, nil
+}
+
+// Do calls fn unless the breaker is open, any error it returns counts as a failure
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	done(err != nil)
+	return err
+}
+
+func (b *Breaker) record(generation uint64, failed bool, took time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := time.Now()
+	slow := b.settings.SlowCall > 0 && took > b.settings.SlowCall
+
+	if b.state == StateHalfOpen {
+		if failed || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenProbes {
+			b.setState(StateClosed, now)
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.settings.Window {
+		b.resetWindow(now)
+	}
+
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if slow {
+		b.slowCalls++
+	}
+
+	if b.requests >= b.settings.MinRequests && (exceeds(b.failures, b.requests, b.settings.FailureRate) || exceeds(b.slowCalls, b.requests, b.settings.SlowCallRate)) {
+		b.setState(StateOpen, now)
+	}
+}
+
+// exceeds reports whether count is at least percent of total, a percent of 0 never does
+func exceeds(count int, total int, percent int) bool {
+	return percent > 0 && count*100 >= percent*total
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	if state == StateOpen {
+		b.openedAt = now
+	}
+	if state == StateClosed {
+		b.resetWindow(now)
+	}
+
+	stateGauge.WithLabelValues(b.name).Set(float64(state))
+	transitions.WithLabelValues(b.name, state.String()).Inc()
+}
+
+func (b *Breaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+	b.slowCalls = 0
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Status returns the breaker's state and the counts of its current window
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := Status{
+		State:     b.state.String(),
+		Requests:  b.requests,
+		Failures:  b.failures,
+		SlowCalls: b.slowCalls,
+	}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// Transport wraps an http.RoundTripper so that its requests go through a Breaker, for clients that do
+// not handle responses one call at a time such as the compliance, AMS and BOP clients. Transport errors
+// and 5xx responses count as failures.
+type Transport struct {
+	Breaker *Breaker
+	// Next makes the requests, http.DefaultTransport if nil
+	Next http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.Breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	done(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
@@ -0,0 +1,229 @@
+package breaker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/breaker"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var errDependency = errors.New("dependency failed")
+
+var testSettings = breaker.Settings{
+	Window:         time.Minute,
+	MinRequests:    4,
+	FailureRate:    50,
+	SlowCall:       20 * time.Millisecond,
+	SlowCallRate:   50,
+	OpenDuration:   50 * time.Millisecond,
+	HalfOpenProbes: 2,
+}
+
+func fail() error    { return errDependency }
+func succeed() error { return nil }
+func slow() error {
+	time.Sleep(30 * time.Millisecond)
+	return nil
+}
+
+// call makes n calls with fn through the breaker
+func call(b *breaker.Breaker, n int, fn func() error) {
+	for range n {
+		b.Do(fn)
+	}
+}
+
+var _ = Describe("Breaker", func() {
+	var b *breaker.Breaker
+
+	BeforeEach(func() {
+		b = breaker.New("test", testSettings)
+	})
+
+	It("should stay closed below the failure rate", func() {
+		// when
+		call(b, 3, succeed)
+		call(b, 2, fail)
+
+		// then
+		Expect(b.State()).To(Equal(breaker.StateClosed))
+		Expect(b.Status()).To(Equal(breaker.Status{State: "closed", Requests: 5, Failures: 2}))
+	})
+
+	It("should not open before the window has the minimum number of requests", func() {
+		// when
+		call(b, 3, fail)
+
+		// then
+		Expect(b.State()).To(Equal(breaker.StateClosed))
+	})
+
+	It("should open at the failure rate and reject calls without making them", func() {
+		// given
+		call(b, 2, succeed)
+		call(b, 2, fail)
+		called := false
+
+		// when
+		err := b.Do(func() error {
+			called = true
+			return nil
+		})
+
+		// then
+		Expect(err).To(MatchError(breaker.ErrOpen))
+		Expect(called).To(BeFalse())
+		Expect(b.State()).To(Equal(breaker.StateOpen))
+		Expect(b.Status().OpenedAt).NotTo(BeNil())
+	})
+
+	It("should open at the slow call rate even when the calls succeed", func() {
+		// when
+		call(b, 2, succeed)
+		call(b, 2, slow)
+
+		// then
+		Expect(b.State()).To(Equal(breaker.StateOpen))
+	})
+
+	It("should ignore a threshold of 0", func() {
+		// given
+		b = breaker.New("test", breaker.Settings{Window: time.Minute, MinRequests: 1})
+
+		// when
+		call(b, 10, fail)
+
+		// then
+		Expect(b.State()).To(Equal(breaker.StateClosed))
+	})
+
+	It("should start counting over in a new window", func() {
+		// given
+		b = breaker.New("test", breaker.Settings{Window: 20 * time.Millisecond, MinRequests: 4, FailureRate: 50})
+		call(b, 3, fail)
+		time.Sleep(30 * time.Millisecond)
+
+		// when
+		call(b, 3, succeed)
+		call(b, 1, fail)
+
+		// then
+		Expect(b.State()).To(Equal(breaker.StateClosed))
+	})
+
+	Describe("once open", func() {
+		BeforeEach(func() {
+			call(b, 4, fail)
+			Expect(b.State()).To(Equal(breaker.StateOpen))
+		})
+
+		It("should only let the probes through after the open duration", func() {
+			// given
+			time.Sleep(testSettings.OpenDuration)
+
+			// when
+			first, firstErr := b.Allow()
+			second, secondErr := b.Allow()
+			_, thirdErr := b.Allow()
+
+			// then
+			Expect(firstErr).To(BeNil())
+			Expect(secondErr).To(BeNil())
+			Expect(thirdErr).To(MatchError(breaker.ErrOpen))
+			Expect(b.State()).To(Equal(breaker.StateHalfOpen))
+			first(false)
+			second(false)
+		})
+
+		It("should close once the probes succeed", func() {
+			// given
+			time.Sleep(testSettings.OpenDuration)
+
+			// when
+			call(b, 2, succeed)
+
+			// then
+			Expect(b.State()).To(Equal(breaker.StateClosed))
+			Expect(b.Status()).To(Equal(breaker.Status{State: "closed"}))
+		})
+
+		It("should open again when a probe fails", func() {
+			// given
+			time.Sleep(testSettings.OpenDuration)
+
+			// when
+			call(b, 1, succeed)
+			call(b, 1, fail)
+
+			// then
+			Expect(b.State()).To(Equal(breaker.StateOpen))
+			Expect(b.Do(succeed)).To(MatchError(breaker.ErrOpen))
+		})
+
+		It("should ignore the outcome of calls allowed before it opened", func() {
+			// given
+			b = breaker.New("test", testSettings)
+			done, err := b.Allow()
+			Expect(err).To(BeNil())
+			call(b, 4, fail)
+			time.Sleep(testSettings.OpenDuration)
+			Expect(b.Do(succeed)).To(Succeed())
+
+			// when
+			done(true)
+
+			// then
+			Expect(b.State()).To(Equal(breaker.StateHalfOpen))
+		})
+	})
+
+	Describe("Transport", func() {
+		var server *httptest.Server
+		var status int
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			}))
+			DeferCleanup(server.Close)
+		})
+
+		It("should count 5xx responses as failures and reject requests once open", func() {
+			// given
+			client := &http.Client{Transport: &breaker.Transport{Breaker: b}}
+			status = http.StatusServiceUnavailable
+
+			// when
+			for range 4 {
+				resp, err := client.Get(server.URL)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+			}
+			_, err := client.Get(server.URL)
+
+			// then
+			Expect(err).To(MatchError(breaker.ErrOpen))
+		})
+
+		It("should not count 4xx responses as failures", func() {
+			// given
+			client := &http.Client{Transport: &breaker.Transport{Breaker: b}}
+			status = http.StatusNotFound
+
+			// when
+			for range 4 {
+				resp, err := client.Get(server.URL)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+			}
+
+			// then
+			Expect(b.State()).To(Equal(breaker.StateClosed))
+		})
+	})
+})
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	t "github.com/RedHatInsights/entitlements-api-go/types"
+)
+
+// lintFile validates a single bundle config and writes any problems found to out.
+// It returns false if the file is invalid.
+func lintFile(path string, out io.Writer) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "%s: %s\n", path, err)
+		return false
+	}
+
+	_, err = t.ParseBundles(data)
+	if err == nil {
+		fmt.Fprintf(out, "%s: ok\n", path)
+		return true
+	}
+
+	var validationErr *t.BundleValidationError
+	if errors.As(err, &validationErr) {
+		for _, problem := range validationErr.Problems {
+			fmt.Fprintf(out, "%s: %s\n", path, problem)
+		}
+	} else {
+		fmt.Fprintf(out, "%s: %s\n", path, err)
+	}
+
+	return false
+}
+
+// lint validates every given bundle config and returns the process exit code
+func lint(paths []string, out io.Writer) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(out, "usage: bundle-lint <bundles.yml> [<bundles.yml> ...]")
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range paths {
+		if !lintFile(path, out) {
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: bundle-lint <bundles.yml> [<bundles.yml> ...]")
+		fmt.Fprintln(flag.CommandLine.Output(), "Validates bundle config files and exits non-zero if any of them is invalid.")
+	}
+	flag.Parse()
+
+	os.Exit(lint(flag.Args(), os.Stdout))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bundle Lint", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	It("should pass a valid bundle config", func() {
+		exitCode := lint([]string{"../test_data/test_bundle.yml"}, out)
+
+		Expect(exitCode).To(Equal(0))
+		Expect(out.String()).To(ContainSubstring("test_bundle.yml: ok"))
+	})
+
+	It("should report unknown keys", func() {
+		exitCode := lint([]string{"../test_data/invalid_bundle.yml"}, out)
+
+		Expect(exitCode).To(Equal(1))
+		Expect(out.String()).To(ContainSubstring("field use_valid_orgid not found"))
+	})
+
+	It("should report every problem in an invalid bundle config on its own line", func() {
+		exitCode := lint([]string{"../test_data/invalid_rules_bundle.yml"}, out)
+
+		Expect(exitCode).To(Equal(1))
+		Expect(out.String()).To(ContainSubstring("invalid_rules_bundle.yml: bundle #2 (\"TestBundle1\"): name is already used by bundle #1\n"))
+		Expect(out.String()).To(ContainSubstring("invalid_rules_bundle.yml: bundle #3 (\"TestBundle2\"): sku \"SVC456\" is listed in both eval_skus and paid_skus\n"))
+		Expect(out.String()).To(ContainSubstring("invalid_rules_bundle.yml: bundle #4 (\"TestBundle3\"): use_is_internal cannot be combined with skus, eval_skus or paid_skus\n"))
+		Expect(out.String()).To(ContainSubstring("invalid_rules_bundle.yml: bundle #5: name is required\n"))
+	})
+
+	It("should fail when any of the files is invalid", func() {
+		exitCode := lint([]string{"../test_data/test_bundle.yml", "../test_data/err_bundle.yml"}, out)
+
+		Expect(exitCode).To(Equal(1))
+		Expect(out.String()).To(ContainSubstring("test_bundle.yml: ok"))
+		Expect(out.String()).To(ContainSubstring("err_bundle.yml: invalid bundle config"))
+	})
+
+	It("should fail when the file does not exist", func() {
+		exitCode := lint([]string{"no_such_file.yml"}, out)
+
+		Expect(exitCode).To(Equal(1))
+		Expect(out.String()).To(ContainSubstring("no_such_file.yml"))
+	})
+
+	It("should print usage when no files are given", func() {
+		exitCode := lint([]string{}, out)
+
+		Expect(exitCode).To(Equal(2))
+		Expect(out.String()).To(ContainSubstring("usage"))
+	})
+})
@@ -18,7 +18,6 @@ import (
 	"github.com/RedHatInsights/entitlements-api-go/config"
 	t "github.com/RedHatInsights/entitlements-api-go/types"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 var dryRun bool
@@ -103,8 +102,7 @@ func getBundlesConfig(cfg *viper.Viper) (map[string]t.Bundle, error) {
 		return bundlesMap, err
 	}
 
-	var bundles []t.Bundle
-	err = yaml.Unmarshal(bundlesYaml, &bundles)
+	bundles, err := t.ParseBundles(bundlesYaml)
 	if err != nil {
 		return bundlesMap, err
 	}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/RedHatInsights/entitlements-api-go/config"
+	t "github.com/RedHatInsights/entitlements-api-go/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/viper"
@@ -73,6 +74,16 @@ var _ = Describe("Bundle Sync", func() {
 			Expect(bundlesMap).To(HaveKey("TestBundle1"))
 		})
 
+		It("should return error when the bundle config is invalid", func() {
+			cfg.Set(config.Keys.BundleInfoYaml, "../test_data/invalid_rules_bundle.yml")
+
+			bundlesMap, err := getBundlesConfig(cfg)
+
+			var validationErr *t.BundleValidationError
+			Expect(err).To(BeAssignableToTypeOf(validationErr))
+			Expect(bundlesMap).To(BeEmpty())
+		})
+
 		It("should return error when file does not exist", func() {
 			cfg.Set(config.Keys.BundleInfoYaml, "nonexistent_file.yml")
 
@@ -150,8 +161,8 @@ var _ = Describe("Bundle Sync", func() {
   paid_skus:
     - SKU1
     - SKU2
-  eval_skus:
     - SKU1
+  eval_skus:
     - SKU3
 `
 				tmpFile, err := os.CreateTemp("", "test_dup_*.yml")
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/go-chi/chi/v5"
+)
+
+// Paths are where the fake IT services are served, they default to the paths the API is configured with
+type Paths struct {
+	FeatureStatus string
+	Features      string
+	Trials        string
+	Screening     string
+}
+
+// NewRouter serves the Feature Service and Export Compliance Service endpoints the API and bundle_sync
+// call, answering them from the scenario
+func NewRouter(scenario *Scenario, paths Paths) http.Handler {
+	r := chi.NewRouter()
+	r.Get(paths.FeatureStatus, featureStatusHandler(scenario))
+	// trials is registered before {name} so it is not taken for a feature
+	r.Post(paths.Trials, trialHandler(scenario))
+	r.Get(paths.Features+"/{name}", getFeatureHandler(scenario))
+	r.Post(paths.Features, postFeatureHandler(scenario))
+	r.Post(paths.Features+"/{name}", postFeatureHandler(scenario))
+	r.Post(paths.Screening, screeningHandler(scenario))
+	return r
+}
+
+// fail waits for the fault's latency, then answers with the fault when it replaces the answer. It
+// returns whether the request was answered.
+func fail(w http.ResponseWriter, r *http.Request, fault Fault) bool {
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+
+	if !fault.failing() {
+		return false
+	}
+
+	status := fault.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(fault.Body))
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("writing response: %s", err)
+	}
+}
+
+func featureStatusHandler(scenario *Scenario) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		org := scenario.org(query.Get("accountId"))
+		if fail(w, r, org.Fault) {
+			return
+		}
+		writeJSON(w, http.StatusOK, featureStatus(org, query["features"]))
+	}
+}
+
+func trialHandler(scenario *Scenario) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var trial types.TrialActivationRequest
+		if err := json.NewDecoder(r.Body).Decode(&trial); err != nil || trial.AccountID == "" || trial.Feature == "" {
+			http.Error(w, "a trial needs an accountId and a feature", http.StatusBadRequest)
+			return
+		}
+		if fail(w, r, scenario.org(trial.AccountID).Fault) {
+			return
+		}
+		scenario.activateTrial(trial.AccountID, trial.Feature)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func getFeatureHandler(scenario *Scenario) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		skus, ok := scenario.skus(name)
+		if !ok {
+			http.Error(w, "feature not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.SubModel{
+			Name:  name,
+			Rules: []types.Rules{{MatchProducts: []types.MatchProducts{{SkuCodes: skus}}}},
+		})
+	}
+}
+
+// postFeatureHandler upserts a feature with the SKUs of its first rule, as bundle_sync writes them
+func postFeatureHandler(scenario *Scenario) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var feature types.SubModel
+		if err := json.NewDecoder(r.Body).Decode(&feature); err != nil || feature.Name == "" {
+			http.Error(w, "a feature needs a name", http.StatusBadRequest)
+			return
+		}
+
+		skus := []string{}
+		for _, rule := range feature.Rules {
+			for _, match := range rule.MatchProducts {
+				skus = append(skus, match.SkuCodes...)
+			}
+		}
+		scenario.setSkus(feature.Name, skus)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func screeningHandler(scenario *Scenario) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var screening types.ComplianceScreeningRequest
+		if err := json.NewDecoder(r.Body).Decode(&screening); err != nil || screening.User.Login == "" {
+			writeJSON(w, http.StatusBadRequest, types.ComplianceScreeningErrorResponse{
+				Errors: []types.ComplianceScreeningError{{Error: "a screening needs a user login", IdentityType: "login"}},
+			})
+			return
+		}
+
+		user := scenario.user(screening.User.Login)
+		if fail(w, r, user.Fault) {
+			return
+		}
+
+		result := user.Result
+		if result == "" {
+			result = "OK"
+		}
+		writeJSON(w, http.StatusOK, types.ComplianceScreeningResponse{Result: result, Description: user.Description})
+	}
+}
@@ -0,0 +1,78 @@
+// fake-it-services serves the IT Feature Service and Export Compliance Service endpoints the API and
+// bundle_sync call, answering them from a scenario file so the whole stack can run offline.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+)
+
+// serverTLSConfig requires clients to present a certificate signed by clientCA, as the IT services do
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading the server certificate: %w", err)
+	}
+
+	clientCA, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading the client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(clientCA) {
+		return nil, fmt.Errorf("no certificates found in the client CA [%s]", clientCAFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+func main() {
+	var addr, scenarioFile, certFile, keyFile, clientCAFile string
+	var useTLS bool
+	flag.StringVar(&addr, "addr", ":9443", "Address to listen on")
+	flag.StringVar(&scenarioFile, "scenario", "cmd/fake-it-services/scenario.yml", "Scenario file the answers are read from")
+	flag.BoolVar(&useTLS, "tls", false, "Include to require mutual TLS, as the IT services do")
+	flag.StringVar(&certFile, "cert", "test_data/fake_it_services.cert", "Server certificate used with -tls")
+	flag.StringVar(&keyFile, "key", "test_data/fake_it_services.key", "Server key used with -tls")
+	flag.StringVar(&clientCAFile, "client-ca", "test_data/fake_it_services.cert", "CA client certificates are verified with when using -tls")
+	flag.Parse()
+
+	scenario, err := LoadScenario(scenarioFile)
+	if err != nil {
+		log.Fatalf("Unable to load the scenario: %s", err)
+	}
+
+	options := config.GetConfig().Options
+	server := &http.Server{
+		Addr: addr,
+		Handler: NewRouter(scenario, Paths{
+			FeatureStatus: options.GetString(config.Keys.FeatureStatusAPIPath),
+			Features:      options.GetString(config.Keys.FeaturesAPIPath),
+			Trials:        options.GetString(config.Keys.FeatureTrialAPIPath),
+			Screening:     options.GetString(config.Keys.CompAPIBasePath),
+		}),
+	}
+
+	if !useTLS {
+		log.Printf("Serving fake IT services on http://%s", addr)
+		log.Fatal(server.ListenAndServe())
+	}
+
+	server.TLSConfig, err = serverTLSConfig(certFile, keyFile, clientCAFile)
+	if err != nil {
+		log.Fatalf("Unable to set up mutual TLS: %s", err)
+	}
+	log.Printf("Serving fake IT services on https://%s", addr)
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var paths = Paths{
+	FeatureStatus: "/features/v2/featureStatus",
+	Features:      "/features/v1",
+	Trials:        "/features/v1/trials",
+	Screening:     "/v1/screening",
+}
+
+const testScenario = `
+orgs:
+  "540155":
+    features:
+      - name: TestBundle1
+        startDate: "2024-01-01"
+      - name: TestBundle2
+  slow-org:
+    latency: 50ms
+  down-org:
+    status: 503
+    body: down
+users:
+  blocked-user:
+    result: ERROR_EXPORT_CONTROL
+    description: on hold
+features:
+  TestBundle1: [SKU1]
+`
+
+func serve(scenario string) *httptest.Server {
+	s, err := ParseScenario([]byte(scenario))
+	Expect(err).To(BeNil())
+	server := httptest.NewServer(NewRouter(s, paths))
+	DeferCleanup(server.Close)
+	return server
+}
+
+func readBody(resp *http.Response) string {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	Expect(err).To(BeNil())
+	return string(body)
+}
+
+var _ = Describe("Fake IT services", func() {
+	Describe("ParseScenario", func() {
+		It("should reject unknown fields", func() {
+			// when
+			_, err := ParseScenario([]byte("orgs:\n  \"540155\":\n    feature: []\n"))
+
+			// then
+			Expect(err).To(MatchError(ContainSubstring("field feature not found")))
+		})
+
+		It("should accept an empty scenario", func() {
+			// when
+			scenario, err := ParseScenario([]byte(""))
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(scenario.Orgs).To(BeEmpty())
+		})
+
+		It("should parse the example scenario", func() {
+			// when
+			_, err := LoadScenario("scenario.yml")
+
+			// then
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Describe("feature status", func() {
+		It("should answer with the org's features that were asked for", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&features=TestBundle3&accountId=540155")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var status types.FeatureStatus
+			Expect(json.Unmarshal([]byte(readBody(resp)), &status)).To(Succeed())
+			Expect(status.Features).To(Equal([]types.Feature{{Name: "TestBundle1", StartDate: "2024-01-01"}}))
+		})
+
+		It("should answer an org that is not in the scenario without features", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&accountId=1111")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(readBody(resp)).To(MatchJSON(`{"features": []}`))
+		})
+
+		It("should answer with the org's fault", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&accountId=down-org")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(readBody(resp)).To(Equal("down"))
+		})
+
+		It("should delay the answer by the org's latency", func() {
+			// given
+			server := serve(testScenario)
+			start := time.Now()
+
+			// when
+			resp, err := http.Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&accountId=slow-org")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		})
+
+		It("should give the org the feature of an activated trial", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Post(server.URL+paths.Trials, "application/json", strings.NewReader(`{"accountId": "1111", "feature": "TestBundle1"}`))
+			Expect(err).To(BeNil())
+			status, err := http.Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&accountId=1111")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(readBody(status)).To(MatchJSON(`{"features": [{"name": "TestBundle1", "startDate": "", "endDate": ""}]}`))
+		})
+	})
+
+	Describe("features", func() {
+		It("should not find a feature that is not in the scenario", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Get(server.URL + paths.Features + "/TestBundle2")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+
+		It("should answer with a feature posted by bundle_sync", func() {
+			// given
+			server := serve(testScenario)
+			feature := `{"name": "TestBundle2", "rules": [{"matchProducts": [{"skuCodes": ["SKU2", "SKU3"]}]}]}`
+
+			// when
+			post, err := http.Post(server.URL+paths.Features, "application/json", strings.NewReader(feature))
+			Expect(err).To(BeNil())
+			resp, err := http.Get(server.URL + paths.Features + "/TestBundle2")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(post.StatusCode).To(Equal(http.StatusNoContent))
+			Expect(readBody(resp)).To(MatchJSON(feature))
+		})
+	})
+
+	Describe("screening", func() {
+		It("should answer with the user's result", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Post(server.URL+paths.Screening, "application/json", strings.NewReader(`{"user": {"login": "blocked-user"}, "account": {"primary": true}}`))
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(readBody(resp)).To(MatchJSON(`{"result": "ERROR_EXPORT_CONTROL", "description": "on hold"}`))
+		})
+
+		It("should answer OK for a user that is not in the scenario", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Post(server.URL+paths.Screening, "application/json", strings.NewReader(`{"user": {"login": "someone"}, "account": {"primary": true}}`))
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(readBody(resp)).To(MatchJSON(`{"result": "OK", "description": ""}`))
+		})
+
+		It("should reject a screening without a login", func() {
+			// given
+			server := serve(testScenario)
+
+			// when
+			resp, err := http.Post(server.URL+paths.Screening, "application/json", strings.NewReader(`{"account": {"primary": true}}`))
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("mutual TLS", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			s, err := ParseScenario([]byte(testScenario))
+			Expect(err).To(BeNil())
+			tlsConfig, err := serverTLSConfig("../../test_data/fake_it_services.cert", "../../test_data/fake_it_services.key", "../../test_data/fake_it_services.cert")
+			Expect(err).To(BeNil())
+
+			server = httptest.NewUnstartedServer(NewRouter(s, paths))
+			server.TLS = tlsConfig
+			server.StartTLS()
+			DeferCleanup(server.Close)
+		})
+
+		client := func(certs ...tls.Certificate) *http.Client {
+			serverCert, err := os.ReadFile("../../test_data/fake_it_services.cert")
+			Expect(err).To(BeNil())
+			rootCAs := x509.NewCertPool()
+			Expect(rootCAs.AppendCertsFromPEM(serverCert)).To(BeTrue())
+			return &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: certs},
+			}}
+		}
+
+		It("should answer a client with the fake certificate", func() {
+			// given
+			cert, err := tls.LoadX509KeyPair("../../test_data/fake_it_services.cert", "../../test_data/fake_it_services.key")
+			Expect(err).To(BeNil())
+
+			// when
+			resp, err := client(cert).Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&accountId=540155")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("should refuse a client without a certificate", func() {
+			// when
+			_, err := client().Get(server.URL + paths.FeatureStatus + "?features=TestBundle1&accountId=540155")
+
+			// then
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Fault changes how a request is answered. The answer is delayed by Latency, and when Status or Body
+// is set the request is answered with them as is, so errors and malformed bodies can be served.
+type Fault struct {
+	Latency time.Duration `yaml:"latency"`
+	Status  int           `yaml:"status"`
+	Body    string        `yaml:"body"`
+}
+
+// failing tells whether the fault replaces the answer
+func (f Fault) failing() bool {
+	return f.Status != 0 || f.Body != ""
+}
+
+// Feature is a feature an org has, the dates are served as they are written
+type Feature struct {
+	Name      string `yaml:"name"`
+	StartDate string `yaml:"startDate"`
+	EndDate   string `yaml:"endDate"`
+}
+
+// Org is what the Feature Service answers for an org
+type Org struct {
+	Features []Feature `yaml:"features"`
+	Fault    `yaml:",inline"`
+}
+
+// User is what the Export Compliance Service answers for a user. An empty Result is answered as OK.
+type User struct {
+	Result      string `yaml:"result"`
+	Description string `yaml:"description"`
+	Fault       `yaml:",inline"`
+}
+
+// Defaults are used for the orgs and users that are not in the scenario
+type Defaults struct {
+	Org  Org  `yaml:"org"`
+	User User `yaml:"user"`
+}
+
+// Scenario drives the answers of the fake IT services. Features are the SKUs of each feature known to
+// /features/v1, as bundle_sync reads and writes them.
+type Scenario struct {
+	Orgs     map[string]Org      `yaml:"orgs"`
+	Users    map[string]User     `yaml:"users"`
+	Features map[string][]string `yaml:"features"`
+	Defaults Defaults            `yaml:"defaults"`
+
+	mu sync.Mutex
+}
+
+// LoadScenario reads a scenario file. Unknown fields are rejected so a typo does not go unnoticed.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario parses a scenario, an empty one answers every org without features and every user OK
+func ParseScenario(data []byte) (*Scenario, error) {
+	scenario := &Scenario{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(scenario); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+
+	if scenario.Orgs == nil {
+		scenario.Orgs = map[string]Org{}
+	}
+	if scenario.Users == nil {
+		scenario.Users = map[string]User{}
+	}
+	if scenario.Features == nil {
+		scenario.Features = map[string][]string{}
+	}
+	return scenario, nil
+}
+
+// org returns the org, or the default org when it is not in the scenario
+func (s *Scenario) org(orgID string) Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if org, ok := s.Orgs[orgID]; ok {
+		return org
+	}
+	return s.Defaults.Org
+}
+
+// user returns the user, or the default user when they are not in the scenario
+func (s *Scenario) user(login string) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.Users[login]; ok {
+		return user
+	}
+	return s.Defaults.User
+}
+
+// featureStatus returns the org's features that were asked for, as the Feature Service does
+func featureStatus(org Org, requested []string) types.FeatureStatus {
+	status := types.FeatureStatus{Features: []types.Feature{}}
+	for _, feature := range org.Features {
+		if slices.Contains(requested, feature.Name) {
+			status.Features = append(status.Features, types.Feature{
+				Name:      feature.Name,
+				StartDate: types.FeatureDate(feature.StartDate),
+				EndDate:   types.FeatureDate(feature.EndDate),
+			})
+		}
+	}
+	return status
+}
+
+// activateTrial gives the org the feature, unless it already has it
+func (s *Scenario) activateTrial(orgID string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org, ok := s.Orgs[orgID]
+	if !ok {
+		org = s.Defaults.Org
+		org.Features = slices.Clone(org.Features)
+	}
+	if slices.ContainsFunc(org.Features, func(feature Feature) bool { return feature.Name == name }) {
+		return
+	}
+	org.Features = append(org.Features, Feature{Name: name})
+	s.Orgs[orgID] = org
+}
+
+// skus returns the SKUs of a feature and whether it is known
+func (s *Scenario) skus(name string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skus, ok := s.Features[name]
+	return skus, ok
+}
+
+// setSkus adds or replaces a feature
+func (s *Scenario) setSkus(name string, skus []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Features[name] = skus
+}
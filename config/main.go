@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/viper"
 
@@ -37,17 +38,28 @@ type EntitlementsConfigKeysType struct {
 	CaPath                   string
 	OpenAPISpecPath          string
 	BundleInfoYaml           string
+	BundleInfoWatch          string
+	OverridesYaml            string
+	InternalPoliciesYaml     string
 	CwLogGroup               string
 	CwLogStream              string
 	CwRegion                 string
 	CwKey                    string
 	CwSecret                 string
 	Features                 string
-	FeaturesAPIPath			 string
-	FeatureStatusAPIPath	 string
+	FeaturesAPIPath          string
+	FeatureStatusAPIPath     string
+	FeatureTrialAPIPath      string
+	TrialsEnabled            string
 	CompAPIBasePath          string
 	RunBundleSync            string
 	EntitleAll               string
+	ServicesExplain          string
+	ServicesMaxAge           string
+	ServicesBatchClientIDs   string
+	ServicesBatchMaxOrgs     string
+	ServicesBatchConcurrency string
+	TrialActivatedInterval   string
 	AMSHost                  string
 	ClientID                 string
 	ClientSecret             string
@@ -62,9 +74,38 @@ type EntitlementsConfigKeysType struct {
 	SubsCacheDuration        string
 	SubsCacheMaxSize         string
 	SubsCacheItemPrune       string
+	SubsCacheStaleWindow     string
+	SubsCacheStaleRefresh    string
+	SubsCacheErrorTTL        string
+	SubsCacheNon200TTL       string
+	SubsCacheNegativeMaxTTL  string
+	SubsCacheBackend         string
+	SubsCacheRedisAddr       string
+	SubsCacheRedisPassword   string
+	SubsCacheRedisDB         string
+	SubsCacheRedisTimeoutMs  string
+	ComplianceOKTTL          string
+	ComplianceBlockedTTL     string
+	ComplianceCacheMaxSize   string
+	ComplianceCacheItemPrune string
 	AMSAcctMgmt11Msg         string
 	ITServicesTimeoutSeconds string
+	FeatureBreakerEnabled    string
+	FeatureBreakerWindow     string
+	FeatureBreakerMinCalls   string
+	FeatureBreakerFailRate   string
+	FeatureBreakerSlowCallMs string
+	FeatureBreakerSlowRate   string
+	FeatureBreakerOpenSecs   string
+	FeatureBreakerProbes     string
 	PaidFeatureSuffix        string
+	EventsKafkaBrokers       string
+	EventsKafkaCACert        string
+	EventsKafkaSASLMechanism string
+	EventsKafkaUsername      string
+	EventsKafkaPassword      string
+	EventsTopic              string
+	EventsDedupeWindow       string
 }
 
 // Keys is a struct that houses all the env variables key names
@@ -79,17 +120,28 @@ var Keys = EntitlementsConfigKeysType{
 	ComplianceHost:           "COMPLIANCE_HOST",
 	OpenAPISpecPath:          "OPENAPI_SPEC_PATH",
 	BundleInfoYaml:           "BUNDLE_INFO_YAML",
+	BundleInfoWatch:          "BUNDLE_INFO_WATCH",
+	OverridesYaml:            "OVERRIDES_YAML",
+	InternalPoliciesYaml:     "INTERNAL_POLICIES_YAML",
 	CwLogGroup:               "CW_LOG_GROUP",
 	CwLogStream:              "CW_LOG_STEAM",
 	CwRegion:                 "CW_REGION",
 	CwKey:                    "CW_KEY",
 	CwSecret:                 "CW_SECRET",
 	Features:                 "FEATURES",
-	FeaturesAPIPath:		  "FEATURES_API_PATH",
-	FeatureStatusAPIPath:	  "FEATURE_STATUS_API_PATH",
+	FeaturesAPIPath:          "FEATURES_API_PATH",
+	FeatureStatusAPIPath:     "FEATURE_STATUS_API_PATH",
+	FeatureTrialAPIPath:      "FEATURE_TRIAL_API_PATH",
+	TrialsEnabled:            "TRIALS_ENABLED",
 	CompAPIBasePath:          "COMP_API_BASE_PATH",
 	RunBundleSync:            "RUN_BUNDLE_SYNC",
 	EntitleAll:               "ENTITLE_ALL",
+	ServicesExplain:          "SERVICES_EXPLAIN",
+	ServicesMaxAge:           "SERVICES_MAX_AGE_SECONDS",
+	ServicesBatchClientIDs:   "SERVICES_BATCH_CLIENT_IDS",
+	ServicesBatchMaxOrgs:     "SERVICES_BATCH_MAX_ORGS",
+	ServicesBatchConcurrency: "SERVICES_BATCH_CONCURRENCY",
+	TrialActivatedInterval:   "TRIAL_ACTIVATED_INTERVAL_SECONDS",
 	AMSHost:                  "AMS_HOST",
 	ClientID:                 "OIDC_CLIENT_ID",
 	ClientSecret:             "OIDC_CLIENT_SECRET",
@@ -104,9 +156,38 @@ var Keys = EntitlementsConfigKeysType{
 	SubsCacheDuration:        "SUBS_CACHE_DURATION_SECONDS",
 	SubsCacheMaxSize:         "SUBS_CACHE_MAX_SIZE",
 	SubsCacheItemPrune:       "SUBS_CACHE_ITEM_PRUNE",
+	SubsCacheStaleWindow:     "SUBS_CACHE_STALE_WINDOW_SECONDS",
+	SubsCacheStaleRefresh:    "SUBS_CACHE_STALE_REFRESH_SECONDS",
+	SubsCacheErrorTTL:        "SUBS_CACHE_ERROR_TTL_SECONDS",
+	SubsCacheNon200TTL:       "SUBS_CACHE_NON_200_TTL_SECONDS",
+	SubsCacheNegativeMaxTTL:  "SUBS_CACHE_NEGATIVE_MAX_TTL_SECONDS",
+	SubsCacheBackend:         "SUBS_CACHE_BACKEND",
+	SubsCacheRedisAddr:       "SUBS_CACHE_REDIS_ADDR",
+	SubsCacheRedisPassword:   "SUBS_CACHE_REDIS_PASSWORD",
+	SubsCacheRedisDB:         "SUBS_CACHE_REDIS_DB",
+	SubsCacheRedisTimeoutMs:  "SUBS_CACHE_REDIS_TIMEOUT_MS",
+	ComplianceOKTTL:          "COMPLIANCE_CACHE_OK_TTL_SECONDS",
+	ComplianceBlockedTTL:     "COMPLIANCE_CACHE_BLOCKED_TTL_SECONDS",
+	ComplianceCacheMaxSize:   "COMPLIANCE_CACHE_MAX_SIZE",
+	ComplianceCacheItemPrune: "COMPLIANCE_CACHE_ITEM_PRUNE",
 	AMSAcctMgmt11Msg:         "AMS_ACCT_MGMT_11_ERR_MSG",
 	ITServicesTimeoutSeconds: "IT_SERVICES_TIMEOUT_SECONDS",
+	FeatureBreakerEnabled:    "FEATURE_SERVICE_BREAKER_ENABLED",
+	FeatureBreakerWindow:     "FEATURE_SERVICE_BREAKER_WINDOW_SECONDS",
+	FeatureBreakerMinCalls:   "FEATURE_SERVICE_BREAKER_MIN_CALLS",
+	FeatureBreakerFailRate:   "FEATURE_SERVICE_BREAKER_FAILURE_RATE",
+	FeatureBreakerSlowCallMs: "FEATURE_SERVICE_BREAKER_SLOW_CALL_MS",
+	FeatureBreakerSlowRate:   "FEATURE_SERVICE_BREAKER_SLOW_CALL_RATE",
+	FeatureBreakerOpenSecs:   "FEATURE_SERVICE_BREAKER_OPEN_SECONDS",
+	FeatureBreakerProbes:     "FEATURE_SERVICE_BREAKER_HALF_OPEN_PROBES",
 	PaidFeatureSuffix:        "PAID_FEATURE_SUFFIX",
+	EventsKafkaBrokers:       "EVENTS_KAFKA_BROKERS",
+	EventsKafkaCACert:        "EVENTS_KAFKA_CA_CERT",
+	EventsKafkaSASLMechanism: "EVENTS_KAFKA_SASL_MECHANISM",
+	EventsKafkaUsername:      "EVENTS_KAFKA_USERNAME",
+	EventsKafkaPassword:      "EVENTS_KAFKA_PASSWORD",
+	EventsTopic:              "EVENTS_TOPIC",
+	EventsDedupeWindow:       "EVENTS_DEDUPE_WINDOW_SECONDS",
 }
 
 func initialize() {
@@ -135,14 +216,25 @@ func initialize() {
 	options.SetDefault(Keys.Key, fmt.Sprintf("%s/../test_data/test.key", wd))
 	options.SetDefault(Keys.OpenAPISpecPath, "./apispec/api.spec.json")
 	options.SetDefault(Keys.BundleInfoYaml, "./bundles/bundles.yml")
+	options.SetDefault(Keys.BundleInfoWatch, true)
+	options.SetDefault(Keys.OverridesYaml, "./bundles/overrides.yml")
+	options.SetDefault(Keys.InternalPoliciesYaml, "./bundles/internal_policies.yml")
 	options.SetDefault(Keys.CwLogGroup, "platform-dev")
 	options.SetDefault(Keys.CwLogStream, hostname)
 	options.SetDefault(Keys.CwRegion, "us-east-1")
 	options.SetDefault(Keys.FeaturesAPIPath, "/features/v1")
 	options.SetDefault(Keys.FeatureStatusAPIPath, "/features/v2/featureStatus")
+	options.SetDefault(Keys.FeatureTrialAPIPath, "/features/v1/trials")
+	options.SetDefault(Keys.TrialsEnabled, false) // POST /trials/{bundle}, off until the Feature Service trial path and body are confirmed
 	options.SetDefault(Keys.CompAPIBasePath, "/v1/screening")
 	options.SetDefault(Keys.RunBundleSync, false)
 	options.SetDefault(Keys.EntitleAll, false)
+	options.SetDefault(Keys.ServicesExplain, false)     // allow explain=true on /services for every identity, not just internal ones
+	options.SetDefault(Keys.ServicesMaxAge, 60)         // longest max-age of a /services response, bounds how long clients miss trial, override and bundle config changes
+	options.SetDefault(Keys.ServicesBatchClientIDs, "") // comma separated service account client IDs allowed to call /services/batch
+	options.SetDefault(Keys.ServicesBatchMaxOrgs, 100)
+	options.SetDefault(Keys.ServicesBatchConcurrency, 10) // feature service lookups in flight per /services/batch request
+	options.SetDefault(Keys.TrialActivatedInterval, 60)   // seconds between trial_activated=true cache bypasses for an org, 0 bypasses on every request
 	options.SetDefault(Keys.AMSHost, "https://api.openshift.com")
 	options.SetDefault(Keys.TokenURL, "https://sso.redhat.com/auth/realms/redhat-external/protocol/openid-connect/token")
 	options.SetDefault(Keys.BOPURL, "https://backoffice-proxy.apps.ext.spoke.prod.us-west-2.aws.paas.redhat.com/v1/users")
@@ -152,9 +244,34 @@ func initialize() {
 	options.SetDefault(Keys.DisableSeatManager, false)
 	options.SetDefault(Keys.SubsCacheDuration, 1800) // seconds
 	options.SetDefault(Keys.SubsCacheMaxSize, 500)
-	options.SetDefault(Keys.SubsCacheItemPrune, 10) // percent of cache to prune when full
+	options.SetDefault(Keys.SubsCacheItemPrune, 10)     // percent of cache to prune when full
+	options.SetDefault(Keys.SubsCacheStaleWindow, 3600) // seconds past SubsCacheDuration a last known good result may be served, 0 disables
+	options.SetDefault(Keys.SubsCacheStaleRefresh, 60)  // seconds between background refreshes of an org served stale data
+	options.SetDefault(Keys.SubsCacheErrorTTL, 30)      // seconds a fail-closed result is cached after a timeout or connection error, doubled for each failure in a row up to SubsCacheNegativeMaxTTL
+	options.SetDefault(Keys.SubsCacheNon200TTL, 60)     // seconds a fail-closed result is cached after a non-200, doubled for each failure in a row up to SubsCacheNegativeMaxTTL
+	options.SetDefault(Keys.SubsCacheNegativeMaxTTL, 1800)
+	options.SetDefault(Keys.SubsCacheBackend, "memory") // memory or redis
+	options.SetDefault(Keys.SubsCacheRedisAddr, "localhost:6379")
+	options.SetDefault(Keys.SubsCacheRedisDB, 0)
+	options.SetDefault(Keys.SubsCacheRedisTimeoutMs, 250)
+	options.SetDefault(Keys.ComplianceOKTTL, 900)          // seconds an OK screening result is cached for a user, 0 disables
+	options.SetDefault(Keys.ComplianceBlockedTTL, 300)     // seconds a blocked screening result is cached for a user, 0 disables
+	options.SetDefault(Keys.ComplianceCacheMaxSize, 10000) // users whose screening is cached per replica, size it to the users screened within the OK TTL
+	options.SetDefault(Keys.ComplianceCacheItemPrune, 10)  // percent of the compliance cache to prune when full
 	options.SetDefault(Keys.AMSAcctMgmt11Msg, "Please have this user log into \"https://console.redhat.com/openshift\" to grant their account the required permissions, or try again later.")
 	options.SetDefault(Keys.ITServicesTimeoutSeconds, 10)
+	options.SetDefault(Keys.FeatureBreakerEnabled, true)
+	options.SetDefault(Keys.FeatureBreakerWindow, 60)       // seconds the breaker counts feature service calls before starting over
+	options.SetDefault(Keys.FeatureBreakerMinCalls, 20)     // calls a window needs before the breaker may open
+	options.SetDefault(Keys.FeatureBreakerFailRate, 50)     // percent of failed calls that opens the breaker, 0 disables
+	options.SetDefault(Keys.FeatureBreakerSlowCallMs, 3000) // calls slower than this count as slow
+	options.SetDefault(Keys.FeatureBreakerSlowRate, 50)     // percent of slow calls that opens the breaker, 0 disables
+	options.SetDefault(Keys.FeatureBreakerOpenSecs, 30)     // seconds calls are short-circuited before probing the feature service
+	options.SetDefault(Keys.FeatureBreakerProbes, 3)        // calls that must succeed while half-open to close the breaker
+	options.SetDefault(Keys.EventsKafkaBrokers, "")         // comma separated host:port of the brokers entitlement change events are published to, none drops events
+	options.SetDefault(Keys.EventsTopic, "platform.entitlements.events")
+	options.SetDefault(Keys.EventsDedupeWindow, 300) // seconds the last change published for an org is remembered, shared between replicas with the redis backend, 0 disables
+
 	options.SetDefault(Keys.DisableSeatManager, true) // this feature is obsolete, see https://issues.redhat.com/browse/RHCLOUD-30697
 
 	options.Set(Keys.PaidFeatureSuffix, "_paid") // we don't want this to be configurable by env
@@ -179,6 +296,36 @@ func initialize() {
 		options.Set(Keys.CwRegion, cfg.Logging.Cloudwatch.Region)
 		options.Set(Keys.CwKey, cfg.Logging.Cloudwatch.AccessKeyId)
 		options.Set(Keys.CwSecret, cfg.Logging.Cloudwatch.SecretAccessKey)
+
+		// In-memory DB, used by the redis feature status cache backend
+		if cfg.InMemoryDb != nil {
+			options.Set(Keys.SubsCacheRedisAddr, fmt.Sprintf("%s:%d", cfg.InMemoryDb.Hostname, cfg.InMemoryDb.Port))
+			if cfg.InMemoryDb.Password != nil {
+				options.Set(Keys.SubsCacheRedisPassword, *cfg.InMemoryDb.Password)
+			}
+		}
+
+		// Kafka, used to publish entitlement change events
+		if cfg.Kafka != nil && len(cfg.Kafka.Brokers) > 0 {
+			options.Set(Keys.EventsKafkaBrokers, strings.Join(clowder.KafkaServers, ","))
+			if topic, ok := clowder.KafkaTopics[options.GetString(Keys.EventsTopic)]; ok {
+				options.Set(Keys.EventsTopic, topic.Name)
+			}
+
+			broker := cfg.Kafka.Brokers[0]
+			if broker.Cacert != nil {
+				options.Set(Keys.EventsKafkaCACert, *broker.Cacert)
+			}
+			if broker.Sasl != nil && broker.Sasl.Username != nil {
+				options.Set(Keys.EventsKafkaUsername, *broker.Sasl.Username)
+				if broker.Sasl.Password != nil {
+					options.Set(Keys.EventsKafkaPassword, *broker.Sasl.Password)
+				}
+				if broker.Sasl.SaslMechanism != nil {
+					options.Set(Keys.EventsKafkaSASLMechanism, *broker.Sasl.SaslMechanism)
+				}
+			}
+		}
 	}
 }
 
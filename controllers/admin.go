@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+	"github.com/sirupsen/logrus"
+)
+
+const associateIdentityType = "Associate"
+
+const (
+	adminActionGetCacheStats   = "get_cache_stats"
+	adminActionClearCache      = "clear_cache"
+	adminActionGetOrgCache     = "get_org_cache"
+	adminActionEvictOrgCache   = "evict_org_cache"
+	adminActionEvictCompliance = "evict_compliance_cache"
+)
+
+var adminActions = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "entitlements_admin_action_total",
+		Help: "Total number of admin API requests by action and result",
+	},
+	[]string{"action", "result"},
+)
+
+// AdminApi serves the admin API to inspect and invalidate cached results. Every endpoint requires an
+// associate or internal identity and every request is audit-logged.
+type AdminApi struct{}
+
+func newCachedEntry(entry *featurecache.Entry) *api.AdminCacheEntry {
+	if entry == nil {
+		return nil
+	}
+
+	features := make([]api.AdminFeature, 0, len(entry.Status.Features))
+	for _, feature := range entry.Status.Features {
+		features = append(features, api.AdminFeature{
+			Name:      &feature.Name,
+			StartDate: (*string)(&feature.StartDate),
+			EndDate:   (*string)(&feature.EndDate),
+		})
+	}
+
+	return &api.AdminCacheEntry{
+		Status:     &api.AdminFeatureStatus{Features: &features},
+		Stale:      &entry.Stale,
+		FailClosed: toPtr(entry.FailClosed()),
+		Outcome:    toPtr(entry.LookupOutcome()),
+		StoredAt:   &entry.StoredAt,
+		ExpiresAt:  &entry.ExpiresAt,
+		AgeSeconds: toPtr(int(time.Since(entry.StoredAt).Seconds())),
+		TtlSeconds: toPtr(int(entry.TTL().Seconds())),
+	}
+}
+
+func newCacheStats(stats featurecache.Stats) *api.AdminCacheStatsEntry {
+	return &api.AdminCacheStatsEntry{
+		Name:    &stats.Name,
+		Backend: toPtr(api.AdminCacheStatsEntryBackend(stats.Backend)),
+		Entries: &stats.Entries,
+		Hits:    toPtr(int(stats.Hits)),
+		Misses:  toPtr(int(stats.Misses)),
+	}
+}
+
+// evictionScope reports whether evicting from c reaches every replica. A memory cache is local to the
+// replica that serves the request, the other replicas keep their entries until they expire.
+func evictionScope(c interface{ Backend() string }) api.AdminEvictionScope {
+	if c.Backend() == featurecache.BackendMemory {
+		return api.Replica
+	}
+	return api.AllReplicas
+}
+
+// isInternalIdentity reports whether an identity belongs to Red Hat, either an associate or an internal user
+func isInternalIdentity(id identity.Identity) bool {
+	if id.Type == associateIdentityType && id.Associate != nil {
+		return true
+	}
+
+	return id.User != nil && id.User.Internal
+}
+
+// adminActor returns who is making an admin request, for the audit log
+func adminActor(id identity.Identity) string {
+	if id.Associate != nil {
+		return id.Associate.Email
+	}
+	if id.User != nil {
+		return id.User.Username
+	}
+	return ""
+}
+
+// auditAdminAction logs an admin request along with who made it. Every admin request is logged,
+// including forbidden ones, so that cache invalidations can be traced back to a person.
+func auditAdminAction(ctx context.Context, action string, result string, fields logrus.Fields) {
+	id := identity.GetIdentity(ctx).Identity
+
+	entry := l.Log.WithFields(logrus.Fields{
+		"audit":         true,
+		"action":        action,
+		"result":        result,
+		"actor":         adminActor(id),
+		"identity_type": id.Type,
+		"actor_org_id":  id.Internal.OrgID,
+	}).WithFields(fields)
+
+	adminActions.WithLabelValues(action, result).Inc()
+
+	if result == "forbidden" {
+		entry.Warn("admin action denied")
+		return
+	}
+	entry.Info("admin action")
+}
+
+// adminForbidden reports whether the identity making the request may not use the admin API, and
+// audits the action it was denied
+func adminForbidden(ctx context.Context, action string, fields logrus.Fields) bool {
+	if isInternalIdentity(identity.GetIdentity(ctx).Identity) {
+		return false
+	}
+
+	auditAdminAction(ctx, action, "forbidden", fields)
+	return true
+}
+
+func adminForbiddenError() api.RequestErrorResponse {
+	return requestError(http.StatusForbidden, "Admin API requires an associate or internal identity")
+}
+
+// adminServiceError logs and reports an admin action that failed, and returns the body of its 500
+func adminServiceError(errMsg string, err error) string {
+	l.Log.WithFields(logrus.Fields{"error": err}).Error(errMsg)
+	sentry.CaptureException(err)
+	return http.StatusText(http.StatusInternalServerError) + ": " + errMsg + ": " + err.Error()
+}
+
+// GetAdminCache returns stats for the feature status and last known good caches
+func (AdminApi) GetAdminCache(ctx context.Context, _ api.GetAdminCacheRequestObject) (api.GetAdminCacheResponseObject, error) {
+	if adminForbidden(ctx, adminActionGetCacheStats, nil) {
+		return api.GetAdminCache403JSONResponse(adminForbiddenError()), nil
+	}
+
+	featureStatusStats, err := cache.Stats()
+	lastKnownGoodStats, lkgErr := lastKnownGood.Stats()
+	if err = errors.Join(err, lkgErr); err != nil {
+		auditAdminAction(ctx, adminActionGetCacheStats, "error", logrus.Fields{"error": err})
+		return api.GetAdminCache500TextResponse(adminServiceError("Unable to read feature status cache stats", err)), nil
+	}
+
+	auditAdminAction(ctx, adminActionGetCacheStats, "success", nil)
+	return api.GetAdminCache200JSONResponse{
+		FeatureStatus: newCacheStats(featureStatusStats),
+		LastKnownGood: newCacheStats(lastKnownGoodStats),
+	}, nil
+}
+
+// DeleteAdminCache evicts the cached feature status results of every org
+func (AdminApi) DeleteAdminCache(ctx context.Context, _ api.DeleteAdminCacheRequestObject) (api.DeleteAdminCacheResponseObject, error) {
+	if adminForbidden(ctx, adminActionClearCache, nil) {
+		return api.DeleteAdminCache403JSONResponse(adminForbiddenError()), nil
+	}
+
+	evicted, err := cache.Clear()
+	if err != nil {
+		auditAdminAction(ctx, adminActionClearCache, "error", logrus.Fields{"error": err, "evicted": evicted})
+		return api.DeleteAdminCache500TextResponse(adminServiceError("Unable to clear feature status cache", err)), nil
+	}
+	backgroundRefreshes.Clear()
+
+	scope := evictionScope(cache)
+	auditAdminAction(ctx, adminActionClearCache, "success", logrus.Fields{"evicted": evicted, "scope": scope})
+	return api.DeleteAdminCache200JSONResponse{Evicted: &evicted, Scope: &scope}, nil
+}
+
+// GetAdminCacheOrgId returns the cached feature status results of an org
+func (AdminApi) GetAdminCacheOrgId(ctx context.Context, request api.GetAdminCacheOrgIdRequestObject) (api.GetAdminCacheOrgIdResponseObject, error) {
+	orgID := request.OrgId
+	if adminForbidden(ctx, adminActionGetOrgCache, logrus.Fields{"org_id": orgID}) {
+		return api.GetAdminCacheOrgId403JSONResponse(adminForbiddenError()), nil
+	}
+
+	auditAdminAction(ctx, adminActionGetOrgCache, "success", logrus.Fields{"org_id": orgID})
+	return api.GetAdminCacheOrgId200JSONResponse{
+		OrgId:         &orgID,
+		FeatureStatus: newCachedEntry(cache.Get(orgID)),
+		LastKnownGood: newCachedEntry(lastKnownGood.Get(orgID)),
+	}, nil
+}
+
+// DeleteAdminCacheOrgId evicts the cached feature status results of an org. The last known good
+// result is kept, it is only served if the next fetch fails.
+func (AdminApi) DeleteAdminCacheOrgId(ctx context.Context, request api.DeleteAdminCacheOrgIdRequestObject) (api.DeleteAdminCacheOrgIdResponseObject, error) {
+	orgID := request.OrgId
+	if adminForbidden(ctx, adminActionEvictOrgCache, logrus.Fields{"org_id": orgID}) {
+		return api.DeleteAdminCacheOrgId403JSONResponse(adminForbiddenError()), nil
+	}
+
+	evicted, err := evictFeatureStatus(orgID)
+	if err != nil {
+		auditAdminAction(ctx, adminActionEvictOrgCache, "error", logrus.Fields{"org_id": orgID, "error": err})
+		return api.DeleteAdminCacheOrgId500TextResponse(adminServiceError("Unable to evict the org's single bundle feature statuses", err)), nil
+	}
+	backgroundRefreshes.Delete(orgID)
+
+	scope := evictionScope(cache)
+	auditAdminAction(ctx, adminActionEvictOrgCache, "success", logrus.Fields{"org_id": orgID, "evicted": evicted, "scope": scope})
+	return api.DeleteAdminCacheOrgId200JSONResponse{OrgId: &orgID, Evicted: &evicted, Scope: &scope}, nil
+}
+
+// DeleteAdminComplianceCacheUsername evicts the cached compliance screening result of a user
+func (AdminApi) DeleteAdminComplianceCacheUsername(ctx context.Context, request api.DeleteAdminComplianceCacheUsernameRequestObject) (api.DeleteAdminComplianceCacheUsernameResponseObject, error) {
+	username := request.Username
+	if adminForbidden(ctx, adminActionEvictCompliance, logrus.Fields{"username": username}) {
+		return api.DeleteAdminComplianceCacheUsername403JSONResponse(adminForbiddenError()), nil
+	}
+
+	evicted, err := evictComplianceResult(username)
+	if err != nil {
+		auditAdminAction(ctx, adminActionEvictCompliance, "error", logrus.Fields{"username": username, "error": err})
+		return api.DeleteAdminComplianceCacheUsername500TextResponse(adminServiceError("Unable to evict the user's compliance screening result", err)), nil
+	}
+
+	scope := evictionScope(complianceCache)
+	auditAdminAction(ctx, adminActionEvictCompliance, "success", logrus.Fields{"username": username, "evicted": evicted, "scope": scope})
+	return api.DeleteAdminComplianceCacheUsername200JSONResponse{Username: &username, Evicted: &evicted, Scope: &scope}, nil
+}
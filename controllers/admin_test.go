@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+)
+
+func associateIdentity() identity.Identity {
+	return identity.Identity{
+		Type: associateIdentityType,
+		Associate: &identity.Associate{
+			Email: "support@redhat.com",
+		},
+	}
+}
+
+func adminRequest(method string, path string, id identity.Identity) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, nil)
+	Expect(err).To(BeNil(), "NewRequest error was not nil")
+	req = req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{Identity: id}))
+
+	return serveApi(req)
+}
+
+var _ = Describe("Admin Cache Controller", func() {
+	const orgID = "4384938490324"
+	var status = FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}
+
+	BeforeEach(func() {
+		cache.Clear()
+		lastKnownGood.Clear()
+	})
+
+	Describe("access", func() {
+		It("should allow associates", func() {
+			rr := adminRequest("GET", "/admin/cache", associateIdentity())
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should allow internal users", func() {
+			rr := adminRequest("GET", "/admin/cache", identity.Identity{
+				Type: "User",
+				User: &identity.User{Username: "support", Internal: true},
+			})
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should reject customer users", func() {
+			rr := adminRequest("DELETE", "/admin/cache", identity.Identity{
+				Type: "User",
+				User: &identity.User{Username: "customer", OrgAdmin: true},
+			})
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+			var errorResp RequestErrorResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+			Expect(errorResp.Error.Status).To(Equal(http.StatusForbidden))
+		})
+
+		It("should reject service accounts", func() {
+			rr := adminRequest("GET", "/admin/cache/"+orgID, identity.Identity{
+				Type:           "ServiceAccount",
+				ServiceAccount: &identity.ServiceAccount{Username: "service-account-test"},
+			})
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should not evict when the request is rejected", func() {
+			// given
+			cache.Set(orgID, featurecache.Entry{Status: status}, time.Minute)
+
+			// when
+			adminRequest("DELETE", "/admin/cache/"+orgID, identity.Identity{Type: "User", User: &identity.User{}})
+
+			// then
+			Expect(cache.Get(orgID)).ToNot(BeNil())
+		})
+	})
+
+	Describe("GET /{orgId}", func() {
+		It("should return the cached entries for the org with their age and expiry", func() {
+			// given
+			storedAt := time.Now().Add(-time.Minute)
+			cache.Set(orgID, featurecache.Entry{Status: status, StoredAt: storedAt}, time.Hour)
+			lastKnownGood.Set(orgID, featurecache.Entry{Status: status, StoredAt: storedAt}, 2*time.Hour)
+
+			// when
+			rr := adminRequest("GET", "/admin/cache/"+orgID, associateIdentity())
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var body api.AdminOrgCache
+			Expect(json.Unmarshal(rr.Body.Bytes(), &body)).To(Succeed())
+			Expect(*body.OrgId).To(Equal(orgID))
+			Expect(*body.FeatureStatus.Status.Features).To(HaveExactElements(HaveField("Name", HaveValue(Equal("TestBundle1")))))
+			Expect(*body.FeatureStatus.FailClosed).To(BeFalse())
+			Expect(*body.FeatureStatus.Outcome).To(Equal(FeatureOutcomeSuccess))
+			Expect(*body.FeatureStatus.AgeSeconds).To(BeNumerically("~", 60, 1))
+			Expect(*body.FeatureStatus.TtlSeconds).To(BeNumerically("~", 3600, 1))
+			Expect(*body.LastKnownGood.TtlSeconds).To(BeNumerically("~", 7200, 1))
+		})
+
+		It("should flag fail-closed entries", func() {
+			// given
+			cache.Set(orgID, featurecache.Entry{}, time.Hour)
+
+			// when
+			rr := adminRequest("GET", "/admin/cache/"+orgID, associateIdentity())
+
+			// then
+			var body api.AdminOrgCache
+			Expect(json.Unmarshal(rr.Body.Bytes(), &body)).To(Succeed())
+			Expect(*body.FeatureStatus.FailClosed).To(BeTrue())
+		})
+
+		It("should return null entries for an org that is not cached", func() {
+			rr := adminRequest("GET", "/admin/cache/"+orgID, associateIdentity())
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(MatchJSON(`{"orgId":"` + orgID + `","featureStatus":null,"lastKnownGood":null}`))
+		})
+	})
+
+	Describe("DELETE /{orgId}", func() {
+		It("should evict the org so the next lookup goes to the feature service", func() {
+			// given
+			cache.Set(orgID, featurecache.Entry{Status: status}, time.Hour)
+			cache.Set("other", featurecache.Entry{Status: status}, time.Hour)
+			lastKnownGood.Set(orgID, featurecache.Entry{Status: status}, time.Hour)
+
+			// when
+			rr := adminRequest("DELETE", "/admin/cache/"+orgID, associateIdentity())
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(MatchJSON(`{"orgId":"` + orgID + `","evicted":true,"scope":"replica"}`))
+			Expect(cache.Get(orgID)).To(BeNil())
+			Expect(cache.Get("other")).ToNot(BeNil())
+			Expect(lastKnownGood.Get(orgID)).ToNot(BeNil())
+		})
+
+		It("should report when there was nothing to evict", func() {
+			rr := adminRequest("DELETE", "/admin/cache/"+orgID, associateIdentity())
+
+			Expect(rr.Body.String()).To(MatchJSON(`{"orgId":"` + orgID + `","evicted":false,"scope":"replica"}`))
+		})
+	})
+
+	Describe("DELETE /", func() {
+		It("should evict every org", func() {
+			// given
+			cache.Set(orgID, featurecache.Entry{Status: status}, time.Hour)
+			cache.Set("other", featurecache.Entry{Status: status}, time.Hour)
+
+			// when
+			rr := adminRequest("DELETE", "/admin/cache", associateIdentity())
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(MatchJSON(`{"evicted":2,"scope":"replica"}`))
+			Expect(cache.Get(orgID)).To(BeNil())
+			Expect(cache.Get("other")).To(BeNil())
+		})
+	})
+
+	Describe("GET /", func() {
+		It("should return stats for both caches", func() {
+			// given
+			cache.Set(orgID, featurecache.Entry{Status: status}, time.Hour)
+			cache.Set("other", featurecache.Entry{Status: status}, time.Hour)
+			lastKnownGood.Set(orgID, featurecache.Entry{Status: status}, time.Hour)
+
+			// when
+			rr := adminRequest("GET", "/admin/cache", associateIdentity())
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var body api.AdminCacheStats
+			Expect(json.Unmarshal(rr.Body.Bytes(), &body)).To(Succeed())
+			Expect(*body.FeatureStatus.Name).To(Equal("feature_status"))
+			Expect(*body.FeatureStatus.Backend).To(Equal(api.Memory))
+			Expect(*body.FeatureStatus.Entries).To(Equal(2))
+			Expect(*body.LastKnownGood.Name).To(Equal("last_known_good"))
+			Expect(*body.LastKnownGood.Entries).To(Equal(1))
+		})
+	})
+
+	DescribeTable("should report whether an eviction reached every replica",
+		func(c featurecache.Cache, scope api.AdminEvictionScope) {
+			Expect(evictionScope(c)).To(Equal(scope))
+		},
+		Entry("memory", featurecache.NewMemory("test", 10, 1), api.Replica),
+		Entry("redis", featurecache.NewRedis("test", nil, nil), api.AllReplicas),
+	)
+})
+
+var _ = Describe("Admin Compliance Cache Controller", func() {
+	const username = "test@redhat.com"
+
+	BeforeEach(func() {
+		useMemoryScreeningCache()
+	})
+
+	Describe("DELETE /{username}", func() {
+		It("should evict the user so their next screening goes to the compliance service", func() {
+			// given
+			complianceCache.Set(username, screening{status: http.StatusOK}, time.Hour)
+			complianceCache.Set("other", screening{status: http.StatusOK}, time.Hour)
+
+			// when
+			rr := adminRequest("DELETE", "/admin/compliance/cache/"+username, associateIdentity())
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(MatchJSON(`{"username":"` + username + `","evicted":true,"scope":"replica"}`))
+			_, cached := complianceCache.Get(username)
+			Expect(cached).To(BeFalse())
+			_, cached = complianceCache.Get("other")
+			Expect(cached).To(BeTrue())
+		})
+
+		It("should report when there was nothing to evict", func() {
+			rr := adminRequest("DELETE", "/admin/compliance/cache/"+username, associateIdentity())
+
+			Expect(rr.Body.String()).To(MatchJSON(`{"username":"` + username + `","evicted":false,"scope":"replica"}`))
+		})
+
+		It("should reject customer users", func() {
+			// given
+			complianceCache.Set(username, screening{status: http.StatusOK}, time.Hour)
+
+			// when
+			rr := adminRequest("DELETE", "/admin/compliance/cache/"+username, identity.Identity{
+				Type: "User",
+				User: &identity.User{Username: username},
+			})
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+			_, cached := complianceCache.Get(username)
+			Expect(cached).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// SeatsServer is the part of api.StrictServerInterface served by SeatManagerApi
+type SeatsServer interface {
+	GetSeats(ctx context.Context, request api.GetSeatsRequestObject) (api.GetSeatsResponseObject, error)
+	PostSeats(ctx context.Context, request api.PostSeatsRequestObject) (api.PostSeatsResponseObject, error)
+	DeleteSeatsId(ctx context.Context, request api.DeleteSeatsIdRequestObject) (api.DeleteSeatsIdResponseObject, error)
+}
+
+// ServicesApi serves the endpoints that decide bundles for the identity making the request, and /compliance
+type ServicesApi struct {
+	features featureservice.FeatureService
+}
+
+func NewServicesApi(features featureservice.FeatureService) ServicesApi {
+	return ServicesApi{features: features}
+}
+
+// ApiServer serves every endpoint generated from api.spec.json by handing each to the API it belongs to
+type ApiServer struct {
+	SeatsServer
+	*ServicesBatchApi
+	ServicesApi
+	AdminApi
+}
+
+var _ api.StrictServerInterface = &ApiServer{}
+
+// NewApiServer combines the generated APIs. The seats endpoints respond as if they did not exist
+// when seats is nil, which is the case when the seat manager is disabled.
+func NewApiServer(seats SeatsServer, servicesBatch *ServicesBatchApi, services ServicesApi) *ApiServer {
+	if seats == nil {
+		seats = seatsDisabled{}
+	}
+	return &ApiServer{SeatsServer: seats, ServicesBatchApi: servicesBatch, ServicesApi: services}
+}
+
+// NewApiHandler registers the endpoints of apiServer on r under baseURL
+func NewApiHandler(apiServer *ApiServer, r chi.Router, baseURL string) http.Handler {
+	return api.HandlerWithOptions(newStrictHandler(apiServer), api.ChiServerOptions{
+		BaseURL:          baseURL,
+		BaseRouter:       r,
+		ErrorHandlerFunc: invalidParamsHandler,
+	})
+}
+
+// newStrictHandler adapts apiServer to the generated handlers, which parse the parameters and body of
+// each request and write the response apiServer returns
+func newStrictHandler(apiServer *ApiServer) api.ServerInterface {
+	return api.NewStrictHandlerWithOptions(apiServer, nil, api.StrictHTTPServerOptions{
+		RequestErrorHandlerFunc:  invalidBodyHandler,
+		ResponseErrorHandlerFunc: responseErrorHandler,
+	})
+}
+
+// requestError builds the RequestErrorResponse returned for requests that cannot be served
+func requestError(status int, errMsg string) api.RequestErrorResponse {
+	return api.RequestErrorResponse{Error: &api.RequestErrorDetails{Status: &status, Message: &errMsg}}
+}
+
+// writeRequestError writes a JSON RequestErrorResponse with the given status, for requests rejected
+// before they reach a handler
+func writeRequestError(w http.ResponseWriter, status int, errMsg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(requestError(status, errMsg))
+}
+
+func invalidParamsHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	writeRequestError(w, http.StatusBadRequest, err.Error())
+}
+
+func invalidBodyHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	writeRequestError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+}
+
+func responseErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	l.Log.WithFields(logrus.Fields{"error": err, "path": r.URL.Path}).Error("Unexpected error while writing response")
+	sentry.CaptureException(err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+type seatsDisabled struct{}
+
+func (seatsDisabled) GetSeats(context.Context, api.GetSeatsRequestObject) (api.GetSeatsResponseObject, error) {
+	return seatsNotFound{}, nil
+}
+
+func (seatsDisabled) PostSeats(context.Context, api.PostSeatsRequestObject) (api.PostSeatsResponseObject, error) {
+	return seatsNotFound{}, nil
+}
+
+func (seatsDisabled) DeleteSeatsId(context.Context, api.DeleteSeatsIdRequestObject) (api.DeleteSeatsIdResponseObject, error) {
+	return seatsNotFound{}, nil
+}
+
+// seatsNotFound is the response of every seats endpoint when the seat manager is disabled, the same
+// a path without a route gets
+type seatsNotFound struct{}
+
+func (seatsNotFound) write(w http.ResponseWriter) error {
+	http.Error(w, "404 page not found", http.StatusNotFound)
+	return nil
+}
+
+func (response seatsNotFound) VisitGetSeatsResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
+
+func (response seatsNotFound) VisitPostSeatsResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
+
+func (response seatsNotFound) VisitDeleteSeatsIdResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
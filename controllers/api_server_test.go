@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+)
+
+var _ = Describe("Api server", func() {
+	withUser := func(req *http.Request) *http.Request {
+		return req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{
+			Identity: identity.Identity{
+				AccountNumber: DEFAULT_ACCOUNT_NUMBER,
+				User:          &identity.User{Email: DEFAULT_EMAIL, OrgAdmin: true},
+				Internal:      identity.Internal{OrgID: DEFAULT_ORG_ID},
+			},
+		}))
+	}
+
+	DescribeTable("should answer every seats endpoint with a 404 when the seat manager is disabled",
+		func(method string, path string, body string) {
+			// given
+			req, err := http.NewRequest(method, path, strings.NewReader(body))
+			Expect(err).To(BeNil())
+
+			// when
+			rr := serveApi(withUser(req))
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		},
+		Entry("list seats", "GET", "/seats", ""),
+		Entry("assign a seat", "POST", "/seats", `{"account_username": "test-user"}`),
+		Entry("remove a seat", "DELETE", "/seats/1", ""),
+	)
+
+	It("should answer invalid parameters with a JSON request error", func() {
+		// given
+		req, err := http.NewRequest("GET", "/services?explain=maybe", nil)
+		Expect(err).To(BeNil())
+
+		// when
+		rr := serveApi(withUser(req))
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+		var errorResp RequestErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+		Expect(errorResp.Error.Status).To(Equal(http.StatusBadRequest))
+		Expect(errorResp.Error.Message).To(ContainSubstring("explain"))
+	})
+
+	It("should answer forbidden requests with a JSON request error", func() {
+		// given
+		req, err := http.NewRequest("GET", "/services?explain=true", nil)
+		Expect(err).To(BeNil())
+
+		// when
+		rr := serveApi(withUser(req))
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+		Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+		var errorResp RequestErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+		Expect(errorResp.Error.Message).To(Equal("explain=true is only available to internal users"))
+	})
+
+	Context("with an injected Feature Service", func() {
+		var fake *featureservice.Fake
+
+		serveWith := func(req *http.Request) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			NewApiHandler(NewApiServer(nil, NewServicesBatchApi(fake), NewServicesApi(fake)), chi.NewRouter(), "").ServeHTTP(rr, req)
+			return rr
+		}
+
+		BeforeEach(func() {
+			GetFeatureStatus = realGetFeatureStatus
+			requestFeatures("TestBundle1")
+			cache.Clear()
+			lastKnownGood.Clear()
+			fake = featureservice.NewFake()
+		})
+
+		It("should decide bundles with the features it returns", func() {
+			// given
+			fake.SetFeatures(DEFAULT_ORG_ID, Feature{Name: "TestBundle1"})
+			req, err := http.NewRequest("GET", "/services", nil)
+			Expect(err).To(BeNil())
+
+			// when
+			rr := serveWith(withUser(req))
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var body map[string]EntitlementsSection
+			Expect(json.Unmarshal(rr.Body.Bytes(), &body)).To(Succeed())
+			Expect(body["TestBundle1"].IsEntitled).To(BeTrue())
+			Expect(fake.Calls()).To(HaveLen(1))
+			Expect(fake.Calls()[0].Features).To(ContainElement("TestBundle1"))
+		})
+
+		It("should degrade the response when the feature status cannot be decoded", func() {
+			// given
+			fake.SetError(DEFAULT_ORG_ID, &featureservice.DecodeError{Body: "<html>", Err: errors.New("invalid character")})
+			req, err := http.NewRequest("GET", "/services", nil)
+			Expect(err).To(BeNil())
+
+			// when
+			rr := serveWith(withUser(req))
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("X-Entitlements-Degraded")).To(Equal("true"))
+			var body map[string]EntitlementsSection
+			Expect(json.Unmarshal(rr.Body.Bytes(), &body)).To(Succeed())
+			Expect(body["TestBundle1"].IsEntitled).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"math"
+	"time"
+
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// now is swapped out in tests so trial_days_remaining is predictable
+var now = time.Now
+
+// entitlementFeature is the feature whose dates describe an entitled bundle: the paid feature when
+// the org has one, otherwise the bundle's own feature
+func entitlementFeature(bundle types.Bundle, features map[string]types.Feature) (types.Feature, bool) {
+	if !bundle.IsSkuBased() {
+		return types.Feature{}, false
+	}
+	if bundle.IsPaid() {
+		if feature, ok := features[bundle.Name+paidFeatureSuffix]; ok {
+			return feature, true
+		}
+	}
+	feature, ok := features[bundle.Name]
+	return feature, ok
+}
+
+// setBundleDates adds the start and end dates of the feature an entitled bundle comes from, and how
+// long is left of a trial. Dates that can't be parsed are logged and left out.
+func setBundleDates(section *types.EntitlementsSection, bundle types.Bundle, features map[string]types.Feature, at time.Time) {
+	if !section.IsEntitled {
+		return
+	}
+	feature, ok := entitlementFeature(bundle, features)
+	if !ok {
+		return
+	}
+
+	section.StartsAt = parseFeatureDate(feature, "startDate", feature.StartDate)
+	section.ExpiresAt = parseFeatureDate(feature, "endDate", feature.EndDate)
+
+	if section.IsTrial && section.ExpiresAt != nil {
+		days := max(int(math.Ceil(section.ExpiresAt.Sub(at).Hours()/24)), 0)
+		section.TrialDaysRemaining = &days
+	}
+}
+
+func parseFeatureDate(feature types.Feature, field string, date types.FeatureDate) *time.Time {
+	t, ok, err := date.Time()
+	if err != nil {
+		l.Log.WithFields(logrus.Fields{"feature": feature.Name, "field": field}).WithError(err).Warn("Could not parse a date from the Feature Service")
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/getsentry/sentry-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+var bundleConfigReload = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "bundle_config_reload_total",
+		Help: "Total number of bundle config reload attempts by result",
+	},
+	[]string{"result"},
+)
+var bundleConfigInfo = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "bundle_config_info",
+		Help: "Hash of the currently loaded bundle config, the value is always 1",
+	},
+	[]string{"hash"},
+)
+
+const (
+	reloadResultSuccess   = "success"
+	reloadResultFailure   = "failure"
+	reloadResultUnchanged = "unchanged"
+)
+
+// bundleState is an immutable snapshot of the loaded bundle config and everything derived from it.
+// A new snapshot is swapped in as a whole so a request never sees bundles from one file and
+// requested features from another.
+type bundleState struct {
+	bundles []types.Bundle
+	// conditions holds the compiled entitlement condition of each bundle by name
+	conditions map[string]evaluator.Condition
+	// requestedFeatures are the features requested from the Feature Service to decide every bundle
+	requestedFeatures []string
+	// bundleRequestedFeatures holds the features of each bundle that needs features by name
+	bundleRequestedFeatures map[string][]string
+	// featureSet identifies the requested features, feature statuses cached for another set are not used
+	featureSet string
+	hash       string
+	loadedAt   time.Time
+}
+
+// bundleReloadStatus describes the outcome of the most recent attempt to load the bundle config
+type bundleReloadStatus struct {
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+var loadedBundles atomic.Pointer[bundleState]
+
+var lastReloadMu sync.RWMutex
+var lastReload bundleReloadStatus
+
+// getBundleState returns the currently loaded bundle config, never nil
+func getBundleState() *bundleState {
+	if state := loadedBundles.Load(); state != nil {
+		return state
+	}
+	return &bundleState{}
+}
+
+// storeBundleInfo builds a new snapshot from the given bundles and swaps it in.
+// The current snapshot is kept if a bundle's conditions do not compile.
+func storeBundleInfo(bundles []types.Bundle, hash string) error {
+	conditions := make(map[string]evaluator.Condition, len(bundles))
+	for _, bundle := range bundles {
+		condition, err := bundle.Condition(getInternalPolicies())
+		if err != nil {
+			return fmt.Errorf("bundle %q: %w", bundle.Name, err)
+		}
+		conditions[bundle.Name] = condition
+	}
+
+	requestedFeatures := buildRequestedFeatures(bundles)
+	bundleRequestedFeatures := buildBundleRequestedFeatures(bundles)
+	loadedBundles.Store(&bundleState{
+		bundles:                 bundles,
+		conditions:              conditions,
+		requestedFeatures:       requestedFeatures,
+		bundleRequestedFeatures: bundleRequestedFeatures,
+		featureSet:              featureSetHash(requestedFeatures, bundleRequestedFeatures),
+		hash:                    hash,
+		loadedAt:                time.Now(),
+	})
+
+	bundleConfigInfo.Reset()
+	bundleConfigInfo.WithLabelValues(hash).Set(1)
+	return nil
+}
+
+func recordReload(result string, err error) {
+	status := bundleReloadStatus{Result: result, At: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	lastReloadMu.Lock()
+	lastReload = status
+	lastReloadMu.Unlock()
+
+	bundleConfigReload.WithLabelValues(result).Inc()
+}
+
+func getLastReload() bundleReloadStatus {
+	lastReloadMu.RLock()
+	defer lastReloadMu.RUnlock()
+	return lastReload
+}
+
+// parseBundleInfo reads and validates the bundle config at yamlFilePath, returning the bundles and
+// the hash of the file contents
+func parseBundleInfo(yamlFilePath string) ([]types.Bundle, string, error) {
+	bundlesYaml, err := os.ReadFile(yamlFilePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bundles, err := types.ParseBundles(bundlesYaml)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", yamlFilePath, err)
+	}
+
+	sum := sha256.Sum256(bundlesYaml)
+	return bundles, hex.EncodeToString(sum[:]), nil
+}
+
+// SetBundleInfo sets the bundle information fetched from the YAML.
+// The currently loaded bundle config is left in place if the file cannot be read or parsed.
+func SetBundleInfo(yamlFilePath string) error {
+	bundles, hash, err := parseBundleInfo(yamlFilePath)
+	if err != nil {
+		sentry.CaptureException(err)
+		recordReload(reloadResultFailure, err)
+		return err
+	}
+
+	if hash == getBundleState().hash {
+		recordReload(reloadResultUnchanged, nil)
+		return nil
+	}
+
+	if err = storeBundleInfo(bundles, hash); err != nil {
+		sentry.CaptureException(err)
+		recordReload(reloadResultFailure, err)
+		return err
+	}
+	recordReload(reloadResultSuccess, nil)
+
+	return nil
+}
+
+func buildRequestedFeatures(bundles []types.Bundle) []string {
+	features := strings.Split(configOptions.GetString(config.Keys.Features), ",")
+
+	var skuBasedFeatures []string
+	for _, bundle := range bundles {
+		skuBasedFeatures = append(skuBasedFeatures, bundleFeatures(bundle, features)...)
+	}
+
+	return skuBasedFeatures
+}
+
+// buildBundleRequestedFeatures returns the features of each bundle on its own, used when a single bundle
+// is checked for an org that is not cached. Bundles that need no features are left out.
+func buildBundleRequestedFeatures(bundles []types.Bundle) map[string][]string {
+	features := strings.Split(configOptions.GetString(config.Keys.Features), ",")
+
+	requested := make(map[string][]string, len(bundles))
+	for _, bundle := range bundles {
+		needed := bundleFeatures(bundle, features)
+		if bundle.EntitledWhen != nil {
+			for _, feature := range bundle.EntitledWhen.Features() {
+				if slices.Contains(features, feature) && !slices.Contains(needed, feature) {
+					needed = append(needed, feature)
+				}
+			}
+		}
+
+		if len(needed) > 0 {
+			requested[bundle.Name] = needed
+		}
+	}
+	return requested
+}
+
+// featureSetHash identifies the features requested for orgs and for single bundles. It changes when a
+// reload adds or removes features, so feature statuses cached without them are fetched again rather
+// than showing new bundles as not entitled until they expire.
+func featureSetHash(requested []string, bundleRequested map[string][]string) string {
+	sum := sha256.New()
+	sum.Write([]byte(strings.Join(requested, ",")))
+	for _, bundle := range slices.Sorted(maps.Keys(bundleRequested)) {
+		sum.Write([]byte("\n" + bundle + ":" + strings.Join(bundleRequested[bundle], ",")))
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:16]
+}
+
+// bundleFeatures returns the features requested from the Feature Service for a SKU based bundle
+func bundleFeatures(bundle types.Bundle, features []string) []string {
+	if !slices.Contains(features, bundle.Name) || !bundle.IsSkuBased() {
+		return nil
+	}
+
+	if bundle.IsPaid() {
+		return []string{bundle.Name, bundle.Name + paidFeatureSuffix}
+	}
+	return []string{bundle.Name}
+}
+
+// WatchBundleInfo reloads the bundle config whenever the file at yamlFilePath changes, until ctx is done.
+func WatchBundleInfo(ctx context.Context, yamlFilePath string) error {
+	return watchConfigFile(ctx, yamlFilePath, "bundle config", reloadBundleInfo)
+}
+
+// watchConfigFile calls reload whenever the file at path changes, until ctx is done.
+// The parent directory is watched rather than the file itself so that ConfigMap updates, which swap a
+// symlink instead of writing to the file, are picked up as well.
+func watchConfigFile(ctx context.Context, path string, name string, reload func(string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Chmod) {
+					continue
+				}
+				reload(path)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				l.Log.WithFields(logrus.Fields{"error": err}).Error("Error watching " + name)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func reloadBundleInfo(yamlFilePath string) {
+	previousHash := getBundleState().hash
+	err := SetBundleInfo(yamlFilePath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		// the file is briefly missing while it is being replaced, the following event will pick it up
+		return
+	}
+
+	if err != nil {
+		l.Log.WithFields(logrus.Fields{"error": err, "hash": previousHash}).Error("Error reloading bundle config, keeping previous config")
+		return
+	}
+
+	if current := getBundleState().hash; current != previousHash {
+		l.Log.WithFields(logrus.Fields{"previous_hash": previousHash, "hash": current}).Info("bundle config reloaded")
+	}
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const oneBundleYaml = `
+- name: TestBundle1
+  skus:
+    - SVC123
+`
+
+const twoBundlesYaml = `
+- name: TestBundle1
+  skus:
+    - SVC123
+- name: TestBundle2
+  use_valid_acc_num: true
+`
+
+func bundleNames() []string {
+	var names []string
+	for _, bundle := range getBundleState().bundles {
+		names = append(names, bundle.Name)
+	}
+	return names
+}
+
+var _ = Describe("Bundle Info", func() {
+	var dir string
+	var bundlesPath string
+
+	BeforeEach(func() {
+		storeBundleInfo([]Bundle{}, "")
+		dir = GinkgoT().TempDir()
+		bundlesPath = filepath.Join(dir, "bundles.yml")
+		Expect(os.WriteFile(bundlesPath, []byte(oneBundleYaml), 0644)).To(Succeed())
+	})
+
+	Describe("SetBundleInfo", func() {
+		It("should record the hash of the loaded file", func() {
+			// when
+			err := SetBundleInfo(bundlesPath)
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(getBundleState().hash).To(HaveLen(64))
+			Expect(getBundleState().loadedAt).ToNot(BeZero())
+			Expect(getLastReload().Result).To(Equal(reloadResultSuccess))
+		})
+
+		It("should not swap the bundle config when the file is unchanged", func() {
+			// given
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+			loaded := getBundleState()
+
+			// when
+			err := SetBundleInfo(bundlesPath)
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(getBundleState()).To(BeIdenticalTo(loaded))
+			Expect(getLastReload().Result).To(Equal(reloadResultUnchanged))
+		})
+
+		It("should fetch cached feature statuses again when a reload changes the requested features", func() {
+			// given
+			configOptions.Set(config.Keys.Features, "TestBundle1,TestBundle3")
+			DeferCleanup(configOptions.Set, config.Keys.Features, "")
+			DeferCleanup(cache.Delete, "reload-org")
+			fake := featureservice.NewFake()
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+			realGetFeatureStatus(GetFeatureStatusParams{FeatureService: fake, OrgId: "reload-org"})
+			Expect(os.WriteFile(bundlesPath, []byte(oneBundleYaml+"- name: TestBundle3\n  skus: [SVC999]\n"), 0644)).To(Succeed())
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+
+			// when
+			res := realGetFeatureStatus(GetFeatureStatusParams{FeatureService: fake, OrgId: "reload-org"})
+
+			// then
+			Expect(res.CacheHit).To(BeFalse())
+			Expect(fake.Calls()).To(HaveLen(2))
+			Expect(fake.Calls()[1].Features).To(Equal([]string{"TestBundle1", "TestBundle3"}))
+		})
+
+		It("should keep cached feature statuses when a reload does not change the requested features", func() {
+			// given
+			configOptions.Set(config.Keys.Features, "TestBundle1")
+			DeferCleanup(configOptions.Set, config.Keys.Features, "")
+			DeferCleanup(cache.Delete, "reload-org")
+			fake := featureservice.NewFake()
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+			realGetFeatureStatus(GetFeatureStatusParams{FeatureService: fake, OrgId: "reload-org"})
+			Expect(os.WriteFile(bundlesPath, []byte(twoBundlesYaml), 0644)).To(Succeed())
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+
+			// when
+			res := realGetFeatureStatus(GetFeatureStatusParams{FeatureService: fake, OrgId: "reload-org"})
+
+			// then
+			Expect(res.CacheHit).To(BeTrue())
+			Expect(fake.Calls()).To(HaveLen(1))
+		})
+
+		It("should reject a config without bundles", func() {
+			// given
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+			Expect(os.WriteFile(bundlesPath, []byte(""), 0644)).To(Succeed())
+
+			// when
+			err := SetBundleInfo(bundlesPath)
+
+			// then
+			Expect(err).To(HaveOccurred())
+			Expect(bundleNames()).To(HaveExactElements("TestBundle1"))
+		})
+	})
+
+	Describe("WatchBundleInfo", func() {
+		var cancel context.CancelFunc
+
+		BeforeEach(func() {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			Expect(SetBundleInfo(bundlesPath)).To(BeNil())
+			Expect(WatchBundleInfo(ctx, bundlesPath)).To(BeNil())
+		})
+
+		AfterEach(func() {
+			cancel()
+		})
+
+		It("should reload the bundle config when the file is written", func() {
+			// when
+			Expect(os.WriteFile(bundlesPath, []byte(twoBundlesYaml), 0644)).To(Succeed())
+
+			// then
+			Eventually(bundleNames).Should(HaveExactElements("TestBundle1", "TestBundle2"))
+		})
+
+		It("should rebuild the requested features along with the bundles", func() {
+			// given
+			configOptions.Set(config.Keys.Features, "TestBundle1,TestBundle3")
+			DeferCleanup(configOptions.Set, config.Keys.Features, "")
+
+			// when
+			Expect(os.WriteFile(bundlesPath, []byte(oneBundleYaml+"- name: TestBundle3\n  skus: [SVC999]\n"), 0644)).To(Succeed())
+
+			// then
+			Eventually(func() []string { return getBundleState().requestedFeatures }).
+				Should(Equal([]string{"TestBundle1", "TestBundle3"}))
+		})
+
+		It("should reload the bundle config when a symlink is swapped like a ConfigMap update", func() {
+			// given
+			target := filepath.Join(dir, "bundles-v2.yml")
+			Expect(os.WriteFile(target, []byte(twoBundlesYaml), 0644)).To(Succeed())
+			link := filepath.Join(dir, "bundles.yml.tmp")
+			Expect(os.Symlink(target, link)).To(Succeed())
+
+			// when
+			Expect(os.Rename(link, bundlesPath)).To(Succeed())
+
+			// then
+			Eventually(bundleNames).Should(HaveExactElements("TestBundle1", "TestBundle2"))
+		})
+
+		It("should keep the previous bundle config when the new file does not parse", func() {
+			// given
+			loaded := getBundleState()
+
+			// when
+			Expect(os.WriteFile(bundlesPath, []byte("foo: [bar"), 0644)).To(Succeed())
+
+			// then
+			Eventually(func() string { return getLastReload().Result }).Should(Equal(reloadResultFailure))
+			Expect(getBundleState()).To(BeIdenticalTo(loaded))
+		})
+	})
+})
@@ -5,11 +5,36 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/RedHatInsights/entitlements-api-go/breaker"
 	"github.com/RedHatInsights/entitlements-api-go/config"
 )
 
 var client *http.Client
 
+// featureServiceBreaker fails feature service calls fast while the feature service is failing or slow
+var featureServiceBreaker = newFeatureServiceBreaker()
+
+func newFeatureServiceBreaker() *breaker.Breaker {
+	options := config.GetConfig().Options
+	settings := breaker.Settings{
+		Window:         time.Duration(options.GetInt(config.Keys.FeatureBreakerWindow)) * time.Second,
+		MinRequests:    options.GetInt(config.Keys.FeatureBreakerMinCalls),
+		FailureRate:    options.GetInt(config.Keys.FeatureBreakerFailRate),
+		SlowCall:       time.Duration(options.GetInt(config.Keys.FeatureBreakerSlowCallMs)) * time.Millisecond,
+		SlowCallRate:   options.GetInt(config.Keys.FeatureBreakerSlowRate),
+		OpenDuration:   time.Duration(options.GetInt(config.Keys.FeatureBreakerOpenSecs)) * time.Second,
+		HalfOpenProbes: options.GetInt(config.Keys.FeatureBreakerProbes),
+	}
+
+	if !options.GetBool(config.Keys.FeatureBreakerEnabled) {
+		// a breaker without thresholds never opens
+		settings.FailureRate = 0
+		settings.SlowCallRate = 0
+	}
+
+	return breaker.New("feature_service", settings)
+}
+
 func getClient() *http.Client {
 	if client != nil {
 		return client
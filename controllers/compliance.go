@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	u "net/url"
@@ -11,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/RedHatInsights/entitlements-api-go/api"
 	"github.com/RedHatInsights/entitlements-api-go/config"
 	l "github.com/RedHatInsights/entitlements-api-go/logger"
 	"github.com/RedHatInsights/entitlements-api-go/types"
@@ -26,7 +29,7 @@ var complianceServiceName = "Export Compliance Service"
 var complianceFailure = promauto.NewCounterVec(
 	prometheus.CounterOpts{
 		Name: "it_export_compliance_service_failure",
-		Help: "Total number of IT export compliance service failures",
+		Help: "Total number of IT export compliance service failures by status code, invalid_response for a 2xx that could not be decoded, or unknown_result for a result that is not known",
 	},
 	[]string{"code"},
 )
@@ -35,70 +38,145 @@ var complianceTimeHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
 	Help:    "Export compliance service latency distributions.",
 	Buckets: prometheus.LinearBuckets(0.25, 0.25, 20),
 })
+var complianceCacheLookups = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "it_export_compliance_service_cache_lookups_total",
+		Help: "Total number of export compliance screening cache lookups by result, hit or miss",
+	},
+	[]string{"result"},
+)
 
-func Compliance() func(http.ResponseWriter, *http.Request) {
-	return func(w http.ResponseWriter, req *http.Request) {
-		start := time.Now()
+// complianceCache holds the screening results of users by username, see cacheScreening
+var complianceCache = newScreeningCache()
 
-		userIdentity := identity.GetIdentity(req.Context()).Identity
+// complianceResponse is a /compliance response whose status is decided at runtime, a ComplianceResult or
+// a RequestErrorResponse
+type complianceResponse struct {
+	status int
+	body   any
+	// cacheHit is set when the response is a screening result cached for the user
+	cacheHit bool
+}
 
-		// Service Accounts don't have User field and cannot be screened for compliance
-		if userIdentity.User == nil {
-			err := errors.New("compliance: Service Accounts are not supported for compliance screening")
-			failOnBadRequest(w, "Invalid identity type", err)
-			return
-		}
+func (response complianceResponse) VisitGetComplianceResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	if response.cacheHit {
+		w.Header().Set("X-Entitlements-Cached", "true")
+	}
+	w.WriteHeader(response.status)
+	return json.NewEncoder(w).Encode(response.body)
+}
 
-		if len(strings.TrimSpace(userIdentity.User.Username)) == 0 {
-			err := errors.New("compliance: x-rh-identity header has a missing or whitespace username")
-			failOnBadRequest(w, "Invalid x-rh-identity header", err)
-			return
-		}
+// canDebugCompliance reports whether an identity may see the raw Export Compliance Service response
+func canDebugCompliance(id identity.Identity) bool {
+	return configOptions.GetBool(config.Keys.Debug) || isInternalIdentity(id)
+}
 
-		reqBody := constructComplianceRequestBody(userIdentity)
+// GetCompliance screens the user making the request with the Export Compliance Service
+func (ServicesApi) GetCompliance(ctx context.Context, request api.GetComplianceRequestObject) (api.GetComplianceResponseObject, error) {
+	start := time.Now()
 
-		reqBodyJson, err := json.Marshal(reqBody)
-		if err != nil {
-			failOnServiceError(w, "Unable to marshal request to compliance service", err)
-			return
-		}
+	userIdentity := identity.GetIdentity(ctx).Identity
 
-		var httpClient = getClient()
-		configOptions := config.GetConfig().Options
-		url := configOptions.GetString(config.Keys.ComplianceHost) + configOptions.GetString(config.Keys.CompAPIBasePath)
-		complianceReq, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(reqBodyJson))
+	// Service Accounts don't have User field and cannot be screened for compliance
+	if userIdentity.User == nil {
+		err := errors.New("compliance: Service Accounts are not supported for compliance screening")
+		return failOnBadRequest("Invalid identity type", err), nil
+	}
 
-		if err != nil {
-			failOnComplianceError(w, "Unexpected error while creating request to Export Compliance Service", err, url)
-			return
-		}
+	if len(strings.TrimSpace(userIdentity.User.Username)) == 0 {
+		err := errors.New("compliance: x-rh-identity header has a missing or whitespace username")
+		return failOnBadRequest("Invalid x-rh-identity header", err), nil
+	}
+
+	debug := fromPtr(request.Params.Debug)
+	if debug && !canDebugCompliance(userIdentity) {
+		return api.GetCompliance403JSONResponse(requestError(http.StatusForbidden, "debug=true is only available to internal users")), nil
+	}
 
-		complianceReq.Header.Add("accept", "application/json;charset=UTF-8")
-		complianceReq.Header.Add("Content-Type", "application/json;charset=UTF-8")
+	username := userIdentity.User.Username
+	if cached, ok := complianceCache.Get(username); ok {
+		complianceCacheLookups.WithLabelValues("hit").Inc()
+		return cached.response(debug, true), nil
+	}
+	complianceCacheLookups.WithLabelValues("miss").Inc()
+
+	reqBody := constructComplianceRequestBody(userIdentity)
 
-		resp, err := httpClient.Do(complianceReq)
-		if err != nil {
-			var urlError *u.Error
-			if errors.As(err, &urlError) && urlError.Timeout() {
-				failOnComplianceError(w, "Request to Export Compliance Service timed out", err, url)
-			} else {
-				failOnComplianceError(w, "Unexpected error returned on request to Export Compliance Service", err, url)
-			}
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request to compliance service: %w", err)
+	}
 
-			return
+	var httpClient = getClient()
+	configOptions := config.GetConfig().Options
+	url := configOptions.GetString(config.Keys.ComplianceHost) + configOptions.GetString(config.Keys.CompAPIBasePath)
+	complianceReq, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(reqBodyJson))
+
+	if err != nil {
+		return failOnComplianceError("Unexpected error while creating request to Export Compliance Service", err, url), nil
+	}
+
+	complianceReq.Header.Add("accept", "application/json;charset=UTF-8")
+	complianceReq.Header.Add("Content-Type", "application/json;charset=UTF-8")
+
+	resp, err := httpClient.Do(complianceReq)
+	if err != nil {
+		var urlError *u.Error
+		if errors.As(err, &urlError) && urlError.Timeout() {
+			return failOnComplianceError("Request to Export Compliance Service timed out", err, url), nil
 		}
+		return failOnComplianceError("Unexpected error returned on request to Export Compliance Service", err, url), nil
+	}
 
-		complianceTimeTaken := time.Since(start).Seconds()
-		l.Log.WithFields(logrus.Fields{"compliance_call_duration": complianceTimeTaken}).Info("compliance call complete")
-		complianceTimeHistogram.Observe(complianceTimeTaken)
+	complianceTimeTaken := time.Since(start).Seconds()
+	l.Log.WithFields(logrus.Fields{"compliance_call_duration": complianceTimeTaken}).Info("compliance call complete")
+	complianceTimeHistogram.Observe(complianceTimeTaken)
 
-		defer resp.Body.Close()
-		respBody, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return failOnComplianceError("Unable to read the response of the Export Compliance Service", err, url), nil
+	}
+
+	screened, err := normalizeScreening(resp.StatusCode, respBody)
+	if err != nil {
+		return failOnComplianceResponse(err, resp.StatusCode, respBody, url), nil
+	}
+	if screened.result.Result == api.UNKNOWN && screened.status == http.StatusOK {
+		l.Log.WithFields(logrus.Fields{"body": string(respBody), "url": url}).Warn("Export Compliance Service answered with an unknown result, the user is not allowed")
+		complianceFailure.WithLabelValues(complianceFailureUnknownResult).Inc()
+	}
+
+	cacheScreening(username, screened)
+	return screened.response(debug, false), nil
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(resp.StatusCode)
-		w.Write([]byte(respBody))
+// cacheScreening caches a user's screening result. OK and blocked results are cached for their own TTLs,
+// while rejected screenings and results we do not know are not cached so the next request screens the
+// user again.
+func cacheScreening(username string, screened screening) {
+	if screened.status != http.StatusOK {
+		return
 	}
+
+	var ttlSeconds int64
+	switch screened.result.Result {
+	case api.OK:
+		ttlSeconds = configOptions.GetInt64(config.Keys.ComplianceOKTTL)
+	case api.ERROREXPORTCONTROL, api.ERROROFAC, api.ERRORT5:
+		ttlSeconds = configOptions.GetInt64(config.Keys.ComplianceBlockedTTL)
+	}
+	if ttlSeconds <= 0 {
+		return
+	}
+
+	complianceCache.Set(username, screened, time.Duration(ttlSeconds)*time.Second)
+}
+
+// evictComplianceResult removes a user's cached screening result, returning whether there was one
+func evictComplianceResult(username string) (bool, error) {
+	return complianceCache.Delete(username)
 }
 
 func constructComplianceRequestBody(userIdentity identity.Identity) types.ComplianceScreeningRequest {
@@ -114,43 +192,54 @@ func constructComplianceRequestBody(userIdentity identity.Identity) types.Compli
 	return reqBody
 }
 
-func failOnBadRequest(w http.ResponseWriter, errMsg string, err error) {
+func failOnBadRequest(errMsg string, err error) complianceResponse {
 	sentry.CaptureException(err)
 	l.Log.WithFields(logrus.Fields{"error": err}).Error(errMsg)
 	complianceFailure.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Inc()
 
-	response := types.RequestErrorResponse{
-		Error: types.RequestErrorDetails{
-			Status:  http.StatusBadRequest,
-			Message: errMsg + ": " + err.Error(),
-		},
-	}
-
-	responseJson, _ := json.Marshal(response)
-	http.Error(w, string(responseJson), http.StatusBadRequest)
+	return complianceResponse{status: http.StatusBadRequest, body: requestError(http.StatusBadRequest, errMsg+": "+err.Error())}
 }
 
-func failOnComplianceError(w http.ResponseWriter, errMsg string, err error, url string) {
+func failOnComplianceError(errMsg string, err error, url string) api.GetCompliance500JSONResponse {
 	sentry.CaptureException(err)
 	l.Log.WithFields(logrus.Fields{"error": err}).Error(errMsg)
 	complianceFailure.WithLabelValues(strconv.Itoa(http.StatusInternalServerError)).Inc()
 
-	response := types.DependencyErrorResponse{
-		Error: types.DependencyErrorDetails{
-			DependencyFailure: true,
-			Service:           complianceServiceName,
-			Status:            http.StatusInternalServerError,
-			Endpoint:          url,
-			Message:           errMsg + ": " + err.Error(),
+	return api.GetCompliance500JSONResponse{
+		Error: &api.DependencyErrorDetails{
+			DependencyFailure: toPtr(true),
+			Service:           toPtr(complianceServiceName),
+			Status:            toPtr(http.StatusInternalServerError),
+			Endpoint:          toPtr(url),
+			Message:           toPtr(errMsg + ": " + err.Error()),
 		},
 	}
-
-	responseJson, _ := json.Marshal(response)
-	http.Error(w, string(responseJson), http.StatusInternalServerError)
 }
 
-func failOnServiceError(w http.ResponseWriter, errMsg string, err error) {
-	l.Log.WithFields(logrus.Fields{"error": err}).Error(errMsg)
-	sentry.CaptureException(err)
-	http.Error(w, http.StatusText(http.StatusInternalServerError)+": "+errMsg+": "+err.Error(), http.StatusInternalServerError)
+// failOnComplianceResponse answers a response of the Export Compliance Service we cannot give the caller,
+// see normalizeScreening, as a dependency failure with the status it answered with
+func failOnComplianceResponse(err error, status int, body []byte, url string) api.GetCompliance500JSONResponse {
+	label := strconv.Itoa(status)
+	if status >= 200 && status < 300 {
+		label = complianceFailureInvalidResponse
+	}
+
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("response_body", string(body))
+		scope.SetTag("response_status", strconv.Itoa(status))
+		scope.SetTag("url", url)
+		sentry.CaptureException(err)
+	})
+	l.Log.WithFields(logrus.Fields{"error": err, "status": status, "body": string(body), "url": url}).Error("Export Compliance Service answered with a response that cannot be used")
+	complianceFailure.WithLabelValues(label).Inc()
+
+	return api.GetCompliance500JSONResponse{
+		Error: &api.DependencyErrorDetails{
+			DependencyFailure: toPtr(true),
+			Service:           toPtr(complianceServiceName),
+			Status:            toPtr(status),
+			Endpoint:          toPtr(url),
+			Message:           toPtr(err.Error()),
+		},
+	}
 }
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const screeningKeyPrefix = "entitlements:compliance_screening:"
+
+var complianceCacheErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "it_export_compliance_service_cache_errors_total",
+		Help: "Total number of failed export compliance screening cache operations by operation",
+	},
+	[]string{"operation"},
+)
+
+// screeningCache holds the screening results of users by username. It uses the same backend as the
+// feature status caches, so with the redis backend every replica shares it and an eviction reaches all
+// of them.
+type screeningCache interface {
+	// Get returns the screening cached for a user, if there is one that has not expired
+	Get(username string) (screening, bool)
+	// Set caches a user's screening for ttl
+	Set(username string, screened screening, ttl time.Duration)
+	// Delete removes the screening cached for a user, returning whether there was one
+	Delete(username string) (bool, error)
+	// Backend is the name of the storage backend, one of the featurecache backends
+	Backend() string
+}
+
+// newScreeningCache constructs the screening cache with the backend selected by SUBS_CACHE_BACKEND
+func newScreeningCache() screeningCache {
+	local := newMemoryScreeningCache(
+		configOptions.GetInt64(config.Keys.ComplianceCacheMaxSize),
+		uint8(configOptions.GetUint32(config.Keys.ComplianceCacheItemPrune)),
+	)
+
+	switch backend := configOptions.GetString(config.Keys.SubsCacheBackend); backend {
+	case featurecache.BackendMemory, "":
+		return local
+	case featurecache.BackendRedis:
+		return &redisScreeningCache{client: featurecache.RedisClient(), local: local}
+	default:
+		panic(fmt.Sprintf("Error constructing compliance screening cache: unsupported backend [%s]", backend))
+	}
+}
+
+// memoryScreeningCache is a screeningCache local to this process
+type memoryScreeningCache struct {
+	cache *ccache.Cache[screening]
+}
+
+func newMemoryScreeningCache(maxSize int64, percentToPrune uint8) *memoryScreeningCache {
+	return &memoryScreeningCache{
+		cache: ccache.New(
+			ccache.Configure[screening]().
+				MaxSize(maxSize).
+				PercentToPrune(percentToPrune),
+		),
+	}
+}
+
+func (m *memoryScreeningCache) Get(username string) (screening, bool) {
+	item := m.cache.Get(username)
+	if item == nil || item.Expired() {
+		return screening{}, false
+	}
+	return item.Value(), true
+}
+
+func (m *memoryScreeningCache) Set(username string, screened screening, ttl time.Duration) {
+	m.cache.Set(username, screened, ttl)
+}
+
+func (m *memoryScreeningCache) Delete(username string) (bool, error) {
+	return m.cache.Delete(username), nil
+}
+
+func (m *memoryScreeningCache) Backend() string {
+	return featurecache.BackendMemory
+}
+
+// storedScreening is a screening as it is held in the shared store
+type storedScreening struct {
+	Status  int                  `json:"status"`
+	Result  api.ComplianceResult `json:"result"`
+	RawBody string               `json:"rawBody,omitempty"`
+}
+
+// redisScreeningCache is a screeningCache shared between replicas through the store of the feature
+// status caches. Screenings are also written to local memory, which answers lookups while the shared
+// store is unreachable.
+type redisScreeningCache struct {
+	client *redis.Client
+	local  *memoryScreeningCache
+}
+
+func (r *redisScreeningCache) logError(operation string, username string, err error) {
+	complianceCacheErrors.WithLabelValues(operation).Inc()
+	l.Log.WithFields(logrus.Fields{"error": err, "username": username, "operation": operation}).Warn("compliance screening cache operation failed")
+}
+
+func (r *redisScreeningCache) Get(username string) (screening, bool) {
+	data, err := r.client.Get(context.Background(), screeningKeyPrefix+username).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return screening{}, false
+	}
+	if err != nil {
+		r.logError("get", username, err)
+		return r.local.Get(username)
+	}
+
+	var stored storedScreening
+	if err = json.Unmarshal(data, &stored); err != nil {
+		r.logError("decode", username, err)
+		return screening{}, false
+	}
+	return screening{status: stored.Status, result: stored.Result, rawBody: stored.RawBody}, true
+}
+
+func (r *redisScreeningCache) Set(username string, screened screening, ttl time.Duration) {
+	r.local.Set(username, screened, ttl)
+
+	data, err := json.Marshal(storedScreening{Status: screened.status, Result: screened.result, RawBody: screened.rawBody})
+	if err != nil {
+		r.logError("encode", username, err)
+		return
+	}
+
+	if err = r.client.Set(context.Background(), screeningKeyPrefix+username, data, ttl).Err(); err != nil {
+		r.logError("set", username, err)
+	}
+}
+
+// Delete removes the screening from the shared store and from local memory. It fails when the shared
+// store is unreachable, as the other replicas would keep screening the user with it.
+func (r *redisScreeningCache) Delete(username string) (bool, error) {
+	deletedLocal, _ := r.local.Delete(username)
+
+	deleted, err := r.client.Del(context.Background(), screeningKeyPrefix+username).Result()
+	if err != nil {
+		r.logError("delete", username, err)
+		return deletedLocal, err
+	}
+	return deleted > 0 || deletedLocal, nil
+}
+
+func (r *redisScreeningCache) Backend() string {
+	return featurecache.BackendRedis
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+// useMemoryScreeningCache gives the spec an empty compliance screening cache of its own
+func useMemoryScreeningCache() *memoryScreeningCache {
+	screenings := newMemoryScreeningCache(100, 10)
+	DeferCleanup(func(previous screeningCache) { complianceCache = previous }, complianceCache)
+	complianceCache = screenings
+	return screenings
+}
+
+var _ = Describe("Compliance screening cache", func() {
+	const username = "test@redhat.com"
+	blocked := screening{
+		status:  http.StatusOK,
+		result:  api.ComplianceResult{Result: api.ERROROFAC, Allowed: false, Reasons: []string{"sanctioned"}},
+		rawBody: `{"result": "ERROR_OFAC"}`,
+	}
+
+	Describe("Redis", func() {
+		var server *miniredis.Miniredis
+		var client *redis.Client
+
+		BeforeEach(func() {
+			server = miniredis.RunT(GinkgoT())
+			client = redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+			DeferCleanup(client.Close)
+		})
+
+		newReplica := func() *redisScreeningCache {
+			return &redisScreeningCache{client: client, local: newMemoryScreeningCache(100, 10)}
+		}
+
+		It("should share screenings between replicas until they expire", func() {
+			// given
+			replica1, replica2 := newReplica(), newReplica()
+
+			// when
+			replica1.Set(username, blocked, time.Minute)
+
+			// then
+			cached, ok := replica2.Get(username)
+			Expect(ok).To(BeTrue())
+			Expect(cached).To(Equal(blocked))
+			Expect(server.TTL(screeningKeyPrefix + username)).To(Equal(time.Minute))
+		})
+
+		It("should evict a screening from every replica", func() {
+			// given
+			replica1, replica2 := newReplica(), newReplica()
+			replica1.Set(username, blocked, time.Minute)
+			replica2.Get(username)
+
+			// when
+			evicted, err := replica2.Delete(username)
+
+			// then
+			Expect(err).ToNot(HaveOccurred())
+			Expect(evicted).To(BeTrue())
+			_, ok := replica1.Get(username)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should answer from local memory while the shared store is unreachable", func() {
+			// given
+			c := newReplica()
+			c.Set(username, blocked, time.Minute)
+			server.Close()
+
+			// when
+			cached, ok := c.Get(username)
+
+			// then
+			Expect(ok).To(BeTrue())
+			Expect(cached).To(Equal(blocked))
+		})
+
+		It("should fail an eviction the other replicas would not see", func() {
+			// given
+			c := newReplica()
+			c.Set(username, blocked, time.Minute)
+			server.Close()
+
+			// when
+			_, err := c.Delete(username)
+
+			// then
+			Expect(err).To(HaveOccurred())
+			_, ok := c.Get(username)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	DescribeTable("should report whether an eviction reached every replica",
+		func(c screeningCache, scope api.AdminEvictionScope) {
+			Expect(evictionScope(c)).To(Equal(scope))
+		},
+		Entry("memory", newMemoryScreeningCache(10, 1), api.Replica),
+		Entry("redis", &redisScreeningCache{}, api.AllReplicas),
+	)
+})
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+)
+
+// Labels of it_export_compliance_service_failure for responses that did not fail with a status
+const (
+	complianceFailureInvalidResponse = "invalid_response"
+	complianceFailureUnknownResult   = "unknown_result"
+)
+
+// blockedReasons are the reasons given for a blocked result the Export Compliance Service did not describe
+var blockedReasons = map[api.ComplianceResultResult]string{
+	api.ERROREXPORTCONTROL: "The user's account is on export hold",
+	api.ERROROFAC:          "The user's email address indicates they are located in a country subject to OFAC sanctions",
+	api.ERRORT5:            "The user's address indicates they are located in a country subject to OFAC sanctions",
+}
+
+// screening is a user's screening normalized to a ComplianceResult, along with the status it is answered
+// with and the body the Export Compliance Service answered with
+type screening struct {
+	status  int
+	result  api.ComplianceResult
+	rawBody string
+}
+
+// response answers the screening, with the raw body when debug is set
+func (s screening) response(debug bool, cacheHit bool) complianceResponse {
+	result := s.result
+	if debug {
+		result.RawBody = toPtr(s.rawBody)
+	}
+	return complianceResponse{status: s.status, body: result, cacheHit: cacheHit}
+}
+
+// normalizeScreening turns a response of the Export Compliance Service into a screening by its status class:
+//   - 2xx is the user's result, answered with a 200. A result we do not know is answered as UNKNOWN and not
+//     allowed, as letting a user through because the service added a result would be unsafe.
+//   - 401, 403 and 429 mean the service refused us rather than the user, they are returned as errors.
+//   - Any other 4xx is a rejected screening of the user, answered with a 400 and the service's errors.
+//   - 5xx, and the 1xx and 3xx the http client does not follow, are returned as errors.
+//
+// A 2xx body that cannot be decoded is returned as an error too.
+func normalizeScreening(status int, body []byte) (screening, error) {
+	switch {
+	case status >= 200 && status < 300:
+		var upstream types.ComplianceScreeningResponse
+		if err := json.Unmarshal(body, &upstream); err != nil {
+			return screening{}, fmt.Errorf("export compliance service answered with a screening result that could not be decoded: %w", err)
+		}
+		return screening{status: http.StatusOK, result: screeningResult(upstream), rawBody: string(body)}, nil
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusTooManyRequests:
+		return screening{}, fmt.Errorf("export compliance service refused the screening with http status code [%d]", status)
+	case status >= 400 && status < 500:
+		return screening{status: http.StatusBadRequest, result: rejectedScreening(status, body), rawBody: string(body)}, nil
+	default:
+		return screening{}, fmt.Errorf("export compliance service failed with http status code [%d]", status)
+	}
+}
+
+// screeningResult normalizes a screening result. Its description is the reason for the result, blocked
+// results without one are given a reason of our own.
+func screeningResult(upstream types.ComplianceScreeningResponse) api.ComplianceResult {
+	result := api.ComplianceResult{
+		Result:         api.ComplianceResultResult(upstream.Result),
+		Reasons:        []string{},
+		UpstreamErrors: []api.ComplianceScreeningError{},
+	}
+
+	switch result.Result {
+	case api.OK:
+		result.Allowed = true
+	case api.ERROREXPORTCONTROL, api.ERROROFAC, api.ERRORT5:
+		if upstream.Description == "" {
+			result.Reasons = append(result.Reasons, blockedReasons[result.Result])
+		}
+	default:
+		result.Result = api.UNKNOWN
+		result.Reasons = append(result.Reasons, fmt.Sprintf("The Export Compliance Service answered with a result that is not known: %q", upstream.Result))
+	}
+
+	if upstream.Description != "" {
+		result.Reasons = append(result.Reasons, upstream.Description)
+	}
+	return result
+}
+
+// rejectedScreening normalizes a screening the Export Compliance Service rejected. The user is not
+// allowed, and the errors it answered with are the reasons.
+func rejectedScreening(status int, body []byte) api.ComplianceResult {
+	result := api.ComplianceResult{
+		Result:         api.UNKNOWN,
+		Reasons:        []string{},
+		UpstreamErrors: []api.ComplianceScreeningError{},
+	}
+
+	var upstream types.ComplianceScreeningErrorResponse
+	if err := json.Unmarshal(body, &upstream); err == nil {
+		for _, upstreamErr := range upstream.Errors {
+			result.UpstreamErrors = append(result.UpstreamErrors, api.ComplianceScreeningError{
+				Error:        upstreamErr.Error,
+				IdentityType: upstreamErr.IdentityType,
+				Identity:     upstreamErr.Identity,
+			})
+			if upstreamErr.Error != "" {
+				result.Reasons = append(result.Reasons, upstreamErr.Error)
+			}
+		}
+	}
+
+	if len(result.Reasons) == 0 {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("The Export Compliance Service rejected the screening with http status code [%d]", status))
+	}
+	return result
+}
@@ -8,10 +8,12 @@ import (
 	"net/http/httptest"
 	"time"
 
+	"github.com/RedHatInsights/entitlements-api-go/api"
 	"github.com/RedHatInsights/entitlements-api-go/config"
 	"github.com/RedHatInsights/entitlements-api-go/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
 )
 
@@ -59,16 +61,39 @@ func getContextWithServiceAccount() context.Context {
 	return ctx
 }
 
+// screeningServer answers every screening with status and body, counting the screenings it received
+func screeningServer(status int, body string) (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	DeferCleanup(server.Close)
+	config.GetConfig().Options.Set(config.Keys.ComplianceHost, server.URL)
+	return server, &calls
+}
+
+func screen(username string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
+	return serveApi(req.WithContext(getContextWithIdentity(username)))
+}
+
 var _ = Describe("Compliance Controller", func() {
+	var screenings *memoryScreeningCache
+
+	BeforeEach(func() {
+		screenings = useMemoryScreeningCache()
+	})
+
 	Context("When username is empty", func() {
 		It("should return an error and status 400", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity(""))
-			rr := httptest.NewRecorder()
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusBadRequest))
@@ -87,12 +112,11 @@ var _ = Describe("Compliance Controller", func() {
 	Context("When username is whitespace", func() {
 		It("should return an error and status 400", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity("           "))
-			rr := httptest.NewRecorder()
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusBadRequest))
@@ -112,12 +136,11 @@ var _ = Describe("Compliance Controller", func() {
 		It("should return an error and status 500", func() {
 			// given
 			config.GetConfig().Options.Set(config.Keys.ComplianceHost, "bad url that will cause an error in http.NewRequest\n")
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity(defaultEmail))
-			rr := httptest.NewRecorder()
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusInternalServerError))
@@ -138,15 +161,14 @@ var _ = Describe("Compliance Controller", func() {
 	Context("When the request to compliance service fails", func() {
 		It("should return an error and status 500", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity(defaultEmail))
-			rr := httptest.NewRecorder()
 
 			server := httptest.NewUnstartedServer(http.NotFoundHandler())
 			config.GetConfig().Options.Set(config.Keys.ComplianceHost, server.URL)
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusInternalServerError))
@@ -167,9 +189,8 @@ var _ = Describe("Compliance Controller", func() {
 	Context("When the request to compliance service fails due to timeout", func() {
 		It("should return a specific error and status 500", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity(defaultEmail))
-			rr := httptest.NewRecorder()
 
 			cfg := config.GetConfig().Options
 			wait := cfg.GetInt(config.Keys.ITServicesTimeoutSeconds) + 1
@@ -183,7 +204,7 @@ var _ = Describe("Compliance Controller", func() {
 			cfg.Set(config.Keys.ComplianceHost, server.URL)
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusInternalServerError))
@@ -202,11 +223,10 @@ var _ = Describe("Compliance Controller", func() {
 	})
 
 	Context("When the request to compliance service is successful", func() {
-		It("should return a body and successful status code", func() {
+		It("should return an allowed OK result and successful status code", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity(defaultEmail))
-			rr := httptest.NewRecorder()
 
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 				if req.URL.Path == config.GetConfig().Options.GetString(config.Keys.CompAPIBasePath) {
@@ -222,27 +242,29 @@ var _ = Describe("Compliance Controller", func() {
 			config.GetConfig().Options.Set(config.Keys.ComplianceHost, server.URL)
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusOK))
 			resp := readResponse(rr.Result().Body)
 
-			var response types.ComplianceScreeningResponse
+			var response api.ComplianceResult
 			err := json.Unmarshal(resp, &response)
 			Expect(err).To(BeNil(), "Error unmarshalling server response")
 
-			Expect(response.Result).To(Equal("OK"))
-			Expect(response.Description).To(Equal(""))
+			Expect(response.Allowed).To(BeTrue())
+			Expect(response.Result).To(Equal(api.OK))
+			Expect(response.Reasons).To(BeEmpty())
+			Expect(response.UpstreamErrors).To(BeEmpty())
+			Expect(response.RawBody).To(BeNil())
 		})
 	})
 
 	Context("When the request to compliance service is successful and error from compliance", func() {
-		It("should return a body and appropriate status code", func() {
+		It("should return a rejected result and status 400", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithIdentity(defaultEmail))
-			rr := httptest.NewRecorder()
 
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 				if req.URL.Path == config.GetConfig().Options.GetString(config.Keys.CompAPIBasePath) {
@@ -263,21 +285,25 @@ var _ = Describe("Compliance Controller", func() {
 			config.GetConfig().Options.Set(config.Keys.ComplianceHost, server.URL)
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusBadRequest))
 			resp := readResponse(rr.Result().Body)
 
-			var response types.ComplianceScreeningErrorResponse
+			var response api.ComplianceResult
 			err := json.Unmarshal(resp, &response)
 			Expect(err).To(BeNil(), "Error unmarshalling server response")
 
-			Expect(response.Errors).To(HaveLen(1))
-			Expect(response.Errors).To(ContainElement(types.ComplianceScreeningError{
-				Error:        "no_such_user",
-				IdentityType: "login",
-				Identity:     defaultEmail,
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result).To(Equal(api.UNKNOWN))
+			Expect(response.Reasons).To(Equal([]string{"no_such_user"}))
+			Expect(response.UpstreamErrors).To(Equal([]api.ComplianceScreeningError{
+				{
+					Error:        "no_such_user",
+					IdentityType: "login",
+					Identity:     defaultEmail,
+				},
 			}))
 		})
 	})
@@ -285,12 +311,11 @@ var _ = Describe("Compliance Controller", func() {
 	Context("When identity is a Service Account", func() {
 		It("should reject Service Account with 400 and clear error message", func() {
 			// given
-			req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			req := httptest.NewRequest(http.MethodGet, "/compliance", nil)
 			req = req.WithContext(getContextWithServiceAccount())
-			rr := httptest.NewRecorder()
 
 			// when
-			Compliance()(rr, req)
+			rr := serveApi(req)
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(http.StatusBadRequest))
@@ -305,4 +330,242 @@ var _ = Describe("Compliance Controller", func() {
 			Expect(errorResp.Error.Status).To(Equal(http.StatusBadRequest))
 		})
 	})
+
+	Context("When the screening is normalized", func() {
+		DescribeTable("should answer a screening result",
+			func(body string, allowed bool, result api.ComplianceResultResult, reasons []string) {
+				// given
+				screeningServer(http.StatusOK, body)
+
+				// when
+				rr := screen(defaultEmail)
+
+				// then
+				Expect(rr.Result().StatusCode).To(Equal(http.StatusOK))
+				var response api.ComplianceResult
+				Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Allowed).To(Equal(allowed))
+				Expect(response.Result).To(Equal(result))
+				Expect(response.Reasons).To(Equal(reasons))
+			},
+			Entry("OK", `{"result": "OK", "description": ""}`, true, api.OK, []string{}),
+			Entry("blocked with a description", `{"result": "ERROR_EXPORT_CONTROL", "description": "on hold"}`,
+				false, api.ERROREXPORTCONTROL, []string{"on hold"}),
+			Entry("blocked without a description", `{"result": "ERROR_T5", "description": ""}`,
+				false, api.ERRORT5, []string{blockedReasons[api.ERRORT5]}),
+			Entry("a result we do not know", `{"result": "PENDING", "description": ""}`,
+				false, api.UNKNOWN, []string{`The Export Compliance Service answered with a result that is not known: "PENDING"`}),
+		)
+
+		It("should count a result we do not know", func() {
+			// given
+			screeningServer(http.StatusOK, `{"result": "PENDING", "description": ""}`)
+			before := testutil.ToFloat64(complianceFailure.WithLabelValues(complianceFailureUnknownResult))
+
+			// when
+			screen(defaultEmail)
+
+			// then
+			Expect(testutil.ToFloat64(complianceFailure.WithLabelValues(complianceFailureUnknownResult))).To(Equal(before + 1))
+		})
+
+		DescribeTable("should answer a dependency failure with the upstream status",
+			func(status int, body string, label string) {
+				// given
+				screeningServer(status, body)
+				before := testutil.ToFloat64(complianceFailure.WithLabelValues(label))
+
+				// when
+				rr := screen(defaultEmail)
+
+				// then
+				Expect(rr.Result().StatusCode).To(Equal(http.StatusInternalServerError))
+				var errorResp types.DependencyErrorResponse
+				Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+				Expect(errorResp.Error.DependencyFailure).To(BeTrue())
+				Expect(errorResp.Error.Service).To(Equal(complianceServiceName))
+				Expect(errorResp.Error.Status).To(Equal(status))
+				Expect(testutil.ToFloat64(complianceFailure.WithLabelValues(label))).To(Equal(before + 1))
+			},
+			Entry("unauthorized", http.StatusUnauthorized, "", "401"),
+			Entry("forbidden", http.StatusForbidden, "", "403"),
+			Entry("too many requests", http.StatusTooManyRequests, "", "429"),
+			Entry("a server error", http.StatusBadGateway, "upstream is down", "502"),
+			Entry("a body that cannot be decoded", http.StatusOK, `{"result": `, complianceFailureInvalidResponse),
+		)
+
+		It("should answer a rejected screening without errors with a reason of its own", func() {
+			// given
+			screeningServer(http.StatusNotFound, "not found")
+
+			// when
+			rr := screen(defaultEmail)
+
+			// then
+			Expect(rr.Result().StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(rr.Body.String()).To(MatchJSON(`{
+				"allowed": false,
+				"result": "UNKNOWN",
+				"reasons": ["The Export Compliance Service rejected the screening with http status code [404]"],
+				"upstream_errors": []
+			}`))
+		})
+	})
+
+	Context("When debug is requested", func() {
+		debugRequest := func(internal bool) *httptest.ResponseRecorder {
+			ctx := identity.WithIdentity(context.Background(), identity.XRHID{
+				Identity: identity.Identity{
+					AccountNumber: "540155",
+					User:          &identity.User{Username: defaultEmail, Internal: internal},
+				},
+			})
+			req := httptest.NewRequest(http.MethodGet, "/compliance?debug=true", nil)
+			return serveApi(req.WithContext(ctx))
+		}
+
+		It("should answer an internal user with the raw body", func() {
+			// given
+			screeningServer(http.StatusOK, `{"result": "OK", "description": ""}`)
+
+			// when
+			rr := debugRequest(true)
+
+			// then
+			Expect(rr.Result().StatusCode).To(Equal(http.StatusOK))
+			var response api.ComplianceResult
+			Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.RawBody).ToNot(BeNil())
+			Expect(*response.RawBody).To(Equal(`{"result": "OK", "description": ""}`))
+		})
+
+		It("should answer the raw body of a cached result", func() {
+			// given
+			_, calls := screeningServer(http.StatusOK, `{"result": "OK", "description": ""}`)
+			screen(defaultEmail)
+
+			// when
+			rr := debugRequest(true)
+
+			// then
+			Expect(*calls).To(Equal(1))
+			Expect(rr.Header().Get("X-Entitlements-Cached")).To(Equal("true"))
+			Expect(rr.Body.String()).To(ContainSubstring(`"raw_body"`))
+		})
+
+		It("should forbid a user that is not internal", func() {
+			// given
+			_, calls := screeningServer(http.StatusOK, `{"result": "OK", "description": ""}`)
+
+			// when
+			rr := debugRequest(false)
+
+			// then
+			Expect(rr.Result().StatusCode).To(Equal(http.StatusForbidden))
+			Expect(*calls).To(Equal(0))
+			var errorResp types.RequestErrorResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+			Expect(errorResp.Error.Message).To(ContainSubstring("only available to internal users"))
+		})
+	})
+
+	Context("When screening results are cached", func() {
+		It("should answer an OK result from the cache until it expires", func() {
+			// given
+			_, calls := screeningServer(http.StatusOK, `{"result": "OK", "description": ""}`)
+			hits := testutil.ToFloat64(complianceCacheLookups.WithLabelValues("hit"))
+			misses := testutil.ToFloat64(complianceCacheLookups.WithLabelValues("miss"))
+
+			// when
+			first := screen(defaultEmail)
+			second := screen(defaultEmail)
+
+			// then
+			Expect(*calls).To(Equal(1))
+			Expect(first.Header().Get("X-Entitlements-Cached")).To(BeEmpty())
+			Expect(second.Result().StatusCode).To(Equal(http.StatusOK))
+			Expect(second.Header().Get("X-Entitlements-Cached")).To(Equal("true"))
+			Expect(second.Body.String()).To(MatchJSON(`{"allowed": true, "result": "OK", "reasons": [], "upstream_errors": []}`))
+			Expect(screenings.cache.Get(defaultEmail).TTL()).To(BeNumerically("~",
+				time.Duration(config.GetConfig().Options.GetInt64(config.Keys.ComplianceOKTTL))*time.Second, time.Second))
+			Expect(testutil.ToFloat64(complianceCacheLookups.WithLabelValues("hit"))).To(Equal(hits + 1))
+			Expect(testutil.ToFloat64(complianceCacheLookups.WithLabelValues("miss"))).To(Equal(misses + 1))
+		})
+
+		It("should cache a blocked result for the blocked TTL", func() {
+			// given
+			config.GetConfig().Options.Set(config.Keys.ComplianceBlockedTTL, 42)
+			DeferCleanup(config.GetConfig().Options.Set, config.Keys.ComplianceBlockedTTL, 300)
+			_, calls := screeningServer(http.StatusOK, `{"result": "ERROR_OFAC", "description": "sanctioned"}`)
+
+			// when
+			screen(defaultEmail)
+			rr := screen(defaultEmail)
+
+			// then
+			Expect(*calls).To(Equal(1))
+			Expect(rr.Header().Get("X-Entitlements-Cached")).To(Equal("true"))
+			Expect(screenings.cache.Get(defaultEmail).TTL()).To(BeNumerically("~", 42*time.Second, time.Second))
+		})
+
+		It("should cache results per user", func() {
+			// given
+			_, calls := screeningServer(http.StatusOK, `{"result": "OK", "description": ""}`)
+
+			// when
+			screen(defaultEmail)
+			rr := screen("other@redhat.com")
+
+			// then
+			Expect(*calls).To(Equal(2))
+			Expect(rr.Header().Get("X-Entitlements-Cached")).To(BeEmpty())
+		})
+
+		DescribeTable("should not cache",
+			func(status int, body string, expectedStatus int) {
+				// given
+				_, calls := screeningServer(status, body)
+
+				// when
+				screen(defaultEmail)
+				rr := screen(defaultEmail)
+
+				// then
+				Expect(*calls).To(Equal(2))
+				Expect(rr.Result().StatusCode).To(Equal(expectedStatus))
+				Expect(rr.Header().Get("X-Entitlements-Cached")).To(BeEmpty())
+			},
+			Entry("an error response", http.StatusBadRequest, `{"errors": [{"error": "no_such_user", "identityType": "login"}]}`, http.StatusBadRequest),
+			Entry("a server error", http.StatusInternalServerError, "upstream is down", http.StatusInternalServerError),
+			Entry("a result we do not know", http.StatusOK, `{"result": "PENDING", "description": ""}`, http.StatusOK),
+			Entry("a body that cannot be decoded", http.StatusOK, `{"result": `, http.StatusInternalServerError),
+		)
+
+		It("should not cache a result whose TTL is 0", func() {
+			// given
+			config.GetConfig().Options.Set(config.Keys.ComplianceOKTTL, 0)
+			DeferCleanup(config.GetConfig().Options.Set, config.Keys.ComplianceOKTTL, 900)
+			_, calls := screeningServer(http.StatusOK, `{"result": "OK", "description": ""}`)
+
+			// when
+			screen(defaultEmail)
+			screen(defaultEmail)
+
+			// then
+			Expect(*calls).To(Equal(2))
+		})
+
+		It("should not cache a screening that failed", func() {
+			// given
+			server := httptest.NewUnstartedServer(http.NotFoundHandler())
+			config.GetConfig().Options.Set(config.Keys.ComplianceHost, server.URL)
+
+			// when
+			screen(defaultEmail)
+
+			// then
+			_, cached := complianceCache.Get(defaultEmail)
+			Expect(cached).To(BeFalse())
+		})
+	})
 })
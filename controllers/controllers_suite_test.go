@@ -1,10 +1,14 @@
 package controllers
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
 	. "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/go-chi/chi/v5"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -18,3 +22,15 @@ func TestControllers(t *testing.T) {
 var _ = BeforeSuite(func() {
 	config.GetConfig().Options.Set(config.Keys.ITServicesTimeoutSeconds, 2)
 })
+
+// background refreshes read the config and caches, they must not outlive the spec that started them
+var _ = AfterEach(waitForBackgroundRefreshes)
+
+// serveApi serves req with the generated server, as server.DoRoutes does without the base URL. The
+// Feature Service client is built for every request as tests point ENT_SUBS_HOST at their own server.
+func serveApi(req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	features := featureservice.NewClient()
+	NewApiHandler(NewApiServer(nil, NewServicesBatchApi(features), NewServicesApi(features)), chi.NewRouter(), "").ServeHTTP(rr, req)
+	return rr
+}
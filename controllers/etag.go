@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var servicesNotModified = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "entitlements_services_not_modified_total",
+	Help: "Total number of /services requests answered with a 304 because the caller's ETag was current",
+})
+
+// entitlementsETag identifies a /services response by the bundle config it was decided with and its
+// body. Maps are marshalled with sorted keys, so the same entitlements always get the same ETag.
+func entitlementsETag(body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(getBundleState().hash))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. ETags are compared weakly, as
+// If-None-Match requires.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl lets clients use a response for as long as the org's feature status stays cached, and
+// revalidate it with its ETag after. Entitlements change on trial activations, override changes and
+// bundle reloads without the org's cache expiring, so it is capped by ENT_SERVICES_MAX_AGE_SECONDS.
+func cacheControl(expiresAt time.Time) string {
+	maxAge := time.Second * time.Duration(configOptions.GetInt64(config.Keys.ServicesMaxAge))
+	maxAge = max(min(time.Until(expiresAt), maxAge), 0)
+	return fmt.Sprintf("private, max-age=%d", int64(maxAge/time.Second))
+}
+
+// servicesResponse is a /services response, answered with a 304 when the caller already has it
+type servicesResponse struct {
+	entitlementsResponse
+	ifNoneMatch string
+}
+
+func (response servicesResponse) VisitGetServicesResponse(w http.ResponseWriter) error {
+	response.setHeaders(w)
+	if notModified(w, response.ifNoneMatch, response.body, response.degraded, response.subscriptions.ExpiresAt) {
+		return nil
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(response.body)
+	return err
+}
+
+// notModified sets the caching headers of a /services response and answers with a 304 when the
+// caller already has it. Responses vary by the identity they were decided for. Degraded responses are
+// never cached by clients, so they keep asking until the Feature Service recovers.
+func notModified(w http.ResponseWriter, ifNoneMatch string, body []byte, degraded bool, expiresAt time.Time) bool {
+	w.Header().Set("Vary", "X-Rh-Identity")
+	if degraded {
+		w.Header().Set("Cache-Control", "no-store")
+		return false
+	}
+
+	etag := entitlementsETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl(expiresAt))
+
+	if !etagMatches(ifNoneMatch, etag) {
+		return false
+	}
+
+	servicesNotModified.Inc()
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+)
+
+func conditionalRequest(ifNoneMatch string, response FeatureResponse) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/services", nil)
+	Expect(err).To(BeNil(), "NewRequest error was not nil")
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	req = req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{
+		Identity: identity.Identity{
+			AccountNumber: DEFAULT_ACCOUNT_NUMBER,
+			User:          &identity.User{Email: DEFAULT_EMAIL},
+			Internal:      identity.Internal{OrgID: DEFAULT_ORG_ID},
+		},
+	}))
+
+	GetFeatureStatus = fakeGetFeatureStatus(DEFAULT_ORG_ID, response)
+	return serveApi(req)
+}
+
+var _ = Describe("Conditional /services requests", func() {
+	var cached FeatureResponse
+
+	BeforeEach(func() {
+		storeBundleInfo([]Bundle{}, "")
+		Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		cached = FeatureResponse{
+			StatusCode: 200,
+			Data:       FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+			CacheHit:   true,
+			Outcome:    FeatureOutcomeSuccess,
+			ExpiresAt:  time.Now().Add(30*time.Second + 500*time.Millisecond),
+		}
+		DeferCleanup(func() { GetFeatureStatus = realGetFeatureStatus })
+	})
+
+	It("should let clients keep the response per identity for the rest of the org's cache lifetime", func() {
+		// when
+		rr := conditionalRequest("", cached)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Header().Get("ETag")).To(MatchRegexp(`^"[0-9a-f]{32}"$`))
+		Expect(rr.Header().Get("Cache-Control")).To(Equal("private, max-age=30"))
+		Expect(rr.Header().Get("Vary")).To(Equal("X-Rh-Identity"))
+	})
+
+	It("should cap max-age so trial, override and bundle config changes reach clients", func() {
+		// given
+		cached.ExpiresAt = time.Now().Add(time.Hour)
+		configOptions.Set(config.Keys.ServicesMaxAge, 10)
+		DeferCleanup(configOptions.Set, config.Keys.ServicesMaxAge, 60)
+
+		// when
+		rr := conditionalRequest("", cached)
+
+		// then
+		Expect(rr.Header().Get("Cache-Control")).To(Equal("private, max-age=10"))
+	})
+
+	It("should make clients revalidate responses that were not cached", func() {
+		// given
+		cached.ExpiresAt = time.Time{}
+
+		// when
+		rr := conditionalRequest("", cached)
+
+		// then
+		Expect(rr.Header().Get("Cache-Control")).To(Equal("private, max-age=0"))
+	})
+
+	It("should return the same ETag for the same entitlements", func() {
+		first := conditionalRequest("", cached)
+		cached.CacheHit = false
+		second := conditionalRequest("", cached)
+
+		Expect(second.Header().Get("ETag")).To(Equal(first.Header().Get("ETag")))
+	})
+
+	It("should return 304 when the caller has the current ETag", func() {
+		// given
+		etag := conditionalRequest("", cached).Header().Get("ETag")
+
+		// when
+		rr := conditionalRequest(`"other", W/`+etag, cached)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusNotModified))
+		Expect(rr.Body.Len()).To(BeZero())
+		Expect(rr.Header().Get("ETag")).To(Equal(etag))
+	})
+
+	It("should change the ETag when the entitlements change", func() {
+		// given
+		etag := conditionalRequest("", cached).Header().Get("ETag")
+		cached.Data = FeatureStatus{}
+
+		// when
+		rr := conditionalRequest(etag, cached)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Header().Get("ETag")).NotTo(Equal(etag))
+	})
+
+	It("should change the ETag when the bundle config changes", func() {
+		// given
+		etag := conditionalRequest("", cached).Header().Get("ETag")
+		bundles := getBundleState().bundles
+		Expect(storeBundleInfo(bundles, "another-version")).To(Succeed())
+
+		// when
+		rr := conditionalRequest(etag, cached)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should never let clients cache degraded responses", func() {
+		// given
+		etag := conditionalRequest("", cached).Header().Get("ETag")
+		cached.Stale = true
+
+		// when
+		rr := conditionalRequest(etag, cached)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Header().Get("Cache-Control")).To(Equal("no-store"))
+		Expect(rr.Header().Get("Vary")).To(Equal("X-Rh-Identity"))
+		Expect(rr.Header().Get("ETag")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/events"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+)
+
+// eventPublishTimeout bounds how long a publish may take, events are published off the request path
+const eventPublishTimeout = 10 * time.Second
+
+var eventPublisher atomic.Pointer[events.Publisher]
+
+// SetEventPublisher sets where entitlement change events are published, they are dropped until it is called
+func SetEventPublisher(publisher events.Publisher) {
+	eventPublisher.Store(&publisher)
+}
+
+// NewKafkaEventPublisher returns a publisher for the brokers and topic configured by ENT_EVENTS_*.
+// The last change published for each org is shared between replicas through the store of the redis
+// feature status cache backend, and kept by each replica otherwise.
+func NewKafkaEventPublisher() (events.Publisher, error) {
+	var brokers []string
+	for _, broker := range strings.Split(configOptions.GetString(config.Keys.EventsKafkaBrokers), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+
+	writer, err := events.NewKafkaWriter(events.KafkaWriterConfig{
+		Brokers:       brokers,
+		CACert:        configOptions.GetString(config.Keys.EventsKafkaCACert),
+		SASLMechanism: configOptions.GetString(config.Keys.EventsKafkaSASLMechanism),
+		Username:      configOptions.GetString(config.Keys.EventsKafkaUsername),
+		Password:      configOptions.GetString(config.Keys.EventsKafkaPassword),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error configuring the entitlement change events writer: %w", err)
+	}
+
+	var dedupe events.Dedupe = events.NewMemoryDedupe()
+	if configOptions.GetString(config.Keys.SubsCacheBackend) == featurecache.BackendRedis {
+		dedupe = events.NewRedisDedupe(featurecache.RedisClient())
+	}
+
+	window := time.Second * time.Duration(configOptions.GetInt64(config.Keys.EventsDedupeWindow))
+	return events.NewKafka(writer, configOptions.GetString(config.Keys.EventsTopic), dedupe, window), nil
+}
+
+// getEventPublisher returns the configured event publisher, never nil
+func getEventPublisher() events.Publisher {
+	if publisher := eventPublisher.Load(); publisher != nil {
+		return *publisher
+	}
+	return events.Noop{}
+}
+
+// previousFeatureStatus returns the last successful feature status of an org that is still cached, if
+// any. A status requested for another feature set, before a bundle config reload changed the features,
+// is not returned: the features it lacks would show up as changes of the org.
+func previousFeatureStatus(orgID string, featureSet string) *types.FeatureStatus {
+	if entry := cache.Get(orgID); entry != nil && entry.LookupOutcome() == types.FeatureOutcomeSuccess {
+		return requestedFor(entry, featureSet)
+	}
+	if entry := lastKnownGood.Get(orgID); entry != nil {
+		return requestedFor(entry, featureSet)
+	}
+	return nil
+}
+
+func requestedFor(entry *featurecache.Entry, featureSet string) *types.FeatureStatus {
+	if entry.FeatureSet != featureSet {
+		return nil
+	}
+	return &entry.Status
+}
+
+// orgBundleStates decides every bundle for an org from its feature status, like /services/batch does
+func orgBundleStates(orgID string, status types.FeatureStatus, at time.Time) map[string]events.BundleState {
+	subscriptions := types.FeatureResponse{StatusCode: 200, Data: status, Outcome: types.FeatureOutcomeSuccess}
+	states := make(map[string]events.BundleState)
+	for name, section := range evaluateBundles(orgID, orgInputs(orgID, subscriptions, false, at), nil, nil, false) {
+		states[name] = events.BundleState{IsEntitled: section.IsEntitled, IsTrial: section.IsTrial}
+	}
+	return states
+}
+
+// publishEntitlementChanges publishes an org_entitlements_changed event in the background when the
+// bundles of an org differ between its previous and fresh feature status. Nothing is published the
+// first time an org is seen, there is nothing to compare against.
+func publishEntitlementChanges(orgID string, previous *types.FeatureStatus, fresh types.FeatureStatus) {
+	if previous == nil {
+		return
+	}
+
+	at := now()
+	changes := events.Changes(orgBundleStates(orgID, *previous, at), orgBundleStates(orgID, fresh, at))
+	if len(changes) == 0 {
+		return
+	}
+
+	event := events.NewOrgEntitlementsChanged(orgID, changes, at)
+	publisher := getEventPublisher()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+		defer cancel()
+
+		if err := publisher.Publish(ctx, event); err != nil {
+			l.Log.WithFields(logrus.Fields{"error": err, "org_id": orgID, "event_id": event.ID}).Error("Error publishing entitlement change event")
+			sentry.CaptureException(err)
+		}
+	}()
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/events"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func (p *recordingPublisher) published() []events.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]events.Event(nil), p.events...)
+}
+
+var _ = Describe("Entitlement change events", func() {
+	var subsServer *ghttp.Server
+	var publisher *recordingPublisher
+
+	BeforeEach(func() {
+		GetFeatureStatus = realGetFeatureStatus
+		configOptions.Set(config.Keys.Features, "TestBundle1,TestBundle2")
+		DeferCleanup(configOptions.Set, config.Keys.Features, "")
+		storeBundleInfo([]Bundle{}, "")
+		Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		cache.Clear()
+		lastKnownGood.Clear()
+
+		subsServer = ghttp.NewServer()
+		subsServer.Writer = GinkgoWriter
+		configOptions.SetDefault(config.Keys.SubsHost, subsServer.URL())
+		DeferCleanup(subsServer.Close)
+
+		publisher = &recordingPublisher{}
+		SetEventPublisher(publisher)
+		DeferCleanup(SetEventPublisher, events.Publisher(events.Noop{}))
+	})
+
+	respondWith := func(body string) {
+		subsServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, body))
+	}
+
+	It("should publish the bundles that changed since the org's previous feature status", func() {
+		// given
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{
+			Status:     FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+			Outcome:    FeatureOutcomeSuccess,
+			FeatureSet: getBundleState().featureSet,
+		}, time.Hour)
+		respondWith(`{"features": [{"name":"TestBundle2"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+		// then
+		Eventually(publisher.published).Should(HaveLen(1))
+		event := publisher.published()[0]
+		Expect(event.Type).To(Equal(events.TypeOrgEntitlementsChanged))
+		Expect(event.OrgID).To(Equal(DEFAULT_ORG_ID))
+		Expect(event.Bundles).To(Equal(map[string]events.BundleChange{
+			"TestBundle1": {Before: &events.BundleState{IsEntitled: true}, After: &events.BundleState{}},
+			"TestBundle2": {Before: &events.BundleState{}, After: &events.BundleState{IsEntitled: true}},
+		}))
+	})
+
+	It("should decide bundles with rules about the user for a user who meets them", func() {
+		// given
+		Expect(storeBundleInfo([]Bundle{
+			{Name: "TestBundle1", Skus: []string{"SVC123"}, UseValidAccNum: true},
+			{Name: "TestBundle2", Skus: []string{"MCT1122"}, UseIsInternal: true},
+		}, "")).To(Succeed())
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{Status: FeatureStatus{}, Outcome: FeatureOutcomeSuccess, FeatureSet: getBundleState().featureSet}, time.Hour)
+		respondWith(`{"features": [{"name":"TestBundle1"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+		// then
+		Eventually(publisher.published).Should(HaveLen(1))
+		Expect(publisher.published()[0].Bundles).To(Equal(map[string]events.BundleChange{
+			"TestBundle1": {Before: &events.BundleState{}, After: &events.BundleState{IsEntitled: true}},
+		}))
+	})
+
+	It("should compare against the last known good feature status once the cached one expired", func() {
+		// given
+		lastKnownGood.Set(DEFAULT_ORG_ID, featurecache.Entry{Status: FeatureStatus{}, Outcome: FeatureOutcomeSuccess, FeatureSet: getBundleState().featureSet}, time.Hour)
+		respondWith(`{"features": [{"name":"TestBundle1"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID})
+
+		// then
+		Eventually(publisher.published).Should(HaveLen(1))
+		Expect(publisher.published()[0].Bundles).To(HaveKey("TestBundle1"))
+	})
+
+	It("should not compare against a feature status requested before the bundle config changed", func() {
+		// given
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{
+			Status:     FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+			Outcome:    FeatureOutcomeSuccess,
+			FeatureSet: getBundleState().featureSet,
+		}, time.Hour)
+		configOptions.Set(config.Keys.Features, "TestBundle1,TestBundle2,NewBundle")
+		bundles := append(getBundleState().bundles, Bundle{Name: "NewBundle", Skus: []string{"SVC999"}})
+		Expect(storeBundleInfo(bundles, "reloaded")).To(Succeed())
+		respondWith(`{"features": [{"name":"TestBundle1"}, {"name":"NewBundle"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+		// then
+		Consistently(publisher.published, 50*time.Millisecond).Should(BeEmpty())
+	})
+
+	It("should not publish when the bundles did not change", func() {
+		// given
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{
+			Status:     FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+			Outcome:    FeatureOutcomeSuccess,
+			FeatureSet: getBundleState().featureSet,
+		}, time.Hour)
+		respondWith(`{"features": [{"name":"TestBundle1"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+		// then
+		Consistently(publisher.published, 50*time.Millisecond).Should(BeEmpty())
+	})
+
+	It("should not publish the first time an org is seen", func() {
+		// given
+		respondWith(`{"features": [{"name":"TestBundle1"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID})
+
+		// then
+		Consistently(publisher.published, 50*time.Millisecond).Should(BeEmpty())
+	})
+
+	It("should not compare against a cached fail-closed result", func() {
+		// given
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{Outcome: FeatureOutcomeUpstreamError}, time.Hour)
+		respondWith(`{"features": [{"name":"TestBundle1"}]}`)
+
+		// when
+		GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+		// then
+		Consistently(publisher.published, 50*time.Millisecond).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("NewKafkaEventPublisher", func() {
+	It("should publish to the configured topic", func() {
+		// given
+		configOptions.Set(config.Keys.EventsKafkaBrokers, "localhost:9092, localhost:9093")
+		DeferCleanup(configOptions.Set, config.Keys.EventsKafkaBrokers, "")
+
+		// when
+		publisher, err := NewKafkaEventPublisher()
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(publisher).To(BeAssignableToTypeOf(&events.Kafka{}))
+		Expect(publisher.Close()).To(Succeed())
+	})
+
+	It("should fail without brokers", func() {
+		_, err := NewKafkaEventPublisher()
+
+		Expect(err).To(MatchError(ContainSubstring("no kafka brokers")))
+	})
+
+	It("should fail for an unsupported SASL mechanism", func() {
+		// given
+		configOptions.Set(config.Keys.EventsKafkaBrokers, "localhost:9092")
+		configOptions.Set(config.Keys.EventsKafkaUsername, "entitlements")
+		configOptions.Set(config.Keys.EventsKafkaSASLMechanism, "GSSAPI")
+		DeferCleanup(configOptions.Set, config.Keys.EventsKafkaBrokers, "")
+		DeferCleanup(configOptions.Set, config.Keys.EventsKafkaUsername, "")
+		DeferCleanup(configOptions.Set, config.Keys.EventsKafkaSASLMechanism, "")
+
+		// when
+		_, err := NewKafkaEventPublisher()
+
+		// then
+		Expect(err).To(MatchError(ContainSubstring("unsupported kafka SASL mechanism [GSSAPI]")))
+	})
+})
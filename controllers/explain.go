@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+)
+
+// Names of the bundle rules as they appear in an explanation
+const (
+	ruleEntitleAll     = "entitle_all"
+	ruleSkus           = evaluator.RuleSkus
+	rulePaidSkus       = "paid_skus"
+	ruleUseValidAccNum = evaluator.RuleUseValidAccNum
+	ruleUseValidOrgId  = evaluator.RuleUseValidOrgId
+	ruleUseIsInternal  = evaluator.RuleUseIsInternal
+	ruleEntitledWhen   = "entitled_when"
+	ruleIdentityTypes  = "identity_types"
+
+	// decidedByNoRules is used for bundles without any rules, which are always entitled
+	decidedByNoRules = "no_rules"
+	// decidedByAllRules is used for entitled bundles, every rule has to pass for a bundle to be entitled
+	decidedByAllRules = "all_rules_passed"
+)
+
+// Where the feature data a bundle was evaluated against came from
+const (
+	sourceFeatureService = "feature_service"
+	sourceCache          = "cache"
+	sourceStale          = "stale"
+	sourceFailClosed     = "fail_closed"
+	sourceDegraded       = "degraded"
+	sourceEntitleAll     = "entitle_all"
+	// sourceIdentity is used for bundles that are not SKU based and only look at the identity
+	sourceIdentity = "identity"
+)
+
+// bundleInputs are the facts about a request that bundle rules are evaluated against
+type bundleInputs struct {
+	facts evaluator.Facts
+	// featureDates holds the features the Feature Service returned for the org by name
+	featureDates  map[string]types.Feature
+	featureSource string
+	entitleAll    bool
+	// at is when the request was evaluated, trials count their remaining days from it
+	at time.Time
+}
+
+// canExplain reports whether an identity may ask /services to explain its decisions
+func canExplain(id identity.Identity) bool {
+	return configOptions.GetBool(config.Keys.ServicesExplain) || isInternalIdentity(id)
+}
+
+// featureSource describes where the result of a feature status lookup came from
+func featureSource(res types.FeatureResponse, degraded bool) string {
+	switch {
+	case res.Stale:
+		return sourceStale
+	case isCachedFailClosed(res):
+		return sourceFailClosed
+	case degraded:
+		return sourceDegraded
+	case res.CacheHit:
+		return sourceCache
+	default:
+		return sourceFeatureService
+	}
+}
+
+// evaluateBundle decides whether the request is entitled to a bundle, and if it is on a trial.
+// When explain is set the rules that were evaluated are returned along with the result.
+func evaluateBundle(bundle types.Bundle, condition evaluator.Condition, in bundleInputs, explain bool) types.EntitlementsSection {
+	if in.entitleAll {
+		section := setBundlePayload(true, false)
+		if explain {
+			section.Explanation = &types.BundleExplanation{
+				Rules:     []types.RuleEvaluation{{Rule: ruleEntitleAll, Result: true}},
+				DecidedBy: ruleEntitleAll,
+				Source:    sourceEntitleAll,
+			}
+		}
+		return section
+	}
+
+	isTrial := false
+	hasPaidFeature := false
+	paidFeature := bundle.Name + paidFeatureSuffix
+	if bundle.IsSkuBased() && bundle.IsPaid() && in.facts.Features[bundle.Name] {
+		hasPaidFeature = in.facts.Features[paidFeature]
+		isTrial = !hasPaidFeature
+	}
+
+	if !explain {
+		section := setBundlePayload(condition.Evaluate(in.facts), isTrial)
+		setBundleDates(&section, bundle, in.featureDates, in.at)
+		return section
+	}
+
+	isEntitled, steps := condition.Explain(in.facts)
+	rules := make([]types.RuleEvaluation, 0, len(steps)+1)
+	for _, step := range steps {
+		rules = append(rules, types.RuleEvaluation{Rule: step.Rule, Inputs: step.Inputs, Result: step.Result})
+	}
+
+	explanation := &types.BundleExplanation{
+		DecidedBy: decidedBy(bundle, isEntitled, rules),
+		Source:    sourceIdentity,
+	}
+
+	if bundle.IsSkuBased() {
+		explanation.Source = in.featureSource
+		if bundle.IsPaid() && in.facts.Features[bundle.Name] {
+			// a missing paid feature makes the bundle a trial, it does not deny it
+			rules = append(rules, types.RuleEvaluation{
+				Rule:   rulePaidSkus,
+				Inputs: map[string]any{"feature": paidFeature, "feature_found": hasPaidFeature},
+				Result: hasPaidFeature,
+			})
+		}
+	}
+
+	explanation.Rules = rules
+	section := setBundlePayload(isEntitled, isTrial)
+	setBundleDates(&section, bundle, in.featureDates, in.at)
+	section.Explanation = explanation
+	return section
+}
+
+// identityTypeDecision answers a bundle for an identity type it does not apply to with its
+// disallowed_identity_result, without evaluating its other rules
+func identityTypeDecision(bundle types.Bundle, in bundleInputs, explain bool) types.EntitlementsSection {
+	section := setBundlePayload(bundle.IdentityResult() == types.IdentityResultEntitled, false)
+	if explain {
+		section.Explanation = &types.BundleExplanation{
+			Rules: []types.RuleEvaluation{{
+				Rule:   ruleIdentityTypes,
+				Inputs: map[string]any{"identity_type": in.facts.IdentityType, "identity_types": bundle.IdentityTypes},
+				Result: false,
+			}},
+			DecidedBy: ruleIdentityTypes,
+			Source:    sourceIdentity,
+		}
+	}
+	return section
+}
+
+// decidedBy names the rule that determined whether a bundle is entitled
+func decidedBy(bundle types.Bundle, isEntitled bool, rules []types.RuleEvaluation) string {
+	switch {
+	case bundle.EntitledWhen != nil:
+		return ruleEntitledWhen
+	case bundle.UseIsInternal:
+		// use_is_internal overrides every other rule
+		return ruleUseIsInternal
+	case len(rules) == 0:
+		return decidedByNoRules
+	case isEntitled:
+		return decidedByAllRules
+	}
+
+	for _, rule := range rules {
+		if !rule.Result {
+			return rule.Rule
+		}
+	}
+	return decidedByAllRules
+}
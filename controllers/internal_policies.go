@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// builtinInternalPolicy is used as the default policy when the internal policies file does not define one
+var builtinInternalPolicy = types.InternalPolicy{
+	Name:         evaluator.DefaultInternalPolicy,
+	EmailDomains: []string{"redhat.com"},
+}
+
+// builtinInternalPolicies are the policies used until a policies file is loaded, compiled once
+var builtinInternalPolicies = evaluator.InternalPolicies{evaluator.DefaultInternalPolicy: builtinInternalPolicy.Compile()}
+
+var loadedInternalPolicies atomic.Pointer[evaluator.InternalPolicies]
+
+// getInternalPolicies returns the loaded internal policies, only the built-in default if none were loaded
+func getInternalPolicies() evaluator.InternalPolicies {
+	if policies := loadedInternalPolicies.Load(); policies != nil {
+		return *policies
+	}
+	return builtinInternalPolicies
+}
+
+// storeInternalPolicies compiles the given policies and swaps them in, adding the built-in default
+// policy if they do not define one
+func storeInternalPolicies(policies []types.InternalPolicy) {
+	compiled := evaluator.InternalPolicies{evaluator.DefaultInternalPolicy: builtinInternalPolicies[evaluator.DefaultInternalPolicy]}
+	for _, policy := range policies {
+		compiled[policy.Name] = policy.Compile()
+	}
+	loadedInternalPolicies.Store(&compiled)
+}
+
+// SetInternalPolicies loads the internal policies file at yamlFilePath. It is read once at startup,
+// before the bundle config, since bundles are compiled against the policies. The file is optional,
+// a missing file returns an error wrapping os.ErrNotExist and leaves the built-in default policy in place.
+func SetInternalPolicies(yamlFilePath string) error {
+	policiesYaml, err := os.ReadFile(yamlFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			sentry.CaptureException(err)
+		}
+		return err
+	}
+
+	policies, err := types.ParseInternalPolicies(policiesYaml)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", yamlFilePath, err)
+		sentry.CaptureException(err)
+		return err
+	}
+
+	storeInternalPolicies(policies)
+	return nil
+}
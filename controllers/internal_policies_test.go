@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const internalPoliciesYaml = `
+- name: partners
+  email_domains: [example.com]
+  org_ids: ["4384938490324"]
+`
+
+var _ = Describe("Internal policies", func() {
+	var policiesPath string
+
+	BeforeEach(func() {
+		policiesPath = filepath.Join(GinkgoT().TempDir(), "internal_policies.yml")
+		Expect(os.WriteFile(policiesPath, []byte(internalPoliciesYaml), 0644)).To(Succeed())
+		DeferCleanup(func() {
+			loadedInternalPolicies.Store(nil)
+			storeBundleInfo([]Bundle{}, "")
+			Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		})
+	})
+
+	It("should load the policies and keep the built-in default", func() {
+		// when
+		err := SetInternalPolicies(policiesPath)
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(getInternalPolicies()).To(HaveKey("partners"))
+		Expect(getInternalPolicies()[evaluator.DefaultInternalPolicy].EmailAllowed("someone@redhat.com")).To(BeTrue())
+	})
+
+	It("should let a file replace the default policy", func() {
+		// given
+		Expect(os.WriteFile(policiesPath, []byte("- name: default\n  email_domains: [example.com]\n"), 0644)).To(Succeed())
+
+		// when
+		Expect(SetInternalPolicies(policiesPath)).To(Succeed())
+
+		// then
+		Expect(getInternalPolicies()[evaluator.DefaultInternalPolicy].EmailAllowed("someone@redhat.com")).To(BeFalse())
+	})
+
+	It("should report a missing file and keep the built-in default", func() {
+		// when
+		err := SetInternalPolicies(filepath.Join(filepath.Dir(policiesPath), "missing.yml"))
+
+		// then
+		Expect(err).To(MatchError(os.ErrNotExist))
+		Expect(getInternalPolicies()).To(HaveKey(evaluator.DefaultInternalPolicy))
+	})
+
+	It("should compile the built-in default policy once", func() {
+		first := getInternalPolicies()[evaluator.DefaultInternalPolicy]
+
+		Expect(getInternalPolicies()[evaluator.DefaultInternalPolicy]).To(BeIdenticalTo(first))
+	})
+
+	It("should reject an invalid file", func() {
+		// given
+		Expect(os.WriteFile(policiesPath, []byte("- name: partners\n"), 0644)).To(Succeed())
+
+		// when
+		err := SetInternalPolicies(policiesPath)
+
+		// then
+		Expect(err).To(MatchError(ContainSubstring("email_domains is required")))
+		Expect(getInternalPolicies()).NotTo(HaveKey("partners"))
+	})
+
+	It("should not load bundles that use an unknown policy", func() {
+		err := storeBundleInfo([]Bundle{{Name: "TestBundle5", UseIsInternal: true, InternalPolicy: "partners"}}, "")
+
+		Expect(err).To(MatchError(ContainSubstring(`unknown internal policy "partners"`)))
+	})
+
+	It("should entitle users of the bundle's policy", func() {
+		// given
+		Expect(SetInternalPolicies(policiesPath)).To(Succeed())
+		Expect(storeBundleInfo([]Bundle{
+			{Name: "TestBundle5", UseIsInternal: true},
+			{Name: "PartnerBundle", UseIsInternal: true, InternalPolicy: "partners"},
+		}, "")).To(Succeed())
+
+		// when
+		_, body, _ := testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, "someone@example.com", fakeGetFeatureStatus(DEFAULT_ORG_ID, FeatureResponse{}))
+
+		// then
+		Expect(body["PartnerBundle"].IsEntitled).To(BeTrue())
+		Expect(body["TestBundle5"].IsEntitled).To(BeFalse())
+	})
+})
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+var overridesConfigReload = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "overrides_config_reload_total",
+		Help: "Total number of overrides config reload attempts by result",
+	},
+	[]string{"result"},
+)
+var overridesApplied = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "entitlements_overrides_applied_total",
+		Help: "Total number of bundles in /services responses that were decided by an override, by bundle and action",
+	},
+	[]string{"bundle", "action"},
+)
+
+// decidedByOverride is used for bundles that an override granted or denied
+const decidedByOverride = "override"
+
+// overridesState is an immutable snapshot of the loaded overrides, swapped in as a whole like bundleState
+type overridesState struct {
+	// byOrg holds the overrides of each org by bundle name
+	byOrg    map[string]map[string]types.Override
+	count    int
+	hash     string
+	loadedAt time.Time
+}
+
+var loadedOverrides atomic.Pointer[overridesState]
+
+var lastOverridesReloadMu sync.RWMutex
+var lastOverridesReload bundleReloadStatus
+
+// getOverridesState returns the currently loaded overrides, never nil
+func getOverridesState() *overridesState {
+	if state := loadedOverrides.Load(); state != nil {
+		return state
+	}
+	return &overridesState{}
+}
+
+// lookup returns the override for an org's bundle if there is one that has not expired
+func (s *overridesState) lookup(orgID string, bundle string, at time.Time) (types.Override, bool) {
+	override, ok := s.byOrg[orgID][bundle]
+	if !ok || override.Expired(at) {
+		return types.Override{}, false
+	}
+	return override, true
+}
+
+// storeOverrides builds a new snapshot from the given overrides and swaps it in
+func storeOverrides(overrides []types.Override, hash string) {
+	byOrg := make(map[string]map[string]types.Override)
+	for _, override := range overrides {
+		if byOrg[override.OrgID] == nil {
+			byOrg[override.OrgID] = make(map[string]types.Override)
+		}
+		byOrg[override.OrgID][override.Bundle] = override
+	}
+
+	loadedOverrides.Store(&overridesState{
+		byOrg:    byOrg,
+		count:    len(overrides),
+		hash:     hash,
+		loadedAt: time.Now(),
+	})
+}
+
+func recordOverridesReload(result string, err error) {
+	status := bundleReloadStatus{Result: result, At: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	lastOverridesReloadMu.Lock()
+	lastOverridesReload = status
+	lastOverridesReloadMu.Unlock()
+
+	overridesConfigReload.WithLabelValues(result).Inc()
+}
+
+func getLastOverridesReload() bundleReloadStatus {
+	lastOverridesReloadMu.RLock()
+	defer lastOverridesReloadMu.RUnlock()
+	return lastOverridesReload
+}
+
+// SetOverrides loads the overrides file at yamlFilePath. The file is optional, a missing file returns
+// an error wrapping os.ErrNotExist and leaves the loaded overrides in place, as does a file that
+// cannot be parsed.
+func SetOverrides(yamlFilePath string) error {
+	overridesYaml, err := os.ReadFile(yamlFilePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			sentry.CaptureException(err)
+			recordOverridesReload(reloadResultFailure, err)
+		}
+		return err
+	}
+
+	overrides, err := types.ParseOverrides(overridesYaml)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", yamlFilePath, err)
+		sentry.CaptureException(err)
+		recordOverridesReload(reloadResultFailure, err)
+		return err
+	}
+
+	sum := sha256.Sum256(overridesYaml)
+	hash := hex.EncodeToString(sum[:])
+	if hash == getOverridesState().hash {
+		recordOverridesReload(reloadResultUnchanged, nil)
+		return nil
+	}
+
+	storeOverrides(overrides, hash)
+	recordOverridesReload(reloadResultSuccess, nil)
+	warnUnknownOverrideBundles(overrides)
+
+	return nil
+}
+
+// warnUnknownOverrideBundles logs overrides for bundles that are not in the bundle config, they never apply
+func warnUnknownOverrideBundles(overrides []types.Override) {
+	known := make(map[string]bool)
+	for _, bundle := range getBundleState().bundles {
+		known[bundle.Name] = true
+	}
+
+	for _, override := range overrides {
+		if !known[override.Bundle] {
+			l.Log.WithFields(logrus.Fields{"org_id": override.OrgID, "bundle": override.Bundle}).Warn("Override refers to a bundle that is not in the bundle config")
+		}
+	}
+}
+
+// WatchOverrides reloads the overrides whenever the file at yamlFilePath changes, until ctx is done
+func WatchOverrides(ctx context.Context, yamlFilePath string) error {
+	return watchConfigFile(ctx, yamlFilePath, "overrides config", reloadOverrides)
+}
+
+func reloadOverrides(yamlFilePath string) {
+	previousHash := getOverridesState().hash
+	err := SetOverrides(yamlFilePath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		// the file is briefly missing while it is being replaced, the following event will pick it up
+		return
+	}
+
+	if err != nil {
+		l.Log.WithFields(logrus.Fields{"error": err, "hash": previousHash}).Error("Error reloading overrides config, keeping previous overrides")
+		return
+	}
+
+	if current := getOverridesState(); current.hash != previousHash {
+		l.Log.WithFields(logrus.Fields{"previous_hash": previousHash, "hash": current.hash, "overrides": current.count}).Info("overrides config reloaded")
+	}
+}
+
+// applyOverride replaces the decision for a bundle with the override's. Granted bundles expire with
+// the override, the dates of the subscription the rules found no longer apply.
+func applyOverride(section types.EntitlementsSection, bundle string, override types.Override, explain bool) types.EntitlementsSection {
+	overridesApplied.WithLabelValues(bundle, override.Action).Inc()
+
+	overridden := setBundlePayload(override.Action == types.OverrideGrant, false)
+	if overridden.IsEntitled {
+		overridden.ExpiresAt = override.ExpiresAt
+	}
+
+	if explain && section.Explanation != nil {
+		explanation := *section.Explanation
+		explanation.DecidedBy = decidedByOverride
+		explanation.Override = &types.OverrideExplanation{
+			Action:    override.Action,
+			Reason:    override.Reason,
+			ExpiresAt: override.ExpiresAt,
+		}
+		overridden.Explanation = &explanation
+	}
+
+	return overridden
+}
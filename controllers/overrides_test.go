@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const overridesYaml = `
+- org_id: "4384938490324"
+  bundle: TestBundle1
+  action: grant
+  reason: Partner demo
+`
+
+var _ = Describe("Overrides", func() {
+	AfterEach(func() {
+		storeOverrides(nil, "")
+	})
+
+	Describe("SetOverrides", func() {
+		var overridesPath string
+
+		BeforeEach(func() {
+			overridesPath = filepath.Join(GinkgoT().TempDir(), "overrides.yml")
+			Expect(os.WriteFile(overridesPath, []byte(overridesYaml), 0644)).To(Succeed())
+		})
+
+		It("should load the overrides by org and bundle", func() {
+			// when
+			err := SetOverrides(overridesPath)
+
+			// then
+			Expect(err).To(BeNil())
+			_, ok := getOverridesState().lookup(DEFAULT_ORG_ID, "TestBundle1", time.Now())
+			Expect(ok).To(BeTrue())
+			Expect(getOverridesState().count).To(Equal(1))
+			Expect(getLastOverridesReload().Result).To(Equal(reloadResultSuccess))
+		})
+
+		It("should report a missing file without recording a failure", func() {
+			// given
+			Expect(SetOverrides(overridesPath)).To(BeNil())
+
+			// when
+			err := SetOverrides(filepath.Join(filepath.Dir(overridesPath), "missing.yml"))
+
+			// then
+			Expect(err).To(MatchError(os.ErrNotExist))
+			Expect(getLastOverridesReload().Result).To(Equal(reloadResultSuccess))
+		})
+
+		It("should keep the loaded overrides when the file is invalid", func() {
+			// given
+			Expect(SetOverrides(overridesPath)).To(BeNil())
+			loaded := getOverridesState()
+			Expect(os.WriteFile(overridesPath, []byte("- org_id: \"1\"\n  action: allow\n"), 0644)).To(Succeed())
+
+			// when
+			err := SetOverrides(overridesPath)
+
+			// then
+			Expect(err).To(MatchError(ContainSubstring("invalid overrides config")))
+			Expect(getOverridesState()).To(BeIdenticalTo(loaded))
+			Expect(getLastOverridesReload().Result).To(Equal(reloadResultFailure))
+		})
+
+		It("should reload the overrides when the file is written", func() {
+			// given
+			ctx, cancel := context.WithCancel(context.Background())
+			DeferCleanup(cancel)
+			Expect(SetOverrides(overridesPath)).To(BeNil())
+			Expect(WatchOverrides(ctx, overridesPath)).To(BeNil())
+
+			// when
+			Expect(os.WriteFile(overridesPath, []byte(overridesYaml+"- org_id: \"1\"\n  bundle: TestBundle2\n  action: deny\n  reason: Revoked\n"), 0644)).To(Succeed())
+
+			// then
+			Eventually(func() int { return getOverridesState().count }).Should(Equal(2))
+		})
+	})
+
+	Describe("applied by /services", func() {
+		var featureResponse = FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle2"}}}}
+
+		BeforeEach(func() {
+			storeBundleInfo([]Bundle{}, "")
+			Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		})
+
+		It("should grant and deny bundles for the org", func() {
+			// given
+			storeOverrides([]Override{
+				{OrgID: DEFAULT_ORG_ID, Bundle: "TestBundle1", Action: OverrideGrant, Reason: "Partner demo"},
+				{OrgID: DEFAULT_ORG_ID, Bundle: "TestBundle2", Action: OverrideDeny, Reason: "Revoked"},
+			}, "hash")
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, featureResponse))
+
+			// then
+			Expect(body["TestBundle1"]).To(Equal(EntitlementsSection{IsEntitled: true, IsTrial: false}))
+			Expect(body["TestBundle2"]).To(Equal(EntitlementsSection{IsEntitled: false, IsTrial: false}))
+		})
+
+		It("should not apply to other orgs", func() {
+			// given
+			storeOverrides([]Override{{OrgID: "other", Bundle: "TestBundle1", Action: OverrideGrant, Reason: "Partner demo"}}, "hash")
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, featureResponse))
+
+			// then
+			Expect(body["TestBundle1"].IsEntitled).To(BeFalse())
+		})
+
+		It("should not apply once expired", func() {
+			// given
+			expired := time.Now().Add(-time.Hour)
+			storeOverrides([]Override{{OrgID: DEFAULT_ORG_ID, Bundle: "TestBundle1", Action: OverrideGrant, Reason: "Partner demo", ExpiresAt: &expired}}, "hash")
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, featureResponse))
+
+			// then
+			Expect(body["TestBundle1"].IsEntitled).To(BeFalse())
+		})
+
+		It("should expire a granted bundle with the override", func() {
+			// given
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			storeOverrides([]Override{{OrgID: DEFAULT_ORG_ID, Bundle: "TestBundle1", Action: OverrideGrant, Reason: "Partner demo", ExpiresAt: &expiresAt}}, "hash")
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, featureResponse))
+
+			// then
+			Expect(*body["TestBundle1"].ExpiresAt).To(BeTemporally("==", expiresAt))
+		})
+
+		It("should mark overridden bundles in the explanation", func() {
+			// given
+			storeOverrides([]Override{{OrgID: DEFAULT_ORG_ID, Bundle: "TestBundle2", Action: OverrideDeny, Reason: "Revoked"}}, "hash")
+
+			// when
+			_, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, featureResponse))
+
+			// then
+			explanation := body["TestBundle2"].Explanation
+			Expect(explanation.DecidedBy).To(Equal(decidedByOverride))
+			Expect(explanation.Override).To(Equal(&OverrideExplanation{Action: OverrideDeny, Reason: "Revoked"}))
+			Expect(explanation.Rules).To(ContainElement(HaveField("Rule", ruleSkus)))
+			Expect(body["TestBundle1"].Explanation.Override).To(BeNil())
+		})
+	})
+})
@@ -4,6 +4,7 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -26,7 +27,7 @@ type SeatManagerApi struct {
 
 const BASE_LINK_URL = "/api/entitlements/v1/seats"
 
-var _ api.ServerInterface = &SeatManagerApi{}
+var _ SeatsServer = &SeatManagerApi{}
 
 func NewSeatManagerApi(amsClient ams.AMSInterface, bopClient bop.Bop) *SeatManagerApi {
 	return &SeatManagerApi{
@@ -37,8 +38,30 @@ func NewSeatManagerApi(amsClient ams.AMSInterface, bopClient bop.Bop) *SeatManag
 
 var errorMapper SeatsErrorMapper = NewErrorMapper(config.GetConfig())
 
-// doError will construct an api.Error reponse and write it to the response writer
-func doError(w http.ResponseWriter, httpStatusCode int, err error, source string) {
+// seatsError is an api.Error response written with the status it carries, which AMS and BOP errors
+// decide rather than the spec
+type seatsError api.Error
+
+func (response seatsError) write(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(*response.Status)
+	return json.NewEncoder(w).Encode(response)
+}
+
+func (response seatsError) VisitGetSeatsResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
+
+func (response seatsError) VisitPostSeatsResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
+
+func (response seatsError) VisitDeleteSeatsIdResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
+
+// doError will construct an api.Error response for err
+func doError(httpStatusCode int, err error, source string) seatsError {
 	response := errorMapper.MapResponse(err, httpStatusCode)
 
 	log := logger.Log.WithFields(logrus.Fields{"error": err, "status": httpStatusCode, "source": source})
@@ -48,58 +71,60 @@ func doError(w http.ResponseWriter, httpStatusCode int, err error, source string
 		log.Debug("ams request error")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(*response.Status)
-	json.NewEncoder(w).Encode(response)
+	return seatsError(response)
 }
 
-func (s *SeatManagerApi) DeleteSeatsId(w http.ResponseWriter, r *http.Request, id string) {
-	idObj := identity.GetIdentity(r.Context()).Identity
+func (s *SeatManagerApi) DeleteSeatsId(ctx context.Context, request api.DeleteSeatsIdRequestObject) (api.DeleteSeatsIdResponseObject, error) {
+	idObj := identity.GetIdentity(ctx).Identity
+	id := request.Id
 
 	// Service Accounts don't have User field and cannot be org admins
 	if idObj.User == nil || !idObj.User.OrgAdmin {
-		doError(w, http.StatusForbidden, fmt.Errorf("Not allowed to delete subscription %s. User must be org admin", id), "")
-		return
+		return doError(http.StatusForbidden, fmt.Errorf("Not allowed to delete subscription %s. User must be org admin", id), ""), nil
 	}
 
 	subscription, err := s.ams.GetSubscription(id)
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS GetSubscription")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS GetSubscription"), nil
 	}
 
 	subOrgId, ok := subscription.GetOrganizationID()
 	if !ok {
-		doError(w, http.StatusInternalServerError,
-			fmt.Errorf("Subscription with id [%s] does not have a corresponding ams org id, cannot verify subscription org", id), "")
-		return
+		return doError(http.StatusInternalServerError,
+			fmt.Errorf("Subscription with id [%s] does not have a corresponding ams org id, cannot verify subscription org", id), ""), nil
 	}
 
 	amsUserOrgId, err := s.ams.ConvertUserOrgId(idObj.Internal.OrgID)
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS ConvertUserOrgId")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS ConvertUserOrgId"), nil
 	}
 
 	if subOrgId != amsUserOrgId {
-		doError(w, http.StatusForbidden,
+		return doError(http.StatusForbidden,
 			fmt.Errorf("Not allowed to delete subscription %s. Subscription org [%s] must match user ams org id [%s]}. User org [%s]",
-				id, subOrgId, amsUserOrgId, idObj.Internal.OrgID), "")
-		return
+				id, subOrgId, amsUserOrgId, idObj.Internal.OrgID), ""), nil
 	}
 
 	if err = s.ams.DeleteSubscription(id); err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS DeleteSubscription")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS DeleteSubscription"), nil
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	return api.DeleteSeatsId204Response{}, nil
 }
 
 func toPtr[T any](s T) *T {
 	return &s
 }
 
+// fromPtr returns the value p points to, the zero value for a nil p such as an optional parameter that was not sent
+func fromPtr[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
 func fillDefaults(params *api.GetSeatsParams) {
 	if params.Limit == nil {
 		params.Limit = toPtr(10)
@@ -110,9 +135,9 @@ func fillDefaults(params *api.GetSeatsParams) {
 	}
 }
 
-func (s *SeatManagerApi) GetSeats(w http.ResponseWriter, r *http.Request, params api.GetSeatsParams) {
-
-	idObj := identity.GetIdentity(r.Context()).Identity
+func (s *SeatManagerApi) GetSeats(ctx context.Context, request api.GetSeatsRequestObject) (api.GetSeatsResponseObject, error) {
+	idObj := identity.GetIdentity(ctx).Identity
+	params := request.Params
 
 	// AMS uses fixed pages rather than offsets So we are forcing the
 	// offset to be tied to the nearest previous page.
@@ -121,27 +146,23 @@ func (s *SeatManagerApi) GetSeats(w http.ResponseWriter, r *http.Request, params
 	offset := int(*params.Offset)
 
 	if limit < 1 {
-		doError(w, http.StatusBadRequest, fmt.Errorf("limit must be > 0"), "")
-		return
+		return doError(http.StatusBadRequest, fmt.Errorf("limit must be > 0"), ""), nil
 	}
 
 	if offset < 0 {
-		doError(w, http.StatusBadRequest, fmt.Errorf("offset must be >= 0"), "")
-		return
+		return doError(http.StatusBadRequest, fmt.Errorf("offset must be >= 0"), ""), nil
 	}
 
 	page := 1 + (offset / limit)
 
 	subs, err := s.ams.GetSubscriptions(idObj.Internal.OrgID, params, limit, page)
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS GetSubscriptions")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS GetSubscriptions"), nil
 	}
 
 	quotaCost, err := s.ams.GetQuotaCost(idObj.Internal.OrgID)
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS GetQuotaCost")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS GetQuotaCost"), nil
 	}
 
 	var seats = make([]api.Seat, 0)
@@ -185,74 +206,51 @@ func (s *SeatManagerApi) GetSeats(w http.ResponseWriter, r *http.Request, params
 		Consumed: toPtr(int64(quotaCost.Consumed())),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err = json.NewEncoder(w).Encode(resp); err != nil {
-		doError(w, http.StatusInternalServerError, fmt.Errorf("Unexpected error encoding response [%w]", err), "")
-		return
-	}
-
+	return api.GetSeats200JSONResponse(resp), nil
 }
 
-func (s *SeatManagerApi) PostSeats(w http.ResponseWriter, r *http.Request) {
-	idObj := identity.GetIdentity(r.Context()).Identity
+func (s *SeatManagerApi) PostSeats(ctx context.Context, request api.PostSeatsRequestObject) (api.PostSeatsResponseObject, error) {
+	idObj := identity.GetIdentity(ctx).Identity
 
 	// Service Accounts don't have User field and cannot be org admins
 	if idObj.User == nil || !idObj.User.OrgAdmin {
-		doError(w, http.StatusForbidden, fmt.Errorf("Not allowed to assign seats, must be an org admin."), "")
-		return
+		return doError(http.StatusForbidden, fmt.Errorf("Not allowed to assign seats, must be an org admin."), ""), nil
 	}
 
-	seat := new(api.SeatRequest)
-	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(seat); err != nil {
-		doError(w, http.StatusBadRequest, fmt.Errorf("PostSeats [%w]", err), "")
-		return
-	}
+	seat := request.Body
 
 	user, err := s.bop.GetUser(seat.AccountUsername)
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "BOP GetUser")
-		return
+		return doError(http.StatusInternalServerError, err, "BOP GetUser"), nil
 	}
 
 	if user.OrgId != idObj.Internal.OrgID {
-		doError(w, http.StatusForbidden, fmt.Errorf("Not allowed to assign seats to users outside of Organization %s", idObj.Internal.OrgID), "")
-		return
+		return doError(http.StatusForbidden, fmt.Errorf("Not allowed to assign seats to users outside of Organization %s", idObj.Internal.OrgID), ""), nil
 	}
 
 	quotaCost, err := s.ams.GetQuotaCost(idObj.Internal.OrgID)
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS GetQuotaCost")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS GetQuotaCost"), nil
 	}
 
 	resp, err := s.ams.QuotaAuthorization(seat.AccountUsername, quotaCost.Version())
 	if err != nil {
-		doError(w, http.StatusInternalServerError, err, "AMS QuotaAuthorization")
-		return
+		return doError(http.StatusInternalServerError, err, "AMS QuotaAuthorization"), nil
 	}
 
 	if !resp.Allowed() {
 		if len(resp.ExcessResources()) > 0 {
-			doError(w, http.StatusConflict, fmt.Errorf("Assignment request was denied due to excessive resource requests"), "")
-			return
+			return doError(http.StatusConflict, fmt.Errorf("Assignment request was denied due to excessive resource requests"), ""), nil
 		}
-		doError(w, http.StatusForbidden, fmt.Errorf("Assignment request was denied"), "")
-		return
+		return doError(http.StatusForbidden, fmt.Errorf("Assignment request was denied"), ""), nil
 	}
 
 	sub := resp.Subscription()
 	subId := sub.ID()
 	userName := seat.AccountUsername
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err = json.NewEncoder(w).Encode(api.Seat{
+	return api.PostSeats200JSONResponse(api.Seat{
 		SubscriptionId:  &subId,
 		AccountUsername: &userName,
-	}); err != nil {
-		doError(w, http.StatusInternalServerError, fmt.Errorf("Unexpected error encoding response [%w]", err), "")
-		return
-	}
+	}), nil
 }
@@ -97,13 +97,13 @@ func MakeServiceAccountRequest(method, path string, body io.Reader) *http.Reques
 var _ = Describe("using the seat managment api", func() {
 	var client ams.AMSInterface
 	var bopClient bop.Bop
-	var seatApi *SeatManagerApi
+	var seatApi api.ServerInterface
 	var rr *httptest.ResponseRecorder
 
 	BeforeEach(func() {
 		client = &ams.Mock{}
 		bopClient, _ = bop.NewClient(true)
-		seatApi = NewSeatManagerApi(client, bopClient)
+		seatApi = newStrictHandler(NewApiServer(NewSeatManagerApi(client, bopClient), nil, ServicesApi{}))
 		rr = httptest.NewRecorder()
 	})
 
@@ -286,9 +286,9 @@ var _ = Describe("using the seat managment api", func() {
 
 		Context("the target is in a different org from the caller", func() {
 			It("should not assign the user a seat", func() {
-				mismatchApi := NewSeatManagerApi(client, &bop.Mock{
+				mismatchApi := newStrictHandler(NewApiServer(NewSeatManagerApi(client, &bop.Mock{
 					OrgId: "12345",
-				})
+				}), nil, ServicesApi{}))
 				b, err := json.Marshal(api.SeatRequest{
 					AccountUsername: "test-user",
 				})
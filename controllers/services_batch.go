@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const serviceAccountIdentityType = "ServiceAccount"
+
+var servicesBatchOrgs = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "services_batch_orgs",
+	Help:    "Number of orgs per /services/batch request, by whether the org's feature status was cached",
+	Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+}, []string{"cache_hit"})
+
+// ServicesBatchApi serves entitlement lookups for many orgs at once to other platform services
+type ServicesBatchApi struct {
+	features featureservice.FeatureService
+}
+
+func NewServicesBatchApi(features featureservice.FeatureService) *ServicesBatchApi {
+	return &ServicesBatchApi{features: features}
+}
+
+// canUseServicesBatch reports whether an identity is a service account that is allowed to call /services/batch
+func canUseServicesBatch(id identity.Identity) bool {
+	if id.Type != serviceAccountIdentityType || id.ServiceAccount == nil || id.ServiceAccount.ClientId == "" {
+		return false
+	}
+	for allowed := range strings.SplitSeq(configOptions.GetString(config.Keys.ServicesBatchClientIDs), ",") {
+		if strings.TrimSpace(allowed) == id.ServiceAccount.ClientId {
+			return true
+		}
+	}
+	return false
+}
+
+// validateServicesBatchRequest checks the org IDs of a batch request and returns them without duplicates
+func validateServicesBatchRequest(body api.ServicesBatchRequest) ([]string, error) {
+	if len(body.OrgIds) == 0 {
+		return nil, fmt.Errorf("org_ids must not be empty")
+	}
+
+	// duplicates count towards the limit, so an oversized request is rejected before it is looked at
+	maxOrgs := configOptions.GetInt(config.Keys.ServicesBatchMaxOrgs)
+	if len(body.OrgIds) > maxOrgs {
+		return nil, fmt.Errorf("org_ids has %d orgs, at most %d are allowed per request", len(body.OrgIds), maxOrgs)
+	}
+
+	seen := make(map[string]bool, len(body.OrgIds))
+	orgIDs := make([]string, 0, len(body.OrgIds))
+	for _, orgID := range body.OrgIds {
+		if strings.TrimSpace(orgID) == "" {
+			return nil, fmt.Errorf("org_ids must not contain empty values")
+		}
+		if !seen[orgID] {
+			seen[orgID] = true
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+	return orgIDs, nil
+}
+
+// lookupFeatureStatuses returns the feature status of every org, in the same order. Cached orgs are
+// served straight from the cache, the rest are fetched with at most ENT_SERVICES_BATCH_CONCURRENCY
+// Feature Service requests in flight.
+func lookupFeatureStatuses(features featureservice.FeatureService, orgIDs []string) []types.FeatureResponse {
+	responses := make([]types.FeatureResponse, len(orgIDs))
+
+	var misses errgroup.Group
+	misses.SetLimit(max(configOptions.GetInt(config.Keys.ServicesBatchConcurrency), 1))
+
+	hits := 0
+	for i, orgID := range orgIDs {
+		if cachedFeatureStatus(orgID) != nil {
+			responses[i] = GetFeatureStatus(GetFeatureStatusParams{FeatureService: features, OrgId: orgID})
+			hits++
+			continue
+		}
+
+		misses.Go(func() error {
+			responses[i] = GetFeatureStatus(GetFeatureStatusParams{FeatureService: features, OrgId: orgID})
+			return nil
+		})
+	}
+	misses.Wait()
+
+	servicesBatchOrgs.WithLabelValues("true").Observe(float64(hits))
+	servicesBatchOrgs.WithLabelValues("false").Observe(float64(len(orgIDs) - hits))
+	return responses
+}
+
+// orgInputs builds the inputs for deciding bundles for an org rather than a user. Bundles are decided
+// for a user of the org who meets every rule about the user, so they follow the org's subscriptions
+// like they do on /services.
+func orgInputs(orgID string, subscriptions types.FeatureResponse, degraded bool, at time.Time) bundleInputs {
+	features, featureDates := indexFeatures(subscriptions.Data)
+	return bundleInputs{
+		facts: evaluator.Facts{
+			Features:     features,
+			IdentityType: "User",
+			OrgID:        orgID,
+			ValidOrgID:   orgID != "-1",
+			AnyUser:      true,
+		},
+		featureDates:  featureDates,
+		featureSource: featureSource(subscriptions, degraded),
+		entitleAll:    configOptions.GetBool(config.Keys.EntitleAll),
+		at:            at,
+	}
+}
+
+// servicesBatchResult decides the bundles of a single org for a user of the org who meets every rule
+// about the user
+func servicesBatchResult(orgID string, subscriptions types.FeatureResponse, degraded bool, body api.ServicesBatchRequest, at time.Time) api.ServicesBatchResult {
+	inputs := orgInputs(orgID, subscriptions, degraded, at)
+
+	var includeFilter, excludeFilter []string
+	if body.IncludeBundles != nil {
+		includeFilter = *body.IncludeBundles
+	}
+	if body.ExcludeBundles != nil {
+		excludeFilter = *body.ExcludeBundles
+	}
+
+	entitlements := make(api.Service)
+	for name, section := range evaluateBundles(orgID, inputs, includeFilter, excludeFilter, false) {
+		entitlements[name] = api.ServiceDetails{
+			IsEntitled:         &section.IsEntitled,
+			IsTrial:            &section.IsTrial,
+			StartsAt:           section.StartsAt,
+			ExpiresAt:          section.ExpiresAt,
+			TrialDaysRemaining: section.TrialDaysRemaining,
+		}
+	}
+
+	result := api.ServicesBatchResult{OrgId: orgID, Degraded: degraded, Entitlements: entitlements}
+	if degraded {
+		// same as the X-Entitlements-Degraded-Status and X-Entitlements-Stale headers on /services
+		status := subscriptions.StatusCode
+		if subscriptions.CacheHit {
+			status = 0
+		}
+		result.DegradedStatus = &status
+		result.Stale = &subscriptions.Stale
+	}
+	return result
+}
+
+// PostServicesBatch returns the entitlements of every org in the request
+func (s *ServicesBatchApi) PostServicesBatch(ctx context.Context, request api.PostServicesBatchRequestObject) (api.PostServicesBatchResponseObject, error) {
+	start := time.Now()
+	idObj := identity.GetIdentity(ctx).Identity
+
+	if !canUseServicesBatch(idObj) {
+		l.Log.WithFields(logrus.Fields{"identity_type": idObj.Type, "org_id": idObj.Internal.OrgID}).Warn("Rejected /services/batch request from an identity that is not an allowed service account")
+		return api.PostServicesBatch403JSONResponse(requestError(http.StatusForbidden, "/services/batch is only available to allowed service accounts")), nil
+	}
+
+	body := *request.Body
+	orgIDs, err := validateServicesBatchRequest(body)
+	if err != nil {
+		return api.PostServicesBatch400JSONResponse(requestError(http.StatusBadRequest, err.Error())), nil
+	}
+
+	at := now()
+	responses := lookupFeatureStatuses(s.features, orgIDs)
+	results := make([]api.ServicesBatchResult, len(orgIDs))
+	degradedOrgs := 0
+	failedOrgs := 0
+	var failure *featureStatusFailure
+	var failedOrgID string
+	for i, orgID := range orgIDs {
+		degraded, orgFailure := checkFeatureStatus(responses[i])
+		if orgFailure != nil {
+			failedOrgs++
+			if failure == nil {
+				failure, failedOrgID = orgFailure, orgID
+			}
+		}
+
+		results[i] = servicesBatchResult(orgID, responses[i], degraded, body, at)
+		if degraded {
+			degradedOrgs++
+		}
+	}
+
+	// a Feature Service outage fails every org of a batch, it is reported once rather than per org
+	if failure != nil {
+		failure.capture(map[string]string{
+			"org_id":      failedOrgID,
+			"failed_orgs": strconv.Itoa(failedOrgs),
+			"orgs":        strconv.Itoa(len(orgIDs)),
+			"client_id":   idObj.ServiceAccount.ClientId,
+		})
+	}
+
+	l.Log.WithFields(logrus.Fields{
+		"client_id":     idObj.ServiceAccount.ClientId,
+		"orgs":          len(orgIDs),
+		"degraded_orgs": degradedOrgs,
+		"duration":      time.Since(start).Seconds(),
+	}).Info("services batch lookup complete")
+
+	return api.PostServicesBatch200JSONResponse{Results: results}, nil
+}
@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/getsentry/sentry-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+)
+
+const batchClientID = "notifications-backend"
+
+func batchServiceAccount(clientID string) identity.Identity {
+	return identity.Identity{
+		Type:           serviceAccountIdentityType,
+		ServiceAccount: &identity.ServiceAccount{ClientId: clientID, Username: "service-account-" + clientID},
+		Internal:       identity.Internal{OrgID: "11789772"},
+	}
+}
+
+func batchRequest(id identity.Identity, body string) (*httptest.ResponseRecorder, api.ServicesBatchResponse) {
+	req, err := http.NewRequest("POST", "/services/batch", strings.NewReader(body))
+	Expect(err).To(BeNil(), "NewRequest error was not nil")
+	req = req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{Identity: id}))
+
+	rr := serveApi(req)
+
+	var response api.ServicesBatchResponse
+	if rr.Code == http.StatusOK {
+		Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(Succeed())
+	}
+	return rr, response
+}
+
+// fakeFeatureStatusByOrg returns the response for each org, orgs without one have no features
+func fakeFeatureStatusByOrg(responses map[string]FeatureResponse) func(GetFeatureStatusParams) FeatureResponse {
+	return func(params GetFeatureStatusParams) FeatureResponse {
+		if res, ok := responses[params.OrgId]; ok {
+			return res
+		}
+		return FeatureResponse{StatusCode: 200}
+	}
+}
+
+var _ = Describe("Services Batch Controller", func() {
+	BeforeEach(func() {
+		storeBundleInfo([]Bundle{}, "")
+		Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		cache.Clear()
+
+		configOptions.Set(config.Keys.ServicesBatchClientIDs, "other-service,"+batchClientID)
+		DeferCleanup(configOptions.Set, config.Keys.ServicesBatchClientIDs, "")
+		configOptions.Set(config.Keys.ServicesBatchMaxOrgs, 3)
+		DeferCleanup(configOptions.Set, config.Keys.ServicesBatchMaxOrgs, 100)
+	})
+
+	AfterEach(func() {
+		GetFeatureStatus = realGetFeatureStatus
+	})
+
+	Describe("access", func() {
+		It("should allow service accounts on the allowlist", func() {
+			// given
+			GetFeatureStatus = fakeFeatureStatusByOrg(nil)
+
+			// when
+			rr, _ := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1"]}`)
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+		})
+
+		It("should allow service accounts listed with spaces around them", func() {
+			// given
+			configOptions.Set(config.Keys.ServicesBatchClientIDs, "other-service, "+batchClientID+" ")
+			GetFeatureStatus = fakeFeatureStatusByOrg(nil)
+
+			// when
+			rr, _ := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1"]}`)
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should reject service accounts that are not on the allowlist", func() {
+			rr, _ := batchRequest(batchServiceAccount("unknown-service"), `{"org_ids": ["1"]}`)
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should reject users, even internal ones", func() {
+			rr, _ := batchRequest(identity.Identity{Type: "User", User: &identity.User{Internal: true}}, `{"org_ids": ["1"]}`)
+
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should reject everyone when no service accounts are allowed", func() {
+			// given
+			configOptions.Set(config.Keys.ServicesBatchClientIDs, "")
+
+			// when
+			rr, _ := batchRequest(batchServiceAccount(""), `{"org_ids": ["1"]}`)
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	DescribeTable("should reject invalid requests",
+		func(body string, message string) {
+			rr, _ := batchRequest(batchServiceAccount(batchClientID), body)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			var errorResp RequestErrorResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+			Expect(errorResp.Error.Message).To(ContainSubstring(message))
+		},
+		Entry("malformed JSON", `{"org_ids": [`, "Invalid request body"),
+		Entry("no org IDs", `{"org_ids": []}`, "org_ids must not be empty"),
+		Entry("empty org IDs", `{"org_ids": ["1", " "]}`, "must not contain empty values"),
+		Entry("too many org IDs", `{"org_ids": ["1", "2", "3", "4"]}`, "at most 3 are allowed"),
+		Entry("too many org IDs with duplicates", `{"org_ids": ["1", "1", "1", "1"]}`, "at most 3 are allowed"),
+	)
+
+	It("should return every org in the order they were requested, once", func() {
+		// given
+		GetFeatureStatus = fakeFeatureStatusByOrg(map[string]FeatureResponse{
+			"2": {StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}},
+		})
+
+		// when
+		rr, response := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["2", "1", "2"], "include_bundles": ["TestBundle1", "TestBundle2"]}`)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(response.Results).To(HaveLen(2))
+		Expect(response.Results[0].OrgId).To(Equal("2"))
+		Expect(response.Results[0].Entitlements).To(HaveLen(2))
+		Expect(*response.Results[0].Entitlements["TestBundle1"].IsEntitled).To(BeTrue())
+		Expect(*response.Results[0].Entitlements["TestBundle2"].IsEntitled).To(BeFalse())
+		Expect(response.Results[1].OrgId).To(Equal("1"))
+		Expect(*response.Results[1].Entitlements["TestBundle1"].IsEntitled).To(BeFalse())
+	})
+
+	It("should flag degraded orgs individually", func() {
+		// given
+		GetFeatureStatus = fakeFeatureStatusByOrg(map[string]FeatureResponse{
+			"1": {StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}},
+			"2": {StatusCode: 503},
+			"3": {StatusCode: 200, CacheHit: true, Stale: true, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}},
+		})
+
+		// when
+		_, response := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1", "2", "3"], "include_bundles": ["TestBundle1"]}`)
+
+		// then
+		Expect(response.Results[0].Degraded).To(BeFalse())
+		Expect(response.Results[0].DegradedStatus).To(BeNil())
+		Expect(response.Results[1].Degraded).To(BeTrue())
+		Expect(*response.Results[1].DegradedStatus).To(Equal(503))
+		Expect(*response.Results[1].Stale).To(BeFalse())
+		Expect(response.Results[2].Degraded).To(BeTrue())
+		Expect(*response.Results[2].DegradedStatus).To(Equal(0))
+		Expect(*response.Results[2].Stale).To(BeTrue())
+		Expect(*response.Results[2].Entitlements["TestBundle1"].IsEntitled).To(BeTrue())
+	})
+
+	It("should report failed orgs to Sentry once per request", func() {
+		// given
+		var events []*sentry.Event
+		hub := sentry.CurrentHub()
+		DeferCleanup(hub.BindClient, hub.Client())
+		client, err := sentry.NewClient(sentry.ClientOptions{BeforeSend: func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			events = append(events, event)
+			return nil
+		}})
+		Expect(err).To(BeNil())
+		hub.BindClient(client)
+		GetFeatureStatus = fakeFeatureStatusByOrg(map[string]FeatureResponse{
+			"1": {StatusCode: 503},
+			"2": {StatusCode: 503},
+		})
+
+		// when
+		batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1", "2", "3"]}`)
+
+		// then
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Tags).To(HaveKeyWithValue("failed_orgs", "2"))
+		Expect(events[0].Tags).To(HaveKeyWithValue("orgs", "3"))
+		Expect(events[0].Tags).To(HaveKeyWithValue("org_id", "1"))
+		Expect(events[0].Tags).To(HaveKeyWithValue("response_status", "503"))
+	})
+
+	It("should decide bundles for a user of the org who meets every rule about the user", func() {
+		// given
+		GetFeatureStatus = fakeFeatureStatusByOrg(nil)
+
+		// when
+		_, response := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1", "-1"], "include_bundles": ["TestBundle4", "TestBundle5", "TestBundle7"]}`)
+
+		// then
+		entitlements := response.Results[0].Entitlements
+		Expect(*entitlements["TestBundle4"].IsEntitled).To(BeTrue(), "use_valid_acc_num is about the user")
+		Expect(*entitlements["TestBundle5"].IsEntitled).To(BeTrue(), "use_is_internal is about the user")
+		Expect(*entitlements["TestBundle7"].IsEntitled).To(BeTrue(), "use_valid_org_id is about the org")
+		Expect(*response.Results[1].Entitlements["TestBundle7"].IsEntitled).To(BeFalse(), "use_valid_org_id is about the org")
+	})
+
+	It("should entitle orgs with the skus of a use_valid_acc_num bundle like /services", func() {
+		// given
+		Expect(storeBundleInfo([]Bundle{{Name: "TestBundle1", Skus: []string{"SVC123"}, UseValidAccNum: true}}, "")).To(Succeed())
+		GetFeatureStatus = fakeFeatureStatusByOrg(map[string]FeatureResponse{
+			"1": {StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}},
+		})
+
+		// when
+		_, response := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1", "2"]}`)
+
+		// then
+		Expect(*response.Results[0].Entitlements["TestBundle1"].IsEntitled).To(BeTrue())
+		Expect(*response.Results[1].Entitlements["TestBundle1"].IsEntitled).To(BeFalse())
+	})
+
+	It("should apply the overrides of each org", func() {
+		// given
+		GetFeatureStatus = fakeFeatureStatusByOrg(nil)
+		storeOverrides([]Override{{OrgID: "2", Bundle: "TestBundle1", Action: OverrideGrant, Reason: "Partner demo"}}, "hash")
+		DeferCleanup(storeOverrides, []Override(nil), "")
+
+		// when
+		_, response := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1", "2"], "include_bundles": ["TestBundle1"]}`)
+
+		// then
+		Expect(*response.Results[0].Entitlements["TestBundle1"].IsEntitled).To(BeFalse())
+		Expect(*response.Results[1].Entitlements["TestBundle1"].IsEntitled).To(BeTrue())
+	})
+
+	It("should limit the Feature Service lookups in flight for orgs that are not cached", func() {
+		// given
+		configOptions.Set(config.Keys.ServicesBatchMaxOrgs, 100)
+		configOptions.Set(config.Keys.ServicesBatchConcurrency, 2)
+		DeferCleanup(configOptions.Set, config.Keys.ServicesBatchConcurrency, 10)
+		cache.Set("cached", featurecache.Entry{Status: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}}, time.Hour)
+
+		var inFlight, maxInFlight atomic.Int32
+		var lookups []string
+		lookupsCh := make(chan string, 10)
+		GetFeatureStatus = func(params GetFeatureStatusParams) FeatureResponse {
+			lookupsCh <- params.OrgId
+			if params.OrgId == "cached" {
+				return FeatureResponse{StatusCode: 200, CacheHit: true}
+			}
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				seen := maxInFlight.Load()
+				if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return FeatureResponse{StatusCode: 200}
+		}
+
+		// when
+		rr, response := batchRequest(batchServiceAccount(batchClientID), `{"org_ids": ["1", "2", "3", "cached", "4", "5", "6"]}`)
+
+		// then
+		close(lookupsCh)
+		for orgID := range lookupsCh {
+			lookups = append(lookups, orgID)
+		}
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(response.Results).To(HaveLen(7))
+		Expect(lookups).To(ConsistOf("1", "2", "3", "cached", "4", "5", "6"))
+		Expect(maxInFlight.Load()).To(Equal(int32(2)))
+	})
+})
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+
+	"github.com/sirupsen/logrus"
+)
+
+// bundleCacheKey is where the feature status fetched for a single bundle of an org is cached. It is
+// kept apart from the org's entry because it only holds that bundle's features.
+func bundleCacheKey(orgID string, bundle string) string {
+	return orgID + "/" + bundle
+}
+
+// GetBundleFeatureStatus returns the feature status needed to decide a single bundle for an org. The
+// org's full feature status is used when it is cached, otherwise only the bundle's features are
+// requested from the feature service.
+var GetBundleFeatureStatus = func(features featureservice.FeatureService, orgID string, bundle string) types.FeatureResponse {
+	if cachedFeatureStatus(orgID) != nil || configOptions.GetBool(config.Keys.EntitleAll) {
+		return GetFeatureStatus(GetFeatureStatusParams{FeatureService: features, OrgId: orgID})
+	}
+
+	state := getBundleState()
+	requested, ok := state.bundleRequestedFeatures[bundle]
+	if !ok {
+		// the bundle is decided without features, there is nothing to ask the feature service
+		return types.FeatureResponse{StatusCode: 200, Data: types.FeatureStatus{}}
+	}
+
+	key := bundleCacheKey(orgID, bundle)
+	if cached := cachedFeatureStatus(key); cached != nil {
+		if cached.Stale {
+			refreshInBackground(features, orgID)
+		}
+
+		return types.FeatureResponse{
+			StatusCode: 200,
+			Data:       cached.Status,
+			CacheHit:   true,
+			Stale:      cached.Stale,
+			Outcome:    cached.LookupOutcome(),
+		}
+	}
+
+	executed := false
+	res, _, _ := featureStatusRequests.Do("bundle:"+key, func() (interface{}, error) {
+		executed = true
+		return fetchBundleFeatureStatus(features, orgID, key, requested, state.featureSet), nil
+	})
+
+	if !executed {
+		subsDeduplicated.Inc()
+	}
+
+	return res.(types.FeatureResponse)
+}
+
+// fetchBundleFeatureStatus requests a single bundle's features for an org and caches the outcome under
+// key. The result is never remembered as the org's last known good, it does not hold every feature.
+func fetchBundleFeatureStatus(features featureservice.FeatureService, orgID string, key string, requested []string, featureSet string) types.FeatureResponse {
+	res := requestFeatureStatus(features, orgID, requested)
+	if res.Error != nil || res.StatusCode != 200 {
+		return serveStaleOrFailClosed(orgID, key, featureSet, res)
+	}
+
+	cache.Set(key, featurecache.Entry{Status: res.Data, Outcome: res.Outcome, FeatureSet: featureSet}, cacheDuration)
+	resetFailureStreak(key)
+	return res
+}
+
+// evictFeatureStatus removes the org's cached feature status, including the entries fetched for single
+// bundles, and reports whether the org's own entry was cached. Single bundle entries are removed by
+// prefix so those of bundles a reload removed go too.
+func evictFeatureStatus(orgID string) (bool, error) {
+	_, err := cache.DeletePrefix(bundleCacheKey(orgID, ""))
+	return cache.Delete(orgID), err
+}
+
+// findBundle returns the loaded bundle with the given name
+func findBundle(name string) (types.Bundle, bool) {
+	for _, bundle := range getBundleState().bundles {
+		if bundle.Name == name {
+			return bundle, true
+		}
+	}
+	return types.Bundle{}, false
+}
+
+// GetServicesBundle decides a single bundle for the identity making the request
+func (s ServicesApi) GetServicesBundle(ctx context.Context, request api.GetServicesBundleRequestObject) (api.GetServicesBundleResponseObject, error) {
+	start := time.Now()
+	idObj := identity.GetIdentity(ctx).Identity
+	orgId := idObj.Internal.OrgID
+
+	bundle, ok := findBundle(request.Bundle)
+	if !ok {
+		return api.GetServicesBundle404JSONResponse(requestError(http.StatusNotFound, "Unknown bundle: "+request.Bundle)), nil
+	}
+
+	trialActivated := forceFreshData(orgId, fromPtr(request.Params.TrialActivated))
+	explain := fromPtr(request.Params.Explain)
+	if explain && !canExplain(idObj) {
+		return api.GetServicesBundle403JSONResponse(requestError(http.StatusForbidden, "explain=true is only available to internal users")), nil
+	}
+
+	var subscriptions types.FeatureResponse
+	if trialActivated {
+		subscriptions = GetFeatureStatus(GetFeatureStatusParams{FeatureService: s.features, OrgId: orgId, ForceFreshData: true})
+	} else {
+		subscriptions = GetBundleFeatureStatus(s.features, orgId, bundle.Name)
+	}
+
+	degraded := featureStatusDegraded(subscriptions)
+
+	subsTimeTaken := time.Since(start).Seconds()
+	l.Log.WithFields(logrus.Fields{
+		"subs_call_duration": subsTimeTaken,
+		"cache_hit":          subscriptions.CacheHit,
+		"url":                subscriptions.Url,
+		"org_id":             orgId,
+		"bundle":             bundle.Name,
+	}).Info("feature service call complete")
+	subsTimeHistogram.Observe(subsTimeTaken)
+
+	inputs := identityInputs(idObj, subscriptions, degraded)
+	section, ok := evaluateBundles(orgId, inputs, []string{bundle.Name}, nil, explain)[bundle.Name]
+	if !ok {
+		// the bundle is omitted for the identity type, as it would be from /services
+		return api.GetServicesBundle404JSONResponse(requestError(http.StatusNotFound, "Bundle "+bundle.Name+" does not apply to identity type "+idObj.Type)), nil
+	}
+
+	obj, err := json.Marshal(section)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling bundle entitlement: %w", err)
+	}
+
+	return newEntitlementsResponse(obj, idObj, subscriptions, degraded), nil
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+)
+
+var realGetBundleFeatureStatus = GetBundleFeatureStatus
+
+func bundleRequest(path string, internal bool) (*httptest.ResponseRecorder, EntitlementsSection) {
+	req, err := http.NewRequest("GET", path, nil)
+	Expect(err).To(BeNil(), "NewRequest error was not nil")
+	req = req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{
+		Identity: identity.Identity{
+			AccountNumber: DEFAULT_ACCOUNT_NUMBER,
+			User:          &identity.User{Internal: internal, Email: DEFAULT_EMAIL},
+			Internal:      identity.Internal{OrgID: DEFAULT_ORG_ID},
+		},
+	}))
+
+	rr := serveApi(req)
+
+	var section EntitlementsSection
+	if rr.Code == http.StatusOK {
+		Expect(json.Unmarshal(rr.Body.Bytes(), &section)).To(Succeed())
+	}
+	return rr, section
+}
+
+var _ = Describe("Single bundle services", func() {
+	BeforeEach(func() {
+		configOptions.Set(config.Keys.Features, "TestBundle1,TestBundle2")
+		DeferCleanup(configOptions.Set, config.Keys.Features, "")
+		storeBundleInfo([]Bundle{}, "")
+		Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		cache.Clear()
+	})
+
+	Describe("ServicesBundle", func() {
+		AfterEach(func() {
+			GetBundleFeatureStatus = realGetBundleFeatureStatus
+			GetFeatureStatus = realGetFeatureStatus
+		})
+
+		It("should return the entitlement of the bundle", func() {
+			// given
+			GetBundleFeatureStatus = func(_ featureservice.FeatureService, orgID string, bundle string) FeatureResponse {
+				Expect(orgID).To(Equal(DEFAULT_ORG_ID))
+				Expect(bundle).To(Equal("TestBundle1"))
+				return FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}}
+			}
+
+			// when
+			rr, section := bundleRequest("/services/TestBundle1", false)
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(rr.Header().Get("X-Entitlements-Degraded")).To(BeEmpty())
+			Expect(section).To(Equal(EntitlementsSection{IsEntitled: true, IsTrial: false}))
+		})
+
+		It("should return 404 for bundles that are not in the bundle config", func() {
+			rr, _ := bundleRequest("/services/NotABundle", false)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			var errorResp RequestErrorResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+			Expect(errorResp.Error.Message).To(ContainSubstring("NotABundle"))
+		})
+
+		It("should return 404 for bundles left out for the identity type", func() {
+			// given
+			Expect(storeBundleInfo([]Bundle{{Name: "ServiceAccountsOnly", IdentityTypes: []string{"ServiceAccount"}, DisallowedIdentityResult: IdentityResultOmit}}, "")).To(Succeed())
+			GetBundleFeatureStatus = func(_ featureservice.FeatureService, orgID string, bundle string) FeatureResponse {
+				return FeatureResponse{StatusCode: 200}
+			}
+
+			// when
+			rr, _ := bundleRequest("/services/ServiceAccountsOnly", false)
+
+			// then
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			var errorResp RequestErrorResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+			Expect(errorResp.Error.Message).To(ContainSubstring("does not apply to identity type"))
+		})
+
+		It("should set the degraded headers like /services", func() {
+			// given
+			GetBundleFeatureStatus = func(_ featureservice.FeatureService, orgID string, bundle string) FeatureResponse {
+				return FeatureResponse{StatusCode: 503, Stale: true, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}}
+			}
+
+			// when
+			rr, section := bundleRequest("/services/TestBundle1", false)
+
+			// then
+			Expect(rr.Header().Get("X-Entitlements-Degraded")).To(Equal("true"))
+			Expect(rr.Header().Get("X-Entitlements-Degraded-Status")).To(Equal("503"))
+			Expect(rr.Header().Get("X-Entitlements-Stale")).To(Equal("true"))
+			Expect(section.IsEntitled).To(BeTrue())
+		})
+
+		It("should fetch the org's full feature status when a trial was activated", func() {
+			// given
+			trialActivatedRefreshes.Clear()
+			GetBundleFeatureStatus = func(_ featureservice.FeatureService, orgID string, bundle string) FeatureResponse {
+				Fail("the single bundle lookup must not be used for trial_activated=true")
+				return FeatureResponse{}
+			}
+			GetFeatureStatus = func(params GetFeatureStatusParams) FeatureResponse {
+				Expect(params.ForceFreshData).To(BeTrue())
+				return FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle2"}}}}
+			}
+
+			// when
+			_, section := bundleRequest("/services/TestBundle2?trial_activated=true", false)
+
+			// then
+			Expect(section.IsEntitled).To(BeTrue())
+		})
+
+		It("should only explain to internal users", func() {
+			GetBundleFeatureStatus = func(_ featureservice.FeatureService, orgID string, bundle string) FeatureResponse {
+				return FeatureResponse{StatusCode: 200}
+			}
+
+			rr, _ := bundleRequest("/services/TestBundle1?explain=true", false)
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+
+			rr, section := bundleRequest("/services/TestBundle1?explain=true", true)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(section.Explanation).NotTo(BeNil())
+		})
+	})
+
+	Describe("GetBundleFeatureStatus", func() {
+		var subsServer *ghttp.Server
+
+		BeforeEach(func() {
+			subsServer = ghttp.NewServer()
+			subsServer.Writer = GinkgoWriter
+			configOptions.SetDefault(config.Keys.SubsHost, subsServer.URL())
+		})
+
+		AfterEach(func() {
+			subsServer.Close()
+		})
+
+		It("should only request the bundle's features when the org is not cached, and cache them for the bundle", func() {
+			// given
+			lastKnownGood.Clear()
+			subsServer.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", configOptions.GetString(config.Keys.FeatureStatusAPIPath), "features=TestBundle1&accountId="+DEFAULT_ORG_ID),
+				ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestBundle1"}]}`),
+			))
+
+			// when
+			res := GetBundleFeatureStatus(featureservice.NewClient(), DEFAULT_ORG_ID, "TestBundle1")
+			cached := GetBundleFeatureStatus(featureservice.NewClient(), DEFAULT_ORG_ID, "TestBundle1")
+
+			// then
+			Expect(res.Data.Features).To(HaveExactElements(HaveField("Name", "TestBundle1")))
+			Expect(cached.CacheHit).To(BeTrue())
+			Expect(subsServer.ReceivedRequests()).To(HaveLen(1))
+			Expect(cache.Get(DEFAULT_ORG_ID)).To(BeNil(), "a single bundle's features are not the org's feature status")
+			Expect(lastKnownGood.Get(DEFAULT_ORG_ID)).To(BeNil())
+		})
+
+		It("should use the org's cached feature status", func() {
+			// given
+			cache.Set(DEFAULT_ORG_ID, featurecache.Entry{Status: FeatureStatus{Features: []Feature{{Name: "TestBundle2"}}}}, time.Hour)
+
+			// when
+			res := GetBundleFeatureStatus(featureservice.NewClient(), DEFAULT_ORG_ID, "TestBundle1")
+
+			// then
+			Expect(res.CacheHit).To(BeTrue())
+			Expect(res.Data.Features).To(HaveExactElements(HaveField("Name", "TestBundle2")))
+			Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("should not call the feature service for bundles that need no features", func() {
+			res := GetBundleFeatureStatus(featureservice.NewClient(), DEFAULT_ORG_ID, "TestBundle4")
+
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("should be evicted with the org", func() {
+			// given
+			cache.Set(bundleCacheKey(DEFAULT_ORG_ID, "TestBundle1"), featurecache.Entry{}, time.Hour)
+
+			// when
+			evicted, err := evictFeatureStatus(DEFAULT_ORG_ID)
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(evicted).To(BeFalse())
+			Expect(cache.Get(bundleCacheKey(DEFAULT_ORG_ID, "TestBundle1"))).To(BeNil())
+		})
+
+		It("should be evicted with the org after the bundle was removed from the config", func() {
+			// given
+			cache.Set(bundleCacheKey(DEFAULT_ORG_ID, "RemovedBundle"), featurecache.Entry{}, time.Hour)
+
+			// when
+			_, err := evictFeatureStatus(DEFAULT_ORG_ID)
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(cache.Get(bundleCacheKey(DEFAULT_ORG_ID, "RemovedBundle"))).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// lastKnownGood holds the most recent successful feature service result per org. It outlives the
+// regular cache entry by the stale window so it can be served when the feature service is down.
+var lastKnownGood = newFeatureStatusCache("last_known_good")
+
+// backgroundRefreshes records orgs with a recent background refresh attempt, so a stale org is
+// refreshed at most once per refresh interval no matter how many requests it receives
+var backgroundRefreshes = ccache.New(ccache.Configure[struct{}]())
+var backgroundRefreshMu sync.Mutex
+
+// backgroundRefreshesRunning tracks the background refreshes in flight, see waitForBackgroundRefreshes
+var backgroundRefreshesRunning sync.WaitGroup
+
+// failureStreaks counts the failed lookups in a row for each cache key, fail-closed results back off with it
+var failureStreaks = ccache.New(ccache.Configure[int]())
+
+var staleServed = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "feature_status_stale_served_total",
+		Help: "Total number of feature status lookups answered with last known good data",
+	},
+	[]string{"cache_hit"},
+)
+var backgroundRefresh = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "feature_status_background_refresh_total",
+		Help: "Total number of background feature status refreshes for orgs served stale data, by result",
+	},
+	[]string{"result"},
+)
+
+// staleWindow is how long past its regular TTL a successful result may still be served when the feature service fails
+func staleWindow() time.Duration {
+	return time.Second * time.Duration(configOptions.GetInt64(config.Keys.SubsCacheStaleWindow))
+}
+
+func staleRefreshInterval() time.Duration {
+	return time.Second * time.Duration(configOptions.GetInt64(config.Keys.SubsCacheStaleRefresh))
+}
+
+func rememberLastKnownGood(orgID string, status types.FeatureStatus, featureSet string) {
+	window := staleWindow()
+	if window <= 0 {
+		return
+	}
+	lastKnownGood.Set(orgID, featurecache.Entry{Status: status, Outcome: types.FeatureOutcomeSuccess, FeatureSet: featureSet}, cacheDuration+window)
+}
+
+// failClosedTTL returns how long to cache a failed lookup under cacheKey. The TTL of the failure's
+// outcome doubles with every failure in a row for the key, up to ENT_SUBS_CACHE_NEGATIVE_MAX_TTL_SECONDS,
+// so a short blip is retried within seconds while a lasting outage is not called every few seconds.
+func failClosedTTL(cacheKey string, outcome string) time.Duration {
+	base := configOptions.GetInt64(config.Keys.SubsCacheErrorTTL)
+	if outcome == types.FeatureOutcomeNon200 || outcome == types.FeatureOutcomeInvalidResponse {
+		base = configOptions.GetInt64(config.Keys.SubsCacheNon200TTL)
+	}
+	maxTTL := time.Second * time.Duration(configOptions.GetInt64(config.Keys.SubsCacheNegativeMaxTTL))
+
+	streak := 1
+	if item := failureStreaks.Get(cacheKey); item != nil && !item.Expired() {
+		streak = item.Value() + 1
+	}
+
+	ttl := time.Second * time.Duration(base)
+	for range streak - 1 {
+		if ttl >= maxTTL {
+			break
+		}
+		ttl *= 2
+	}
+	ttl = min(ttl, maxTTL)
+
+	// a failure soon after this result expires continues the streak, a longer gap starts it over
+	failureStreaks.Set(cacheKey, streak, 2*ttl)
+	return ttl
+}
+
+// resetFailureStreak starts the backoff of a cache key over after a successful lookup
+func resetFailureStreak(cacheKey string) {
+	failureStreaks.Delete(cacheKey)
+}
+
+// serveStaleOrFailClosed decides what to cache under cacheKey for featureSet and return after a failed
+// feature service call. Orgs with a last known good result within the stale window keep it, marked as
+// stale, until the window closes. Orgs without one fail closed for the regular TTL.
+func serveStaleOrFailClosed(orgID string, cacheKey string, featureSet string, res types.FeatureResponse) types.FeatureResponse {
+	if entry := lastKnownGood.Get(orgID); entry != nil && staleWindow() > 0 {
+		cache.Set(cacheKey, featurecache.Entry{Status: entry.Status, Stale: true, StoredAt: entry.StoredAt, Outcome: res.Outcome, FeatureSet: featureSet}, entry.TTL())
+		staleServed.WithLabelValues(strconv.FormatBool(false)).Inc()
+
+		res.Data = entry.Status
+		res.Stale = true
+		return res
+	}
+
+	// cache fail-closed state to avoid repeated downstream calls until TTL expires
+	cache.Set(cacheKey, featurecache.Entry{Outcome: res.Outcome, FeatureSet: featureSet}, failClosedTTL(cacheKey, res.Outcome))
+	return res
+}
+
+// refreshInBackground fetches fresh data for an org that is being served stale data without
+// making the caller wait on the feature service
+func refreshInBackground(features featureservice.FeatureService, orgID string) {
+	staleServed.WithLabelValues(strconv.FormatBool(true)).Inc()
+
+	backgroundRefreshMu.Lock()
+	if item := backgroundRefreshes.Get(orgID); item != nil && !item.Expired() {
+		backgroundRefreshMu.Unlock()
+		return
+	}
+	backgroundRefreshes.Set(orgID, struct{}{}, staleRefreshInterval())
+	backgroundRefreshMu.Unlock()
+
+	backgroundRefreshesRunning.Go(func() {
+		res := fetchFeatureStatusShared(features, orgID, false)
+		if res.Error != nil || res.StatusCode != http.StatusOK {
+			backgroundRefresh.WithLabelValues("failure").Inc()
+			l.Log.WithFields(logrus.Fields{"org_id": orgID, "code": res.StatusCode, "error": res.Error, "stale": res.Stale}).Warn("background feature status refresh failed")
+			return
+		}
+
+		backgroundRefresh.WithLabelValues("success").Inc()
+		backgroundRefreshes.Delete(orgID)
+	})
+}
+
+// waitForBackgroundRefreshes blocks until every background refresh started so far has finished
+func waitForBackgroundRefreshes() {
+	backgroundRefreshesRunning.Wait()
+}
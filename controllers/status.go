@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/RedHatInsights/entitlements-api-go/breaker"
 	"github.com/RedHatInsights/entitlements-api-go/config"
 	"github.com/go-chi/chi/v5"
 )
@@ -17,9 +19,25 @@ type spec struct {
 	Info info `json:"info"`
 }
 
+type bundleConfigStatus struct {
+	Hash       string             `json:"hash"`
+	LoadedAt   time.Time          `json:"loadedAt"`
+	LastReload bundleReloadStatus `json:"lastReload"`
+}
+
+type overridesConfigStatus struct {
+	Hash       string             `json:"hash"`
+	LoadedAt   time.Time          `json:"loadedAt"`
+	Count      int                `json:"count"`
+	LastReload bundleReloadStatus `json:"lastReload"`
+}
+
 type statusInfo struct {
-	APIVersion string `json:"apiVersion"`
-	Commit     string `json:"commit"`
+	APIVersion            string                `json:"apiVersion"`
+	Commit                string                `json:"commit"`
+	BundleConfig          bundleConfigStatus    `json:"bundleConfig"`
+	OverridesConfig       overridesConfigStatus `json:"overridesConfig"`
+	FeatureServiceBreaker breaker.Status        `json:"featureServiceBreaker"`
 }
 
 func buildStatus() statusInfo {
@@ -41,6 +59,23 @@ func buildStatus() statusInfo {
 	status.APIVersion = apiVersion
 	status.Commit = os.Getenv("OPENSHIFT_BUILD_COMMIT")
 
+	bundles := getBundleState()
+	status.BundleConfig = bundleConfigStatus{
+		Hash:       bundles.hash,
+		LoadedAt:   bundles.loadedAt,
+		LastReload: getLastReload(),
+	}
+
+	overrides := getOverridesState()
+	status.OverridesConfig = overridesConfigStatus{
+		Hash:       overrides.hash,
+		LoadedAt:   overrides.loadedAt,
+		Count:      overrides.count,
+		LastReload: getLastOverridesReload(),
+	}
+
+	status.FeatureServiceBreaker = featureServiceBreaker.Status()
+
 	return status
 }
 
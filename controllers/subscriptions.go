@@ -1,119 +1,100 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"regexp"
 	"slices"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/breaker"
 	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
 	l "github.com/RedHatInsights/entitlements-api-go/logger"
 	"github.com/RedHatInsights/entitlements-api-go/types"
 	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
 
 	"github.com/getsentry/sentry-go"
-	"github.com/karlseguin/ccache/v3"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
+	"golang.org/x/sync/singleflight"
 )
 
 var configOptions = config.GetConfig().Options
-var cache = ccache.New(
-	ccache.Configure[types.FeatureStatus]().
-		MaxSize(configOptions.GetInt64(config.Keys.SubsCacheMaxSize)).
-		PercentToPrune(uint8(configOptions.GetUint32(config.Keys.SubsCacheItemPrune))),
-)
+var cache = newFeatureStatusCache("feature_status")
 var cacheDuration = time.Second * time.Duration(configOptions.GetInt64(config.Keys.SubsCacheDuration))
+var featureStatusRequests singleflight.Group
 
-var bundleInfo []types.Bundle
-var featuresQuery string
-var paidFeatureSuffix string
+var paidFeatureSuffix = configOptions.GetString(config.Keys.PaidFeatureSuffix)
 var subsFailure = promauto.NewCounterVec(
 	prometheus.CounterOpts{
 		Name: "it_feature_service_failure",
-		Help: "Total number of IT feature service failures",
+		Help: "Total number of IT feature service failures by status code, or invalid_response for a 200 that could not be decoded",
 	},
 	[]string{"code"},
 )
+var subsDeduplicated = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "it_feature_service_deduplicated",
+	Help: "Total number of feature service lookups that shared an in-flight request for the same org instead of making their own",
+})
 var subsTimeHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
 	Name:    "it_feature_service_time_taken",
 	Help:    "Feature service latency distributions.",
 	Buckets: prometheus.LinearBuckets(0.25, 0.25, 20),
 })
 
-type GetServicesParams struct {
-	IncludeBundles []string
-	ExcludeBundles []string
-	TrialActivated bool
+// newFeatureStatusCache constructs the named feature status cache with the configured backend
+func newFeatureStatusCache(name string) featurecache.Cache {
+	c, err := featurecache.New(name)
+	if err != nil {
+		panic(fmt.Sprintf("Error constructing %s cache: [%s]", name, err))
+	}
+	return c
 }
 
-const (
-	IncludeBundlesParamKey string = "include_bundles"
-	ExcludeBundlesParamKey string = "exclude_bundles"
-	TrialActivatedParamKey string = "trial_activated"
-)
-
 type GetFeatureStatusParams struct {
+	// FeatureService is the Feature Service the feature status is fetched from when it is not cached
+	FeatureService featureservice.FeatureService
 	OrgId          string
 	ForceFreshData bool
 }
 
-// SetBundleInfo sets the bundle information fetched from the YAML
-func SetBundleInfo(yamlFilePath string) error {
-	bundlesYaml, err := os.ReadFile(yamlFilePath)
-
-	if err != nil {
-		sentry.CaptureException(err)
-		return err
-	}
-
-	err = yaml.Unmarshal([]byte(bundlesYaml), &bundleInfo)
-	if err != nil {
-		sentry.CaptureException(err)
-		return err
-	}
-
-	return nil
-}
-
-func setFeaturesQuery() {
-	features := strings.Split(configOptions.GetString(config.Keys.Features), ",")
-	paidFeatureSuffix = configOptions.GetString(config.Keys.PaidFeatureSuffix)
-
-	var skuBasedFeatures []string
-	for _, bundle := range bundleInfo {
-		if slices.Contains(features, bundle.Name) && bundle.IsSkuBased() {
-			skuBasedFeatures = append(skuBasedFeatures, bundle.Name)
-
-			if bundle.IsPaid() {
-				skuBasedFeatures = append(skuBasedFeatures, bundle.Name+paidFeatureSuffix)
-			}
-		}
+// cachedFeatureStatus returns the feature status cached under key, or nil if there is none or it was
+// requested for other features than the loaded bundle config asks for
+func cachedFeatureStatus(key string) *featurecache.Entry {
+	entry := cache.Get(key)
+	if entry == nil || !entry.RequestedFor(getBundleState().featureSet) {
+		return nil
 	}
-
-	featuresQuery = "?features=" + strings.Join(skuBasedFeatures, "&features=")
+	return entry
 }
 
 // GetFeatureStatus calls the IT feature service features endpoint and returns the entitlements for specified features/bundles
 var GetFeatureStatus = func(params GetFeatureStatusParams) types.FeatureResponse {
 	orgID := params.OrgId
-	item := cache.Get(orgID)
+	cached := cachedFeatureStatus(orgID)
 	entitleAll := configOptions.GetString(config.Keys.EntitleAll)
 
-	if item != nil && !item.Expired() && !params.ForceFreshData {
+	if cached != nil && !params.ForceFreshData {
+		if cached.Stale {
+			// keep serving the last known good result while we try to recover it in the background
+			refreshInBackground(params.FeatureService, orgID)
+		}
+
 		return types.FeatureResponse{
 			StatusCode: 200,
-			Data:       item.Value(),
+			Data:       cached.Status,
 			CacheHit:   true,
+			Stale:      cached.Stale,
+			Outcome:    cached.LookupOutcome(),
+			ExpiresAt:  cached.ExpiresAt,
 		}
 	}
 
@@ -125,78 +106,139 @@ var GetFeatureStatus = func(params GetFeatureStatusParams) types.FeatureResponse
 		}
 	}
 
-	if featuresQuery == "" { // build the static part of our query only once
-		setFeaturesQuery()
+	return fetchFeatureStatusShared(params.FeatureService, orgID, params.ForceFreshData)
+}
+
+// fetchFeatureStatusShared collapses concurrent lookups for the same org into a single feature service
+// request whose result is shared by every caller. Forced lookups are only shared with other forced
+// lookups so they never receive a result that was requested before e.g. a trial was activated.
+func fetchFeatureStatusShared(features featureservice.FeatureService, orgID string, forceFreshData bool) types.FeatureResponse {
+	key := orgID
+	if forceFreshData {
+		key = "fresh:" + orgID
+	}
+
+	executed := false
+	res, _, _ := featureStatusRequests.Do(key, func() (interface{}, error) {
+		executed = true
+		return fetchFeatureStatus(features, orgID), nil
+	})
+
+	if !executed {
+		subsDeduplicated.Inc()
 	}
 
-	req := fmt.Sprintf("%s%s%s&accountId=%s",
-			configOptions.GetString(config.Keys.SubsHost),
-			configOptions.GetString(config.Keys.FeatureStatusAPIPath),
-			featuresQuery,
-			orgID,
-		)
+	return res.(types.FeatureResponse)
+}
 
-	resp, err := getClient().Get(req)
+// fetchFeatureStatus requests the feature status for an org from the feature service and caches the outcome.
+// A change to the org's bundles since its previous feature status is published as an event.
+func fetchFeatureStatus(features featureservice.FeatureService, orgID string) types.FeatureResponse {
+	state := getBundleState()
+	res := requestFeatureStatus(features, orgID, state.requestedFeatures)
+	if res.Error != nil || res.StatusCode != 200 {
+		return serveStaleOrFailClosed(orgID, orgID, state.featureSet, res)
+	}
 
+	previous := previousFeatureStatus(orgID, state.featureSet)
+	cache.Set(orgID, featurecache.Entry{Status: res.Data, Outcome: res.Outcome, FeatureSet: state.featureSet}, cacheDuration)
+	res.ExpiresAt = time.Now().Add(cacheDuration)
+	resetFailureStreak(orgID)
+	rememberLastKnownGood(orgID, res.Data, state.featureSet)
+	publishEntitlementChanges(orgID, previous, res.Data)
+	return res
+}
+
+// requestFeatureStatus asks the feature service for the requested features of an org, without caching
+// the outcome. The lookup is shared by every caller waiting on it, so it is not bound to the context of
+// any one request and only has the client's deadline.
+func requestFeatureStatus(features featureservice.FeatureService, orgID string, requested []string) types.FeatureResponse {
+	done, err := featureServiceBreaker.Allow()
 	if err != nil {
-		sentry.CaptureException(err)
-		// cache fail-closed state to avoid repeated downstream calls until TTL expires
-		cache.Set(orgID, types.FeatureStatus{}, cacheDuration)
 		return types.FeatureResponse{
 			StatusCode: 0,
 			Error:      err,
 			Data:       types.FeatureStatus{},
 			CacheHit:   false,
-			Url:        req,
+			Outcome:    types.FeatureOutcomeUpstreamError,
 		}
 	}
 
-	if resp.StatusCode != 200 {
-		defer resp.Body.Close()
-		body, _ := io.ReadAll(resp.Body)
-		// cache fail-closed state to avoid repeated downstream calls until TTL expires
-		cache.Set(orgID, types.FeatureStatus{}, cacheDuration)
+	status, err := features.GetFeatureStatus(context.Background(), orgID, requested)
+	done(isFeatureServiceFailure(err))
+
+	var transportErr *featureservice.TransportError
+	var statusErr *featureservice.StatusError
+	var decodeErr *featureservice.DecodeError
+	switch {
+	case err == nil:
 		return types.FeatureResponse{
-			StatusCode: resp.StatusCode,
-			Body:       string(body),
+			StatusCode: http.StatusOK,
+			Data:       status,
+			CacheHit:   false,
+			Outcome:    types.FeatureOutcomeSuccess,
+		}
+	case errors.As(err, &statusErr):
+		return types.FeatureResponse{
+			StatusCode: statusErr.StatusCode,
+			Body:       statusErr.Body,
 			Error:      nil,
 			Data:       types.FeatureStatus{},
 			CacheHit:   false,
-			Url:        req,
+			Url:        statusErr.URL,
+			Outcome:    types.FeatureOutcomeNon200,
+		}
+	case errors.As(err, &decodeErr):
+		// the feature service answered, but not with features we can decide bundles with. Deciding them
+		// from an empty feature status would look like an org without entitlements rather than a failure.
+		return types.FeatureResponse{
+			StatusCode: http.StatusOK,
+			Body:       decodeErr.Body,
+			Error:      err,
+			Data:       types.FeatureStatus{},
+			CacheHit:   false,
+			Url:        decodeErr.URL,
+			Outcome:    types.FeatureOutcomeInvalidResponse,
+		}
+	default:
+		sentry.CaptureException(err)
+		res := types.FeatureResponse{
+			StatusCode: 0,
+			Error:      err,
+			Data:       types.FeatureStatus{},
+			CacheHit:   false,
+			Outcome:    types.FeatureOutcomeUpstreamError,
 		}
+		if errors.As(err, &transportErr) {
+			res.Url = transportErr.URL
+		}
+		return res
 	}
+}
 
-	defer resp.Body.Close()
-
-	// Unmarshaling response from Feature service
-	body, _ := io.ReadAll(resp.Body)
-	var FeatureStatus types.FeatureStatus
-	json.Unmarshal(body, &FeatureStatus)
-
-	cache.Set(orgID, FeatureStatus, cacheDuration)
-
-	return types.FeatureResponse{
-		StatusCode: resp.StatusCode,
-		Data:       FeatureStatus,
-		CacheHit:   false,
-		Url:        req,
+// isFeatureServiceFailure reports whether err counts against the feature service breaker: the feature
+// service could not be reached or failed to answer
+func isFeatureServiceFailure(err error) bool {
+	var statusErr *featureservice.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
 	}
+	var decodeErr *featureservice.DecodeError
+	return err != nil && !errors.As(err, &decodeErr)
 }
 
-func failOnDependencyError(errMsg string, res types.FeatureResponse, w http.ResponseWriter) {
-	dependencyError := types.DependencyErrorDetails{
-		DependencyFailure: true,
-		Service:           "Feature Service",
-		Status:            res.StatusCode,
-		Endpoint:          configOptions.GetString(config.Keys.SubsHost),
-		Message:           errMsg,
+// dependencyError builds the DependencyErrorResponse returned when the Feature Service failed with status
+func dependencyError(errMsg string, status int) api.DependencyErrorResponse {
+	subsFailure.WithLabelValues(strconv.Itoa(status)).Inc()
+	return api.DependencyErrorResponse{
+		Error: &api.DependencyErrorDetails{
+			DependencyFailure: toPtr(true),
+			Service:           toPtr("Feature Service"),
+			Status:            toPtr(status),
+			Endpoint:          toPtr(configOptions.GetString(config.Keys.SubsHost)),
+			Message:           toPtr(errMsg),
+		},
 	}
-
-	errorResponse := types.DependencyErrorResponse{Error: dependencyError}
-	errorResponsejson, _ := json.Marshal(errorResponse)
-
-	subsFailure.WithLabelValues(strconv.Itoa(res.StatusCode)).Inc()
-	http.Error(w, string(errorResponsejson), 500)
 }
 
 func setBundlePayload(entitle bool, trial bool) types.EntitlementsSection {
@@ -205,181 +247,257 @@ func setBundlePayload(entitle bool, trial bool) types.EntitlementsSection {
 
 // Represents a fail-closed state (empty feature set cached after failure).
 func isCachedFailClosed(res types.FeatureResponse) bool {
-	return res.CacheHit && len(res.Data.Features) == 0
+	return res.CacheHit && !res.Stale && res.Outcome != types.FeatureOutcomeSuccess
 }
 
-// Services the handler for GETs to /api/entitlements/v1/services/
-func Services() func(http.ResponseWriter, *http.Request) {
-	return func(w http.ResponseWriter, req *http.Request) {
-		start := time.Now()
-		idObj := identity.GetIdentity(req.Context()).Identity
-		orgId := idObj.Internal.OrgID
-
-		queryParams := GetServicesParams{
-			IncludeBundles: filtersFromParams(req, IncludeBundlesParamKey),
-			ExcludeBundles: filtersFromParams(req, ExcludeBundlesParamKey),
-			TrialActivated: boolFromParams(req, TrialActivatedParamKey),
-		}
-		subscriptions := GetFeatureStatus(
-			GetFeatureStatusParams{
-				OrgId:          orgId,
-				ForceFreshData: queryParams.TrialActivated,
-			},
-		)
-
-		subscriptionsMap := make(map[string]types.Feature)
-		for _, feature := range subscriptions.Data.Features {
-			subscriptionsMap[feature.Name] = feature
-		}
+// GetServices decides every bundle for the identity making the request
+func (s ServicesApi) GetServices(ctx context.Context, request api.GetServicesRequestObject) (api.GetServicesResponseObject, error) {
+	start := time.Now()
+	idObj := identity.GetIdentity(ctx).Identity
+	orgId := idObj.Internal.OrgID
+	params := request.Params
 
-		degraded := false
-		if subscriptions.Error != nil {
-			errMsg := "Unexpected error while talking to Feature Service"
-			l.Log.WithFields(logrus.Fields{"error": subscriptions.Error}).Error(errMsg)
-			sentry.WithScope(func(scope *sentry.Scope) {
-				scope.SetTag("response_body", subscriptions.Body)
-				scope.SetTag("response_status", strconv.Itoa(subscriptions.StatusCode))
-				scope.SetTag("url", subscriptions.Url)
-				sentry.CaptureException(fmt.Errorf("%s : %w", errMsg, subscriptions.Error))
-			})
-			// the request is degraded because we received an error from the feature service
-			degraded = true
-			subsFailure.WithLabelValues(strconv.Itoa(subscriptions.StatusCode)).Inc()
-		}
+	explain := fromPtr(params.Explain)
+	if explain && !canExplain(idObj) {
+		return api.GetServices403JSONResponse(requestError(http.StatusForbidden, "explain=true is only available to internal users")), nil
+	}
 
-		accNum := idObj.AccountNumber
+	subscriptions := GetFeatureStatus(
+		GetFeatureStatusParams{
+			FeatureService: s.features,
+			OrgId:          orgId,
+			ForceFreshData: forceFreshData(orgId, fromPtr(params.TrialActivated)),
+		},
+	)
+
+	degraded := featureStatusDegraded(subscriptions)
+
+	subsTimeTaken := time.Since(start).Seconds()
+	l.Log.WithFields(logrus.Fields{
+		"subs_call_duration": subsTimeTaken,
+		"cache_hit":          subscriptions.CacheHit,
+		"url":                subscriptions.Url,
+		"org_id":             orgId,
+	}).Info("feature service call complete")
+	subsTimeHistogram.Observe(subsTimeTaken)
+
+	inputs := identityInputs(idObj, subscriptions, degraded)
+	entitlements := evaluateBundles(orgId, inputs, fromPtr(params.IncludeBundles), fromPtr(params.ExcludeBundles), explain)
+
+	obj, err := json.Marshal(entitlements)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling entitlements: %w", err)
+	}
 
-		// For Service Accounts, User field is nil
-		isInternal := false
-		validEmailMatch := false
-		if idObj.User != nil {
-			isInternal = idObj.User.Internal
-			validEmailMatch, _ = regexp.MatchString(`^.*@redhat.com$`, idObj.User.Email)
-		}
+	return servicesResponse{
+		entitlementsResponse: newEntitlementsResponse(obj, idObj, subscriptions, degraded),
+		ifNoneMatch:          fromPtr(params.IfNoneMatch),
+	}, nil
+}
 
-		validAccNum := !(accNum == "" || accNum == "-1")
-		validOrgId := !(orgId == "" || orgId == "-1")
-
-		include_filter := queryParams.IncludeBundles
-		exclude_filter := queryParams.ExcludeBundles
-
-		subsTimeTaken := time.Since(start).Seconds()
-		l.Log.WithFields(logrus.Fields{
-			"subs_call_duration": subsTimeTaken,
-			"cache_hit":          subscriptions.CacheHit,
-			"url":                subscriptions.Url,
-			"org_id":             orgId,
-		}).Info("feature service call complete")
-		subsTimeHistogram.Observe(subsTimeTaken)
-
-		if subscriptions.Error == nil && subscriptions.StatusCode != 200 {
-			errMsg := "Got back a non 200 status code from Feature Service"
-			l.Log.WithFields(logrus.Fields{"code": subscriptions.StatusCode, "body": subscriptions.Body}).Error(errMsg)
-
-			sentry.WithScope(func(scope *sentry.Scope) {
-				scope.SetTag("response_body", subscriptions.Body)
-				scope.SetTag("response_status", strconv.Itoa(subscriptions.StatusCode))
-				scope.SetTag("url", subscriptions.Url)
-				sentry.CaptureException(errors.New(errMsg))
-			})
-
-			// the request is degraded because we received a non-200 from the feature service
-			degraded = true
-			subsFailure.WithLabelValues(strconv.Itoa(subscriptions.StatusCode)).Inc()
-		}
+// identityInputs builds the inputs for deciding bundles for the identity making a request
+func identityInputs(idObj identity.Identity, subscriptions types.FeatureResponse, degraded bool) bundleInputs {
+	features, featureDates := indexFeatures(subscriptions.Data)
+	orgId := idObj.Internal.OrgID
+	accNum := idObj.AccountNumber
+
+	// For Service Accounts, User field is nil
+	isInternal := false
+	isOrgAdmin := false
+	email := ""
+	if idObj.User != nil {
+		isInternal = idObj.User.Internal
+		isOrgAdmin = idObj.User.OrgAdmin
+		email = idObj.User.Email
+	}
+	defaultPolicy, _ := getInternalPolicies().Lookup(evaluator.DefaultInternalPolicy)
+
+	validAccNum := !(accNum == "" || accNum == "-1")
+	validOrgId := !(orgId == "" || orgId == "-1")
+
+	return bundleInputs{
+		facts: evaluator.Facts{
+			Features:           features,
+			IdentityType:       idObj.Type,
+			OrgID:              orgId,
+			ValidAccountNumber: validAccNum,
+			ValidOrgID:         validOrgId,
+			Internal:           isInternal,
+			Email:              email,
+			RedHatEmail:        defaultPolicy.EmailAllowed(email),
+			OrgAdmin:           isOrgAdmin,
+		},
+		featureDates:  featureDates,
+		featureSource: featureSource(subscriptions, degraded),
+		entitleAll:    configOptions.GetBool(config.Keys.EntitleAll),
+		at:            now(),
+	}
+}
 
-		if isCachedFailClosed(subscriptions) {
-			degraded = true
-		}
+// entitlementsResponse is a 200 response with bundles decided for the identity making the request.
+// Its headers are only set when they apply, which the generated responses cannot do.
+type entitlementsResponse struct {
+	body          []byte
+	identityType  string
+	subscriptions types.FeatureResponse
+	degraded      bool
+}
 
-		entitlementsResponse := make(map[string]types.EntitlementsSection)
-		for _, bundle := range bundleInfo {
-			if len(include_filter) > 0 {
-				if !slices.Contains(include_filter, bundle.Name) {
-					continue
-				}
-			} else if len(exclude_filter) > 0 {
-				if slices.Contains(exclude_filter, bundle.Name) {
-					continue
-				}
-			}
+func newEntitlementsResponse(body []byte, idObj identity.Identity, subscriptions types.FeatureResponse, degraded bool) entitlementsResponse {
+	return entitlementsResponse{body: body, identityType: idObj.Type, subscriptions: subscriptions, degraded: degraded}
+}
 
-			isEntitled := true
-			isTrial := false
+func (response entitlementsResponse) setHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	setIdentityTypeHeader(w, response.identityType)
+	if response.degraded {
+		setDegradedHeaders(w, response.subscriptions)
+	}
+}
 
-			entitleAll := configOptions.GetBool(config.Keys.EntitleAll)
-			if entitleAll {
-				entitlementsResponse[bundle.Name] = setBundlePayload(true, false)
-				continue
-			}
+func (response entitlementsResponse) write(w http.ResponseWriter) error {
+	response.setHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(response.body)
+	return err
+}
 
-			if bundle.IsSkuBased() {
-				_, featExists := subscriptionsMap[bundle.Name]
-				isEntitled = featExists
+func (response entitlementsResponse) VisitGetServicesBundleResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
 
-				if isEntitled && bundle.IsPaid() {
-					_, paidFeatExists := subscriptionsMap[bundle.Name+paidFeatureSuffix]
-					isTrial = !paidFeatExists
-				}
-			}
+func (response entitlementsResponse) VisitPostTrialsBundleResponse(w http.ResponseWriter) error {
+	return response.write(w)
+}
 
-			if bundle.UseValidAccNum {
-				isEntitled = validAccNum && isEntitled
-			}
+// setIdentityTypeHeader tells the caller which identity type its bundles were decided for
+func setIdentityTypeHeader(w http.ResponseWriter, identityType string) {
+	if identityType != "" {
+		w.Header().Set("X-Entitlements-Identity-Type", identityType)
+	}
+}
 
-			if bundle.UseValidOrgId {
-				isEntitled = validOrgId && isEntitled
-			}
+// setDegradedHeaders tells the caller that the bundles were decided from a degraded feature status
+func setDegradedHeaders(w http.ResponseWriter, subscriptions types.FeatureResponse) {
+	w.Header().Set("X-Entitlements-Degraded", "true")
+	statusForHeader := subscriptions.StatusCode
+	if subscriptions.CacheHit {
+		statusForHeader = 0
+	}
+	w.Header().Set("X-Entitlements-Degraded-Status", strconv.Itoa(statusForHeader))
+	if subscriptions.Stale {
+		w.Header().Set("X-Entitlements-Stale", "true")
+	}
+}
 
-			if bundle.UseIsInternal {
-				isEntitled = validAccNum && isInternal && validEmailMatch
-			}
-			entitlementsResponse[bundle.Name] = setBundlePayload(isEntitled, isTrial)
-		}
+// indexFeatures returns the names of the features in a feature status, and the features by name
+func indexFeatures(status types.FeatureStatus) (map[string]bool, map[string]types.Feature) {
+	features := make(map[string]bool)
+	featureDates := make(map[string]types.Feature)
+	for _, feature := range status.Features {
+		features[feature.Name] = true
+		featureDates[feature.Name] = feature
+	}
+	return features, featureDates
+}
 
-		obj, err := json.Marshal(entitlementsResponse)
+// featureStatusFailure is a failed Feature Service lookup to report to Sentry
+type featureStatusFailure struct {
+	err  error
+	tags map[string]string
+}
 
-		if err != nil {
-			l.Log.WithFields(logrus.Fields{"error": err}).Error("Unexpected error while unmarshalling JSON data from Subs Service")
-			sentry.CaptureException(err)
-			http.Error(w, http.StatusText(500), 500)
-			return
-		}
+// capture reports the failure to Sentry along with tags describing the request it failed
+func (f *featureStatusFailure) capture(tags map[string]string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(f.tags)
+		scope.SetTags(tags)
+		sentry.CaptureException(f.err)
+	})
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		if degraded {
-			w.Header().Set("X-Entitlements-Degraded", "true")
-			statusForHeader := subscriptions.StatusCode
-			if isCachedFailClosed(subscriptions) {
-				statusForHeader = 0
-			}
-			w.Header().Set("X-Entitlements-Degraded-Status", strconv.Itoa(statusForHeader))
-		}
-		w.Write([]byte(obj))
+// featureStatusDegraded reports and counts failed Feature Service lookups. It returns whether the result
+// is degraded: the lookup failed, or a cached fail-closed or last known good result was served.
+func featureStatusDegraded(subscriptions types.FeatureResponse) bool {
+	degraded, failure := checkFeatureStatus(subscriptions)
+	if failure != nil {
+		failure.capture(nil)
 	}
+	return degraded
 }
 
-func filtersFromParams(req *http.Request, filterName string) []string {
-	var filter []string
-	list := req.URL.Query().Get(filterName)
-	if list != "" {
-		filter = strings.Split(list, ",")
+// checkFeatureStatus logs and counts a failed Feature Service lookup, see featureStatusDegraded. The
+// failure is returned rather than reported so callers looking up many orgs can report it once.
+func checkFeatureStatus(subscriptions types.FeatureResponse) (bool, *featureStatusFailure) {
+	if errors.Is(subscriptions.Error, breaker.ErrOpen) {
+		// the breaker has its own metrics, an open breaker would otherwise report every request it rejects
+		l.Log.Warn("Feature Service circuit breaker is open, skipped the call")
+		return true, nil
 	}
-	return filter
-}
 
-func boolFromParams(req *http.Request, paramName string) bool {
-	strParam := req.URL.Query().Get(paramName)
+	tags := map[string]string{
+		"response_body":   subscriptions.Body,
+		"response_status": strconv.Itoa(subscriptions.StatusCode),
+		"url":             subscriptions.Url,
+	}
 
-	if strParam == "" {
-		return false
+	if subscriptions.Error != nil && subscriptions.Outcome == types.FeatureOutcomeInvalidResponse {
+		errMsg := "Got back a feature status from Feature Service that could not be decoded"
+		l.Log.WithFields(logrus.Fields{"error": subscriptions.Error, "body": subscriptions.Body, "url": subscriptions.Url}).Error(errMsg)
+		tags["outcome"] = subscriptions.Outcome
+		// the request is degraded because the feature service answered with features we cannot trust
+		subsFailure.WithLabelValues(types.FeatureOutcomeInvalidResponse).Inc()
+		return true, &featureStatusFailure{err: fmt.Errorf("%s : %w", errMsg, subscriptions.Error), tags: tags}
 	}
 
-	param, err := strconv.ParseBool(strParam)
+	if subscriptions.Error != nil {
+		errMsg := "Unexpected error while talking to Feature Service"
+		l.Log.WithFields(logrus.Fields{"error": subscriptions.Error}).Error(errMsg)
+		// the request is degraded because we received an error from the feature service
+		subsFailure.WithLabelValues(strconv.Itoa(subscriptions.StatusCode)).Inc()
+		return true, &featureStatusFailure{err: fmt.Errorf("%s : %w", errMsg, subscriptions.Error), tags: tags}
+	}
 
-	if err != nil {
-		return false
+	if subscriptions.StatusCode != 200 {
+		errMsg := "Got back a non 200 status code from Feature Service"
+		l.Log.WithFields(logrus.Fields{"code": subscriptions.StatusCode, "body": subscriptions.Body}).Error(errMsg)
+		// the request is degraded because we received a non-200 from the feature service
+		subsFailure.WithLabelValues(strconv.Itoa(subscriptions.StatusCode)).Inc()
+		return true, &featureStatusFailure{err: errors.New(errMsg), tags: tags}
 	}
 
-	return param
+	return isCachedFailClosed(subscriptions) || subscriptions.Stale, nil
+}
+
+// evaluateBundles decides every bundle that passes the filters for an org, applying the org's overrides
+func evaluateBundles(orgID string, inputs bundleInputs, includeFilter []string, excludeFilter []string, explain bool) map[string]types.EntitlementsSection {
+	bundles := getBundleState()
+	overrides := getOverridesState()
+	entitlementsResponse := make(map[string]types.EntitlementsSection)
+	for _, bundle := range bundles.bundles {
+		if len(includeFilter) > 0 {
+			if !slices.Contains(includeFilter, bundle.Name) {
+				continue
+			}
+		} else if len(excludeFilter) > 0 {
+			if slices.Contains(excludeFilter, bundle.Name) {
+				continue
+			}
+		}
+
+		var section types.EntitlementsSection
+		if bundle.AppliesTo(inputs.facts.IdentityType) {
+			section = evaluateBundle(bundle, bundles.conditions[bundle.Name], inputs, explain)
+		} else if bundle.IdentityResult() == types.IdentityResultOmit {
+			continue
+		} else {
+			section = identityTypeDecision(bundle, inputs, explain)
+		}
+
+		if override, ok := overrides.lookup(orgID, bundle.Name, inputs.at); ok {
+			section = applyOverride(section, bundle.Name, override, explain)
+		}
+		entitlementsResponse[bundle.Name] = section
+	}
+	return entitlementsResponse
 }
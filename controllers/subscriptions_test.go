@@ -9,12 +9,16 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/RedHatInsights/entitlements-api-go/breaker"
 	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
 	. "github.com/RedHatInsights/entitlements-api-go/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
 )
 
@@ -44,11 +48,10 @@ func testRequest(method string, path string, accnum string, orgid string, isinte
 	})
 
 	req = req.WithContext(ctx)
-	rr := httptest.NewRecorder()
 
 	GetFeatureStatus = fakeCaller
 
-	Services()(rr, req)
+	rr := serveApi(req)
 
 	out, err := io.ReadAll(rr.Result().Body)
 	Expect(err).To(BeNil(), "io.ReadAll error was not nil")
@@ -65,6 +68,27 @@ func testRequestWithDefaultOrgId(method string, path string, fakeCaller func(Get
 	return testRequest(method, path, DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, DEFAULT_IS_INTERNAL, DEFAULT_EMAIL, fakeCaller)
 }
 
+// testRequestWithIdentityType makes a /services request as a user of the given identity type
+func testRequestWithIdentityType(path string, identityType string, fakeCaller func(GetFeatureStatusParams) FeatureResponse) (*httptest.ResponseRecorder, map[string]EntitlementsSection, string) {
+	req, err := http.NewRequest("GET", path, nil)
+	Expect(err).To(BeNil(), "NewRequest error was not nil")
+	req = req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{
+		Identity: identity.Identity{
+			AccountNumber: DEFAULT_ACCOUNT_NUMBER,
+			Type:          identityType,
+			User:          &identity.User{Email: DEFAULT_EMAIL},
+			Internal:      identity.Internal{OrgID: DEFAULT_ORG_ID},
+		},
+	}))
+
+	GetFeatureStatus = fakeCaller
+	rr := serveApi(req)
+
+	var ret map[string]EntitlementsSection
+	json.Unmarshal(rr.Body.Bytes(), &ret)
+	return rr, ret, rr.Body.String()
+}
+
 func testRequestWithServiceAccount(method string, path string, accnum string, orgid string, fakeCaller func(GetFeatureStatusParams) FeatureResponse) (*httptest.ResponseRecorder, map[string]EntitlementsSection, string) {
 	req, err := http.NewRequest(method, path, nil)
 	Expect(err).To(BeNil(), "NewRequest error was not nil")
@@ -86,11 +110,10 @@ func testRequestWithServiceAccount(method string, path string, accnum string, or
 	})
 
 	req = req.WithContext(ctx)
-	rr := httptest.NewRecorder()
 
 	GetFeatureStatus = fakeCaller
 
-	Services()(rr, req)
+	rr := serveApi(req)
 
 	out, err := io.ReadAll(rr.Result().Body)
 	Expect(err).To(BeNil(), "io.ReadAll error was not nil")
@@ -103,6 +126,19 @@ func testRequestWithServiceAccount(method string, path string, accnum string, or
 	return rr, ret, string(out)
 }
 
+// requestFeatures reloads the test bundles with the given FEATURES config, so that the Feature Service
+// is asked for those features
+func requestFeatures(features string) {
+	configOptions.Set(config.Keys.Features, features)
+	storeBundleInfo([]Bundle{}, "")
+	Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+	DeferCleanup(func() {
+		configOptions.Set(config.Keys.Features, "")
+		storeBundleInfo([]Bundle{}, "")
+		Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+	})
+}
+
 func fakeGetFeatureStatus(expectedOrgID string, response FeatureResponse) func(GetFeatureStatusParams) FeatureResponse {
 	return func(params GetFeatureStatusParams) FeatureResponse {
 		Expect(expectedOrgID).To(Equal(params.OrgId))
@@ -118,7 +154,7 @@ func expectPass(res *http.Response) {
 var _ = Describe("Services Controller", func() {
 
 	BeforeEach(func() {
-		bundleInfo = []Bundle{}
+		storeBundleInfo([]Bundle{}, "")
 		if err := SetBundleInfo("../test_data/test_bundle.yml"); err != nil {
 			panic("Error in test_bundle.yml")
 		}
@@ -130,20 +166,19 @@ var _ = Describe("Services Controller", func() {
 			Data:       FeatureStatus{},
 			CacheHit:   false,
 		}
-		testRequest("GET", "/", DEFAULT_ACCOUNT_NUMBER, "540155", DEFAULT_IS_INTERNAL, DEFAULT_EMAIL, fakeGetFeatureStatus("540155", fakeResponse))
-		testRequest("GET", "/", DEFAULT_ACCOUNT_NUMBER, "deadbeef12", DEFAULT_IS_INTERNAL, DEFAULT_EMAIL, fakeGetFeatureStatus("deadbeef12", fakeResponse))
+		testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, "540155", DEFAULT_IS_INTERNAL, DEFAULT_EMAIL, fakeGetFeatureStatus("540155", fakeResponse))
+		testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, "deadbeef12", DEFAULT_IS_INTERNAL, DEFAULT_EMAIL, fakeGetFeatureStatus("deadbeef12", fakeResponse))
 	})
 
-	It("should build the subscriptions query with only sku based features", func() {
+	It("should request only sku based features", func() {
 		cfg := config.GetConfig()
 		cfg.Options.Set(config.Keys.Features, "TestBundle1,TestBundle3,TestBundle4,TestBundle5,TestBundle6,TestBundle7")
-		setFeaturesQuery()
-		Expect(featuresQuery).To(BeEquivalentTo("?features=TestBundle1&features=TestBundle6"))
+		Expect(buildRequestedFeatures(getBundleState().bundles)).To(Equal([]string{"TestBundle1", "TestBundle6"}))
 	})
 
 	Context("When bundles have paid and eval SKUs", func() {
 		BeforeEach(func() {
-			bundleInfo = []Bundle{
+			storeBundleInfo([]Bundle{
 				{
 					Name:     "SplitBundle",
 					PaidSkus: []string{"PAID1"},
@@ -153,23 +188,21 @@ var _ = Describe("Services Controller", func() {
 					Name: "RegularBundle",
 					Skus: []string{"SKU1"},
 				},
-			}
+			}, "")
 		})
 
-		It("should include _paid suffix for paid bundles in features query", func() {
+		It("should include _paid suffix for paid bundles in requested features", func() {
 			cfg := config.GetConfig()
 			cfg.Options.Set(config.Keys.Features, "SplitBundle,RegularBundle")
-			setFeaturesQuery()
+			requestedFeatures := buildRequestedFeatures(getBundleState().bundles)
 
-			Expect(featuresQuery).To(ContainSubstring("SplitBundle"))
-			Expect(featuresQuery).To(ContainSubstring("SplitBundle_paid"))
-			Expect(featuresQuery).To(ContainSubstring("RegularBundle"))
+			Expect(requestedFeatures).To(ContainElements("SplitBundle", "SplitBundle_paid", "RegularBundle"))
 		})
 	})
 
 	Context("When the Feature API sends back a non-200", func() {
 		It("should respond 200, mark degraded, and fail closed for SKU-based bundles", func() {
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", func(GetFeatureStatusParams) FeatureResponse {
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", func(GetFeatureStatusParams) FeatureResponse {
 				return FeatureResponse{StatusCode: 503, Data: FeatureStatus{}, CacheHit: false}
 			})
 
@@ -186,7 +219,7 @@ var _ = Describe("Services Controller", func() {
 
 	Context("When the Feature API sends back an error", func() {
 		It("should respond 200, mark degraded, and fail closed for SKU-based bundles", func() {
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", func(GetFeatureStatusParams) FeatureResponse {
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", func(GetFeatureStatusParams) FeatureResponse {
 				return FeatureResponse{StatusCode: 503, Data: FeatureStatus{}, CacheHit: false, Error: errors.New("Sub Failure")}
 			})
 
@@ -203,17 +236,25 @@ var _ = Describe("Services Controller", func() {
 
 	Context("When the bundles.yml has errors", func() {
 		It("should include errors when file is not available", func() {
-			bundleInfo = []Bundle{}
+			storeBundleInfo([]Bundle{}, "")
 			err := SetBundleInfo("no_such_file")
-			Expect(len(bundleInfo)).To(Equal(0))
+			Expect(len(getBundleState().bundles)).To(Equal(0))
 			Expect(err).ToNot(Equal(nil))
 		})
 
 		It("should return error for yaml parse errors", func() {
-			bundleInfo = []Bundle{}
+			storeBundleInfo([]Bundle{}, "")
+			err := SetBundleInfo("../test_data/err_bundle.yml")
+			Expect(len(getBundleState().bundles)).To(Equal(0))
+			Expect(err).ToNot(Equal(nil))
+		})
+
+		It("should keep the previously loaded bundles", func() {
+			previous := getBundleState()
 			err := SetBundleInfo("../test_data/err_bundle.yml")
-			Expect(len(bundleInfo)).To(Equal(0))
 			Expect(err).ToNot(Equal(nil))
+			Expect(getBundleState()).To(BeIdenticalTo(previous))
+			Expect(getLastReload().Result).To(Equal(reloadResultFailure))
 		})
 	})
 
@@ -226,7 +267,7 @@ var _ = Describe("Services Controller", func() {
 
 		It("should give back a valid EntitlementsResponse with bundles using Valid Account Number false", func() {
 			// testing with account number "-1"
-			rr, body, _ := testRequest("GET", "/", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(false))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(false))
@@ -237,7 +278,7 @@ var _ = Describe("Services Controller", func() {
 
 		It("should give back a valid EntitlementsResponse with bundles using Valid Account Number false", func() {
 			// testing with account number ""
-			rr, body, _ := testRequest("GET", "/", "", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(false))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(false))
@@ -256,7 +297,7 @@ var _ = Describe("Services Controller", func() {
 
 		It("should give back a valid EntitlementsResponse with that bundle true", func() {
 			// testing with account number ""
-			rr, body, _ := testRequest("GET", "/", "123456", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "123456", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(false))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(false))
@@ -276,13 +317,13 @@ var _ = Describe("Services Controller", func() {
 
 		Context("When the org_id is invalid", func() {
 			It("should not entitle bundles when a -1 org_id is supplied", func() {
-				rr, body, _ := testRequest("GET", "/", DEFAULT_ACCOUNT_NUMBER, "-1", true, DEFAULT_EMAIL, fakeGetFeatureStatus("-1", fakeResponse))
+				rr, body, _ := testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, "-1", true, DEFAULT_EMAIL, fakeGetFeatureStatus("-1", fakeResponse))
 				expectPass(rr.Result())
 				Expect(body["TestBundle7"].IsEntitled).To(Equal(false))
 			})
 
 			It("should not entitle bundles when a blank org_id is supplied", func() {
-				rr, body, _ := testRequest("GET", "/", DEFAULT_ACCOUNT_NUMBER, "", true, DEFAULT_EMAIL, fakeGetFeatureStatus("", fakeResponse))
+				rr, body, _ := testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, "", true, DEFAULT_EMAIL, fakeGetFeatureStatus("", fakeResponse))
 				expectPass(rr.Result())
 				Expect(body["TestBundle7"].IsEntitled).To(Equal(false))
 			})
@@ -290,7 +331,7 @@ var _ = Describe("Services Controller", func() {
 
 		Context("When the org_id is valid", func() {
 			It("should entitle bundles when a valid org_id is supplied", func() {
-				rr, body, _ := testRequest("GET", "/", "123456", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+				rr, body, _ := testRequest("GET", "/services", "123456", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 				expectPass(rr.Result())
 				Expect(body["TestBundle7"].IsEntitled).To(Equal(true))
 			})
@@ -305,31 +346,31 @@ var _ = Describe("Services Controller", func() {
 		}
 
 		It("should entitle when valid account and principal is internal", func() {
-			rr, body, _ := testRequest("GET", "/", "123456", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "123456", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle5"].IsEntitled).To(Equal(true))
 		})
 
 		It("should not entitle when valid account and principal is internal but email is not @redhat.com", func() {
-			rr, body, _ := testRequest("GET", "/", "123456", DEFAULT_ORG_ID, true, "jdoe@example.com", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "123456", DEFAULT_ORG_ID, true, "jdoe@example.com", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle5"].IsEntitled).To(Equal(false))
 		})
 
 		It("should not entitle when valid account and principal is not internal", func() {
-			rr, body, _ := testRequest("GET", "/", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle5"].IsEntitled).To(Equal(false))
 		})
 
 		It("should not entitle when not a valid account and principal is internal", func() {
-			rr, body, _ := testRequest("GET", "/", "", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle5"].IsEntitled).To(Equal(false))
 		})
 
 		It("should not entitle when not a valid account and principal is internal", func() {
-			rr, body, _ := testRequest("GET", "/", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle5"].IsEntitled).To(Equal(false))
 		})
@@ -354,7 +395,7 @@ var _ = Describe("Services Controller", func() {
 				CacheHit: false,
 			}
 
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(true))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(true))
@@ -364,7 +405,7 @@ var _ = Describe("Services Controller", func() {
 
 	Context("When determining trial status for paid bundles", func() {
 		BeforeEach(func() {
-			bundleInfo = []Bundle{
+			storeBundleInfo([]Bundle{
 				{
 					Name:     "SplitBundle",
 					PaidSkus: []string{"PAID1"},
@@ -374,7 +415,7 @@ var _ = Describe("Services Controller", func() {
 					Name: "RegularBundle",
 					Skus: []string{"SKU1"},
 				},
-			}
+			}, "")
 		})
 
 		It("should set isTrial to false when user has paid SKU", func() {
@@ -393,7 +434,7 @@ var _ = Describe("Services Controller", func() {
 				CacheHit: false,
 			}
 
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["SplitBundle"].IsEntitled).To(Equal(true))
 			Expect(body["SplitBundle"].IsTrial).To(Equal(false))
@@ -412,7 +453,7 @@ var _ = Describe("Services Controller", func() {
 				CacheHit: false,
 			}
 
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["SplitBundle"].IsEntitled).To(Equal(true))
 			Expect(body["SplitBundle"].IsTrial).To(Equal(true))
@@ -427,7 +468,7 @@ var _ = Describe("Services Controller", func() {
 				CacheHit: false,
 			}
 
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["SplitBundle"].IsEntitled).To(Equal(false))
 			Expect(body["SplitBundle"].IsTrial).To(Equal(false))
@@ -446,13 +487,126 @@ var _ = Describe("Services Controller", func() {
 				CacheHit: false,
 			}
 
-			rr, body, _ := testRequestWithDefaultOrgId("GET", "/", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["RegularBundle"].IsEntitled).To(Equal(true))
 			Expect(body["RegularBundle"].IsTrial).To(Equal(false))
 		})
 	})
 
+	Context("When the Feature Service returns start and end dates", func() {
+		BeforeEach(func() {
+			storeBundleInfo([]Bundle{
+				{
+					Name:     "SplitBundle",
+					PaidSkus: []string{"PAID1"},
+					EvalSkus: []string{"EVAL1"},
+				},
+				{
+					Name: "RegularBundle",
+					Skus: []string{"SKU1"},
+				},
+				{
+					Name:           "IdentityBundle",
+					UseValidAccNum: true,
+				},
+			}, "")
+			now = func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+		})
+
+		AfterEach(func() {
+			now = time.Now
+		})
+
+		It("should return the dates of the subscription a bundle is entitled by", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{
+				{Name: "RegularBundle", StartDate: "2024-01-01T00:00:00.000+0000", EndDate: "2025-01-01T00:00:00.000+0000"},
+			}}}
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(*body["RegularBundle"].StartsAt).To(BeTemporally("==", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+			Expect(*body["RegularBundle"].ExpiresAt).To(BeTemporally("==", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+			Expect(body["RegularBundle"].TrialDaysRemaining).To(BeNil())
+		})
+
+		It("should return the days remaining of a trial", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{
+				{Name: "SplitBundle", StartDate: "2024-05-01", EndDate: "2024-06-10T00:00:00Z"},
+			}}}
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["SplitBundle"].IsTrial).To(BeTrue())
+			Expect(*body["SplitBundle"].TrialDaysRemaining).To(Equal(9))
+		})
+
+		It("should not return a negative number of days for an expired trial", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{
+				{Name: "SplitBundle", EndDate: "2024-05-01T00:00:00Z"},
+			}}}
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(*body["SplitBundle"].TrialDaysRemaining).To(Equal(0))
+		})
+
+		It("should return the dates of the paid subscription once the org has one", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{
+				{Name: "SplitBundle", EndDate: "2024-06-10T00:00:00Z"},
+				{Name: "SplitBundle_paid", EndDate: "2025-06-01T00:00:00Z"},
+			}}}
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["SplitBundle"].IsTrial).To(BeFalse())
+			Expect(*body["SplitBundle"].ExpiresAt).To(BeTemporally("==", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)))
+			Expect(body["SplitBundle"].TrialDaysRemaining).To(BeNil())
+		})
+
+		It("should leave out dates that are missing or can't be parsed", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{
+				{Name: "RegularBundle", StartDate: "not a date"},
+			}}}
+
+			// when
+			rr, _, rawJSON := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			expectPass(rr.Result())
+			Expect(rawJSON).ToNot(ContainSubstring("starts_at"))
+			Expect(rawJSON).ToNot(ContainSubstring("expires_at"))
+		})
+
+		It("should not return dates for bundles that are not entitled or not SKU based", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{
+				{Name: "RegularBundle", EndDate: "2025-01-01T00:00:00Z"},
+			}}}
+
+			// when
+			_, body, _ := testRequest("GET", "/services", "-1", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["IdentityBundle"].IsEntitled).To(BeFalse())
+			Expect(body["IdentityBundle"].ExpiresAt).To(BeNil())
+			Expect(body["SplitBundle"].ExpiresAt).To(BeNil())
+		})
+	})
+
 	Context("When the request contains query filters", func() {
 		fakeResponse := FeatureResponse{
 			StatusCode: 200,
@@ -460,7 +614,7 @@ var _ = Describe("Services Controller", func() {
 			CacheHit:   false,
 		}
 		It("should only return bundles included in include_bundles", func() {
-			rr, body, _ := testRequest("GET", "/?include_bundles=TestBundle2,TestBundle3", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services?include_bundles=TestBundle2,TestBundle3", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(len(body)).To(Equal(2))
 			_, found := body["TestBundle1"]
@@ -471,7 +625,7 @@ var _ = Describe("Services Controller", func() {
 			Expect(found).To(BeTrue())
 		})
 		It("should not return bundles included in exclude_bundles", func() {
-			rr, body, _ := testRequest("GET", "/?exclude_bundles=TestBundle2,TestBundle3", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services?exclude_bundles=TestBundle2,TestBundle3", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(len(body)).To(Equal(5))
 			_, found := body["TestBundle1"]
@@ -482,7 +636,7 @@ var _ = Describe("Services Controller", func() {
 			Expect(found).To(BeFalse())
 		})
 		It("should handle single include_filter entries", func() {
-			rr, body, _ := testRequest("GET", "/?include_bundles=TestBundle2", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services?include_bundles=TestBundle2", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(len(body)).To(Equal(1))
 			_, found := body["TestBundle1"]
@@ -493,7 +647,7 @@ var _ = Describe("Services Controller", func() {
 			Expect(found).To(BeFalse())
 		})
 		It("Should handle single exclude_filter entries", func() {
-			rr, body, _ := testRequest("GET", "/?exclude_bundles=TestBundle2", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services?exclude_bundles=TestBundle2", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(len(body)).To(Equal(6))
 			_, found := body["TestBundle1"]
@@ -504,7 +658,7 @@ var _ = Describe("Services Controller", func() {
 			Expect(found).To(BeTrue())
 		})
 		It("should prioritize include_bundles", func() {
-			rr, body, _ := testRequest("GET", "/?include_bundles=TestBundle1,TestBundle2&exclude_bundles=TestBundle2,TestBundle3", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services?include_bundles=TestBundle1,TestBundle2&exclude_bundles=TestBundle2,TestBundle3", "123456", DEFAULT_ORG_ID, false, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(len(body)).To(Equal(2))
 			_, found := body["TestBundle1"]
@@ -527,7 +681,7 @@ var _ = Describe("Services Controller", func() {
 		}
 		It("should skip IT calls and entitle all bundles when true", func() {
 			os.Setenv("ENT_ENTITLE_ALL", "true")
-			rr, body, _ := testRequest("GET", "/", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(true))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(true))
@@ -538,7 +692,7 @@ var _ = Describe("Services Controller", func() {
 
 		It("should return as normal when false", func() {
 			os.Setenv("ENT_ENTITLE_ALL", "false")
-			rr, body, _ := testRequest("GET", "/", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(false))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(false))
@@ -548,7 +702,7 @@ var _ = Describe("Services Controller", func() {
 		})
 
 		It("should return as normal when unset", func() {
-			rr, body, _ := testRequest("GET", "/", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequest("GET", "/services", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 			expectPass(rr.Result())
 			Expect(body["TestBundle1"].IsEntitled).To(Equal(false))
 			Expect(body["TestBundle2"].IsEntitled).To(Equal(false))
@@ -559,6 +713,10 @@ var _ = Describe("Services Controller", func() {
 	})
 
 	Context("The request contains trial_activated", func() {
+		BeforeEach(func() {
+			trialActivatedRefreshes.Clear()
+		})
+
 		When("trial_activated is correctly parsed from the query", func() {
 			dummyResponse := FeatureResponse{
 				StatusCode: 200,
@@ -574,7 +732,7 @@ var _ = Describe("Services Controller", func() {
 
 					return dummyResponse
 				}
-				path := "/"
+				path := "/services"
 
 				// when
 				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
@@ -584,22 +742,24 @@ var _ = Describe("Services Controller", func() {
 				Expect(*actualForceFreshData).To(BeFalse())
 			})
 
-			It("defaults the param to false when its not a valid bool", func() {
+			It("rejects the request when its not a valid bool", func() {
 				// given
-				var actualForceFreshData *bool
+				called := false
 				mockGetFeatureStatus := func(params GetFeatureStatusParams) FeatureResponse {
-					actualForceFreshData = &params.ForceFreshData
+					called = true
 
 					return dummyResponse
 				}
-				path := "/?trial_activated=notABool"
+				path := "/services?trial_activated=notABool"
 
 				// when
-				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
+				rr, _, body := testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
 
 				// then
-				Expect(actualForceFreshData).ToNot(BeNil())
-				Expect(*actualForceFreshData).To(BeFalse())
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+				Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+				Expect(body).To(ContainSubstring("trial_activated"))
+				Expect(called).To(BeFalse())
 			})
 
 			It("set the param to true when its a valid bool", func() {
@@ -610,7 +770,7 @@ var _ = Describe("Services Controller", func() {
 
 					return dummyResponse
 				}
-				path := "/?trial_activated=true"
+				path := "/services?trial_activated=true"
 
 				// when
 				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
@@ -619,6 +779,45 @@ var _ = Describe("Services Controller", func() {
 				Expect(actualForceFreshData).ToNot(BeNil())
 				Expect(*actualForceFreshData).To(BeTrue())
 			})
+
+			It("only bypasses the cache once per interval for an org", func() {
+				// given
+				var forced []bool
+				mockGetFeatureStatus := func(params GetFeatureStatusParams) FeatureResponse {
+					forced = append(forced, params.ForceFreshData)
+
+					return dummyResponse
+				}
+				path := "/services?trial_activated=true"
+
+				// when
+				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
+				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
+				testRequest("GET", path, DEFAULT_ACCOUNT_NUMBER, "another-org", DEFAULT_IS_INTERNAL, DEFAULT_EMAIL, mockGetFeatureStatus)
+
+				// then
+				Expect(forced).To(Equal([]bool{true, false, true}))
+			})
+
+			It("bypasses the cache on every request when the interval is 0", func() {
+				// given
+				configOptions.Set(config.Keys.TrialActivatedInterval, 0)
+				DeferCleanup(configOptions.Set, config.Keys.TrialActivatedInterval, 60)
+				var forced []bool
+				mockGetFeatureStatus := func(params GetFeatureStatusParams) FeatureResponse {
+					forced = append(forced, params.ForceFreshData)
+
+					return dummyResponse
+				}
+				path := "/services?trial_activated=true"
+
+				// when
+				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
+				testRequestWithDefaultOrgId("GET", path, mockGetFeatureStatus)
+
+				// then
+				Expect(forced).To(Equal([]bool{true, true}))
+			})
 		})
 
 		When("trial_activated param is valid", func() {
@@ -626,6 +825,7 @@ var _ = Describe("Services Controller", func() {
 
 			BeforeEach(func() {
 				GetFeatureStatus = realGetFeatureStatus
+				requestFeatures("TestBundle1,TestBundle2")
 
 				// setup mock server
 				subsServer = ghttp.NewServer()
@@ -635,7 +835,7 @@ var _ = Describe("Services Controller", func() {
 				// aka the following request to fill cache
 				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": [
 					{
-						"name":"dummy feature",
+						"name":"TestBundle1",
 						"isEval":false,
 						"entitled":true
 					}
@@ -647,6 +847,7 @@ var _ = Describe("Services Controller", func() {
 
 				// fill cache
 				params := GetFeatureStatusParams{
+					FeatureService: featureservice.NewClient(),
 					OrgId:          DEFAULT_ORG_ID,
 					ForceFreshData: true,
 				}
@@ -660,6 +861,7 @@ var _ = Describe("Services Controller", func() {
 			It("serves cached data when req param is false", func() {
 				// given
 				params := GetFeatureStatusParams{
+					FeatureService: featureservice.NewClient(),
 					OrgId:          DEFAULT_ORG_ID,
 					ForceFreshData: false,
 				}
@@ -670,19 +872,21 @@ var _ = Describe("Services Controller", func() {
 				// then
 				Expect(response).ToNot(BeNil())
 				Expect(response.CacheHit).To(BeTrue())
+				Expect(response.ExpiresAt).To(BeTemporally("~", time.Now().Add(cacheDuration), time.Second))
 				Expect(subsServer.ReceivedRequests()).To(HaveLen(1))
 			})
 
 			It("serves fresh data when req param is true", func() {
 				// given
 				params := GetFeatureStatusParams{
+					FeatureService: featureservice.NewClient(),
 					OrgId:          DEFAULT_ORG_ID,
 					ForceFreshData: true,
 				}
 
 				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": [
 					{
-						"name":"dummy feature 2!",
+						"name":"TestBundle2",
 						"isEval":false,
 						"entitled":true
 					}
@@ -696,15 +900,22 @@ var _ = Describe("Services Controller", func() {
 				Expect(response.CacheHit).To(BeFalse())
 				Expect(response.Data.Features).ToNot(BeNil())
 				Expect(response.Data.Features).To(HaveLen(1))
-				Expect(response.Data.Features[0].Name).To(BeEquivalentTo("dummy feature 2!"))
+				Expect(response.Data.Features[0].Name).To(BeEquivalentTo("TestBundle2"))
+				Expect(response.ExpiresAt).To(BeTemporally("~", time.Now().Add(cacheDuration), time.Second))
 				Expect(subsServer.ReceivedRequests()).To(HaveLen(2))
 			})
 
 			It("caches fail-closed when non-200 is returned and serves cached fail-closed on subsequent call", func() {
+				// given: serving the last known good result is disabled
+				cfg := config.GetConfig().Options
+				cfg.Set(config.Keys.SubsCacheStaleWindow, 0)
+				DeferCleanup(cfg.Set, config.Keys.SubsCacheStaleWindow, 3600)
+
 				// given: next downstream call will fail with 503
 				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusServiceUnavailable, `down`, http.Header{"Content-Type": {"text/plain"}}))
 
 				params := GetFeatureStatusParams{
+					FeatureService: featureservice.NewClient(),
 					OrgId:          DEFAULT_ORG_ID,
 					ForceFreshData: true, // bypass positive cache to force downstream failure and cache fail-closed
 				}
@@ -720,7 +931,7 @@ var _ = Describe("Services Controller", func() {
 				Expect(subsServer.ReceivedRequests()).To(HaveLen(2))
 
 				// when: second call with same params should use cached fail-closed and avoid a downstream call
-				params2 := GetFeatureStatusParams{OrgId: DEFAULT_ORG_ID, ForceFreshData: false}
+				params2 := GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: false}
 				response2 := GetFeatureStatus(params2)
 
 				// then: cached fail-closed used
@@ -730,6 +941,311 @@ var _ = Describe("Services Controller", func() {
 				Expect(response2.StatusCode).To(Equal(200))
 				Expect(subsServer.ReceivedRequests()).To(HaveLen(2))
 			})
+
+			It("caches a feature status that cannot be decoded fail-closed and reports it degraded", func() {
+				// given: serving the last known good result is disabled
+				cfg := config.GetConfig().Options
+				cfg.Set(config.Keys.SubsCacheStaleWindow, 0)
+				DeferCleanup(cfg.Set, config.Keys.SubsCacheStaleWindow, 3600)
+
+				// given: next downstream call answers with a truncated body
+				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestBundle1"`, http.Header{"Content-Type": {"application/json"}}))
+				failures := testutil.ToFloat64(subsFailure.WithLabelValues(FeatureOutcomeInvalidResponse))
+
+				// when
+				response := GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+				degraded := featureStatusDegraded(response)
+				cached := GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID})
+
+				// then
+				Expect(response.Error).ToNot(BeNil())
+				Expect(response.Outcome).To(Equal(FeatureOutcomeInvalidResponse))
+				Expect(response.Data.Features).To(BeEmpty())
+				Expect(degraded).To(BeTrue())
+				Expect(testutil.ToFloat64(subsFailure.WithLabelValues(FeatureOutcomeInvalidResponse))).To(Equal(failures + 1))
+
+				// then: the failure is cached rather than the empty feature status as a success
+				Expect(cached.CacheHit).To(BeTrue())
+				Expect(cached.Outcome).To(Equal(FeatureOutcomeInvalidResponse))
+				Expect(isCachedFailClosed(cached)).To(BeTrue())
+				Expect(subsServer.ReceivedRequests()).To(HaveLen(2))
+			})
+
+			It("treats a feature that was not requested as a feature status that cannot be decoded", func() {
+				// given
+				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestBundle6"}]}`, http.Header{"Content-Type": {"application/json"}}))
+
+				// when
+				response := GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+				// then: the last known good result is served instead
+				Expect(response.Outcome).To(Equal(FeatureOutcomeInvalidResponse))
+				Expect(response.Error).To(MatchError(ContainSubstring(`feature "TestBundle6" was not requested`)))
+				Expect(response.Stale).To(BeTrue())
+				Expect(response.Data.Features).To(HaveExactElements(HaveField("Name", "TestBundle1")))
+			})
+
+			It("serves the last known good result marked stale when non-200 is returned", func() {
+				// given
+				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusServiceUnavailable, `down`, http.Header{"Content-Type": {"text/plain"}}))
+				params := GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true}
+
+				// when
+				response := GetFeatureStatus(params)
+
+				// then
+				Expect(response.Stale).To(BeTrue())
+				Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Data.Features).To(HaveLen(1))
+				Expect(response.Data.Features[0].Name).To(Equal("TestBundle1"))
+			})
+
+			It("serves cached stale data and refreshes it in the background", func() {
+				// given
+				subsServer.AppendHandlers(
+					ghttp.RespondWith(http.StatusServiceUnavailable, `down`, http.Header{"Content-Type": {"text/plain"}}),
+					ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestBundle2"}]}`, http.Header{"Content-Type": {"application/json"}}),
+				)
+				backgroundRefreshes.Delete(DEFAULT_ORG_ID)
+				GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID, ForceFreshData: true})
+
+				// when
+				response := GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID})
+
+				// then: stale data is served straight from the cache
+				Expect(response.CacheHit).To(BeTrue())
+				Expect(response.Stale).To(BeTrue())
+				Expect(response.Data.Features[0].Name).To(Equal("TestBundle1"))
+
+				// then: the background refresh replaces it with fresh data
+				waitForBackgroundRefreshes()
+				Expect(subsServer.ReceivedRequests()).To(HaveLen(3))
+				Expect(GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: DEFAULT_ORG_ID})).To(And(
+					HaveField("Stale", BeFalse()),
+					HaveField("Data.Features", HaveExactElements(HaveField("Name", "TestBundle2"))),
+				))
+			})
+
+			It("fails closed for orgs without a last known good result", func() {
+				// given
+				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusServiceUnavailable, `down`, http.Header{"Content-Type": {"text/plain"}}))
+				params := GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: "neverseen", ForceFreshData: true}
+
+				// when
+				response := GetFeatureStatus(params)
+
+				// then
+				Expect(response.Stale).To(BeFalse())
+				Expect(response.Data.Features).To(BeEmpty())
+			})
+
+			It("caches fail-closed results with their outcome for the outcome's TTL", func() {
+				// given
+				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusServiceUnavailable, `down`, http.Header{"Content-Type": {"text/plain"}}))
+				resetFailureStreak("neverseen-ttl")
+
+				// when
+				GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: "neverseen-ttl", ForceFreshData: true})
+
+				// then
+				entry := cache.Get("neverseen-ttl")
+				Expect(entry.Outcome).To(Equal(FeatureOutcomeNon200))
+				Expect(entry.TTL()).To(BeNumerically("~", time.Minute, time.Second))
+			})
+
+			It("does not treat a cached org without features as fail-closed", func() {
+				// given
+				subsServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": []}`, http.Header{"Content-Type": {"application/json"}}))
+				GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: "no-features", ForceFreshData: true})
+
+				// when
+				response := GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: "no-features"})
+
+				// then
+				Expect(response.CacheHit).To(BeTrue())
+				Expect(response.Outcome).To(Equal(FeatureOutcomeSuccess))
+				Expect(isCachedFailClosed(response)).To(BeFalse())
+				Expect(featureStatusDegraded(response)).To(BeFalse())
+			})
+		})
+	})
+
+	Context("When concurrent lookups for the same org miss the cache", func() {
+		var subsServer *ghttp.Server
+		var release chan struct{}
+
+		BeforeEach(func() {
+			GetFeatureStatus = realGetFeatureStatus
+			requestFeatures("TestBundle1")
+			release = make(chan struct{})
+
+			subsServer = ghttp.NewServer()
+			subsServer.Writer = GinkgoWriter
+			subsServer.SetAllowUnhandledRequests(true)
+			subsServer.AppendHandlers(ghttp.CombineHandlers(
+				func(w http.ResponseWriter, r *http.Request) { <-release },
+				ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestBundle1"}]}`, http.Header{"Content-Type": {"application/json"}}),
+			))
+
+			config.GetConfig().Options.SetDefault(config.Keys.SubsHost, subsServer.URL())
+		})
+
+		AfterEach(func() {
+			subsServer.Close()
+		})
+
+		It("should make a single feature service request and share its result", func() {
+			// given
+			orgID := "coalesced"
+			cache.Delete(orgID)
+			results := make(chan FeatureResponse, 5)
+
+			// when
+			for range 5 {
+				go func() {
+					defer GinkgoRecover()
+					results <- GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: orgID})
+				}()
+			}
+			Eventually(subsServer.ReceivedRequests).Should(HaveLen(1))
+			Consistently(subsServer.ReceivedRequests, "100ms").Should(HaveLen(1))
+			close(release)
+
+			// then
+			for range 5 {
+				var res FeatureResponse
+				Eventually(results).Should(Receive(&res))
+				Expect(res.Data.Features).To(HaveExactElements(HaveField("Name", "TestBundle1")))
+			}
+			Expect(subsServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("When failed lookups are cached", func() {
+		BeforeEach(func() {
+			configOptions.Set(config.Keys.SubsCacheErrorTTL, 10)
+			DeferCleanup(configOptions.Set, config.Keys.SubsCacheErrorTTL, 30)
+			configOptions.Set(config.Keys.SubsCacheNon200TTL, 20)
+			DeferCleanup(configOptions.Set, config.Keys.SubsCacheNon200TTL, 60)
+			configOptions.Set(config.Keys.SubsCacheNegativeMaxTTL, 60)
+			DeferCleanup(configOptions.Set, config.Keys.SubsCacheNegativeMaxTTL, 1800)
+			resetFailureStreak("backoff")
+		})
+
+		It("should use the TTL of the outcome", func() {
+			Expect(failClosedTTL("backoff", FeatureOutcomeUpstreamError)).To(Equal(10 * time.Second))
+			resetFailureStreak("backoff")
+			Expect(failClosedTTL("backoff", FeatureOutcomeNon200)).To(Equal(20 * time.Second))
+		})
+
+		It("should back off exponentially for failures in a row, up to the max TTL", func() {
+			var ttls []time.Duration
+			for range 5 {
+				ttls = append(ttls, failClosedTTL("backoff", FeatureOutcomeUpstreamError))
+			}
+
+			Expect(ttls).To(HaveExactElements(10*time.Second, 20*time.Second, 40*time.Second, 60*time.Second, 60*time.Second))
+		})
+
+		It("should start over after a successful lookup", func() {
+			// given
+			failClosedTTL("backoff", FeatureOutcomeUpstreamError)
+			failClosedTTL("backoff", FeatureOutcomeUpstreamError)
+
+			// when
+			resetFailureStreak("backoff")
+
+			// then
+			Expect(failClosedTTL("backoff", FeatureOutcomeUpstreamError)).To(Equal(10 * time.Second))
+		})
+	})
+
+	Context("When the Feature Service circuit breaker is open", func() {
+		var subsServer *ghttp.Server
+
+		BeforeEach(func() {
+			GetFeatureStatus = realGetFeatureStatus
+			cache.Clear()
+			lastKnownGood.Clear()
+
+			subsServer = ghttp.NewServer()
+			subsServer.Writer = GinkgoWriter
+			subsServer.SetAllowUnhandledRequests(true)
+			config.GetConfig().Options.SetDefault(config.Keys.SubsHost, subsServer.URL())
+
+			previous := featureServiceBreaker
+			featureServiceBreaker = breaker.New("feature_service_test", breaker.Settings{Window: time.Minute, MinRequests: 1, FailureRate: 50, OpenDuration: time.Hour})
+			done, err := featureServiceBreaker.Allow()
+			Expect(err).To(BeNil())
+			done(true)
+			DeferCleanup(func() { featureServiceBreaker = previous })
+		})
+
+		AfterEach(func() {
+			subsServer.Close()
+		})
+
+		It("should fail closed without calling the Feature Service", func() {
+			// when
+			res := GetFeatureStatus(GetFeatureStatusParams{FeatureService: featureservice.NewClient(), OrgId: "breaker-open"})
+
+			// then
+			Expect(res.Error).To(MatchError(breaker.ErrOpen))
+			Expect(res.Data.Features).To(BeEmpty())
+			Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("should mark the /services response degraded", func() {
+			// when
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", realGetFeatureStatus)
+
+			// then
+			Expect(rr.Result().StatusCode).To(Equal(200))
+			Expect(rr.Result().Header.Get("X-Entitlements-Degraded")).To(Equal("true"))
+			Expect(rr.Result().Header.Get("X-Entitlements-Degraded-Status")).To(Equal("0"))
+			Expect(body["TestBundle1"].IsEntitled).To(BeFalse())
+			Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("When the Feature API fails and a last known good result is served", func() {
+		It("should entitle from the stale data and mark the response degraded and stale", func() {
+			// given
+			fakeResponse := FeatureResponse{
+				StatusCode: 503,
+				Stale:      true,
+				Data:       FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+			}
+
+			// when
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(rr.Result().StatusCode).To(Equal(200))
+			Expect(rr.Result().Header.Get("X-Entitlements-Degraded")).To(Equal("true"))
+			Expect(rr.Result().Header.Get("X-Entitlements-Degraded-Status")).To(Equal("503"))
+			Expect(rr.Result().Header.Get("X-Entitlements-Stale")).To(Equal("true"))
+			Expect(body["TestBundle1"].IsEntitled).To(BeTrue())
+			Expect(body["TestBundle6"].IsEntitled).To(BeFalse())
+		})
+
+		It("should report a degraded status of 0 when the stale data came from the cache", func() {
+			// given
+			fakeResponse := FeatureResponse{
+				StatusCode: 200,
+				Stale:      true,
+				CacheHit:   true,
+				Data:       FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+			}
+
+			// when
+			rr, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(rr.Result().Header.Get("X-Entitlements-Degraded")).To(Equal("true"))
+			Expect(rr.Result().Header.Get("X-Entitlements-Degraded-Status")).To(Equal("0"))
+			Expect(rr.Result().Header.Get("X-Entitlements-Stale")).To(Equal("true"))
+			Expect(body["TestBundle1"].IsEntitled).To(BeTrue())
 		})
 	})
 
@@ -743,7 +1259,7 @@ var _ = Describe("Services Controller", func() {
 			}
 
 			// when
-			rr, body, _ := testRequestWithServiceAccount("GET", "/", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithServiceAccount("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(200))
@@ -767,17 +1283,302 @@ var _ = Describe("Services Controller", func() {
 			}
 
 			// when
-			rr, body, _ := testRequestWithServiceAccount("GET", "/", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			rr, body, _ := testRequestWithServiceAccount("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 
 			// then
 			Expect(rr.Result().StatusCode).To(Equal(200))
-			for _, bundle := range bundleInfo {
+			for _, bundle := range getBundleState().bundles {
 				if bundle.UseIsInternal {
 					Expect(body[bundle.Name].IsEntitled).To(Equal(false), "Service Account should not have internal-only bundles")
 				}
 			}
 		})
 	})
+
+	Context("When bundles are limited to identity types", func() {
+		fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}}
+
+		BeforeEach(func() {
+			bundles, err := ParseBundles([]byte(`
+- name: TestBundle1
+  skus: [SVC123]
+  identity_types: [User]
+- name: UsersOnly
+  identity_types: [User, Associate]
+  disallowed_identity_result: omit
+- name: ServiceAccountsGranted
+  use_is_internal: true
+  identity_types: [User]
+  disallowed_identity_result: entitled
+`))
+			Expect(err).To(BeNil())
+			Expect(storeBundleInfo(bundles, "")).To(Succeed())
+		})
+
+		It("should answer other identity types with the disallowed result", func() {
+			// when
+			rr, body, _ := testRequestWithServiceAccount("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(rr.Result().Header.Get("X-Entitlements-Identity-Type")).To(Equal("ServiceAccount"))
+			Expect(body).NotTo(HaveKey("UsersOnly"))
+			Expect(body["TestBundle1"].IsEntitled).To(BeFalse())
+			Expect(body["ServiceAccountsGranted"].IsEntitled).To(BeTrue())
+		})
+
+		It("should decide the bundles for identity types they apply to with their rules", func() {
+			// when
+			rr, body, _ := testRequestWithIdentityType("/services", "User", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(rr.Result().Header.Get("X-Entitlements-Identity-Type")).To(Equal("User"))
+			Expect(body["TestBundle1"].IsEntitled).To(BeTrue())
+			Expect(body["UsersOnly"].IsEntitled).To(BeTrue())
+			Expect(body["ServiceAccountsGranted"].IsEntitled).To(BeFalse())
+		})
+
+		It("should explain the identity type a bundle does not apply to", func() {
+			// given
+			configOptions.Set(config.Keys.ServicesExplain, true)
+			DeferCleanup(configOptions.Set, config.Keys.ServicesExplain, false)
+
+			// when
+			_, body, _ := testRequestWithIdentityType("/services?explain=true", "System", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["TestBundle1"].Explanation.DecidedBy).To(Equal(ruleIdentityTypes))
+			Expect(body["TestBundle1"].Explanation.Rules).To(HaveExactElements(RuleEvaluation{
+				Rule:   ruleIdentityTypes,
+				Inputs: map[string]any{"identity_type": "System", "identity_types": []any{"User"}},
+				Result: false,
+			}))
+		})
+	})
+
+	Context("When a bundle has entitled_when rules", func() {
+		BeforeEach(func() {
+			bundles, err := ParseBundles([]byte(`
+- name: TestBundle1
+  skus: [SVC123]
+  paid_skus: [SVC124]
+  entitled_when:
+    any:
+      - is: internal
+      - feature: TestBundle1
+- name: TestServiceAccounts
+  entitled_when:
+    all:
+      - identity_type: [ServiceAccount]
+      - org_id: ["` + DEFAULT_ORG_ID + `"]
+`))
+			Expect(err).To(BeNil())
+			Expect(storeBundleInfo(bundles, "")).To(Succeed())
+		})
+
+		It("should entitle internal users without the feature", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{}}
+
+			// when
+			_, body, _ := testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["TestBundle1"]).To(Equal(EntitlementsSection{IsEntitled: true, IsTrial: false}))
+			Expect(body["TestServiceAccounts"].IsEntitled).To(BeFalse())
+		})
+
+		It("should entitle customers with the feature and keep reporting trials", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}}
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["TestBundle1"]).To(Equal(EntitlementsSection{IsEntitled: true, IsTrial: true}))
+		})
+
+		It("should deny customers without the feature", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{}}
+
+			// when
+			_, body, _ := testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["TestBundle1"].IsEntitled).To(BeFalse())
+		})
+
+		It("should entitle service accounts of allowlisted orgs", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{}}
+
+			// when
+			_, body, _ := testRequestWithServiceAccount("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			_, otherOrgBody, _ := testRequestWithServiceAccount("GET", "/services", DEFAULT_ACCOUNT_NUMBER, "1111", fakeGetFeatureStatus("1111", fakeResponse))
+
+			// then
+			Expect(body["TestServiceAccounts"].IsEntitled).To(BeTrue())
+			Expect(otherOrgBody["TestServiceAccounts"].IsEntitled).To(BeFalse())
+		})
+
+		It("should explain the rule tree", func() {
+			// given
+			fakeResponse := FeatureResponse{StatusCode: 200, Data: FeatureStatus{}}
+
+			// when
+			_, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			explanation := body["TestBundle1"].Explanation
+			Expect(explanation.DecidedBy).To(Equal(ruleEntitledWhen))
+			Expect(explanation.Rules).To(HaveLen(2))
+			Expect(explanation.Rules[0].Inputs).To(HaveKeyWithValue("is_internal", true))
+			Expect(explanation.Rules[1].Inputs).To(HaveKeyWithValue("feature_found", false))
+		})
+	})
+
+	Context("When explain=true", func() {
+		fakeResponse := FeatureResponse{
+			StatusCode: 200,
+			Data:       FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}},
+		}
+
+		It("should be forbidden for customer users", func() {
+			// when
+			rr, _, rawJSON := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, false, "customer@example.com", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(rr.Result().StatusCode).To(Equal(http.StatusForbidden))
+			Expect(rawJSON).To(ContainSubstring("explain=true"))
+		})
+
+		It("should be allowed for customer users when enabled by config", func() {
+			// given
+			configOptions.Set(config.Keys.ServicesExplain, true)
+			DeferCleanup(configOptions.Set, config.Keys.ServicesExplain, false)
+
+			// when
+			rr, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, false, "customer@example.com", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			expectPass(rr.Result())
+			Expect(body["TestBundle1"].Explanation).ToNot(BeNil())
+		})
+
+		It("should not explain unless asked to", func() {
+			// when
+			_, _, rawJSON := testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(rawJSON).ToNot(ContainSubstring("explanation"))
+		})
+
+		It("should explain every bundle for internal users without changing the outcome", func() {
+			// when
+			rr, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+			_, plainBody, _ := testRequest("GET", "/services", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			expectPass(rr.Result())
+			Expect(body).To(HaveLen(len(plainBody)))
+			for name, section := range body {
+				Expect(section.Explanation).ToNot(BeNil(), name)
+				Expect(section.IsEntitled).To(Equal(plainBody[name].IsEntitled), name)
+				Expect(section.IsTrial).To(Equal(plainBody[name].IsTrial), name)
+			}
+		})
+
+		It("should explain which features matched a SKU based bundle", func() {
+			// when
+			_, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			entitled := body["TestBundle1"].Explanation
+			Expect(entitled.DecidedBy).To(Equal(decidedByAllRules))
+			Expect(entitled.Source).To(Equal(sourceFeatureService))
+			Expect(entitled.Rules).To(HaveLen(1))
+			Expect(entitled.Rules[0].Rule).To(Equal(ruleSkus))
+			Expect(entitled.Rules[0].Result).To(BeTrue())
+			Expect(entitled.Rules[0].Inputs).To(HaveKeyWithValue("feature", "TestBundle1"))
+
+			denied := body["TestBundle2"].Explanation
+			Expect(body["TestBundle2"].IsEntitled).To(BeFalse())
+			Expect(denied.DecidedBy).To(Equal(ruleSkus))
+			Expect(denied.Rules[0].Inputs).To(HaveKeyWithValue("feature_found", false))
+		})
+
+		It("should explain identity based bundles", func() {
+			// when
+			_, body, _ := testRequest("GET", "/services?explain=true", "-1", DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["TestBundle3"].Explanation.DecidedBy).To(Equal(decidedByNoRules))
+			Expect(body["TestBundle3"].Explanation.Source).To(Equal(sourceIdentity))
+
+			Expect(body["TestBundle4"].IsEntitled).To(BeFalse())
+			Expect(body["TestBundle4"].Explanation.DecidedBy).To(Equal(ruleUseValidAccNum))
+			Expect(body["TestBundle4"].Explanation.Rules[0].Inputs).To(HaveKeyWithValue("valid_account_number", false))
+
+			internal := body["TestBundle5"].Explanation
+			Expect(body["TestBundle5"].IsEntitled).To(BeFalse())
+			Expect(internal.DecidedBy).To(Equal(ruleUseIsInternal))
+			Expect(internal.Rules[0].Inputs).To(HaveKeyWithValue("is_internal", true))
+			Expect(internal.Rules[0].Inputs).To(HaveKeyWithValue("policy", "default"))
+			Expect(internal.Rules[0].Inputs).To(HaveKeyWithValue("email_domain_allowed", true))
+			Expect(internal.Rules[0].Inputs).To(HaveKeyWithValue("valid_account_number", false))
+			Expect(internal.Rules[0].Inputs).To(HaveKeyWithValue("failed", ConsistOf("valid_account_number")))
+		})
+
+		It("should explain trials", func() {
+			// given
+			storeBundleInfo([]Bundle{{Name: "TestPaidBundle", Skus: []string{"SVC1"}, PaidSkus: []string{"SVC2"}}}, "")
+			trialResponse := FeatureResponse{
+				StatusCode: 200,
+				Data:       FeatureStatus{Features: []Feature{{Name: "TestPaidBundle"}}},
+			}
+
+			// when
+			_, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, trialResponse))
+
+			// then
+			Expect(body["TestPaidBundle"].IsTrial).To(BeTrue())
+			rules := body["TestPaidBundle"].Explanation.Rules
+			Expect(rules).To(HaveLen(2))
+			Expect(rules[1].Rule).To(Equal(rulePaidSkus))
+			Expect(rules[1].Result).To(BeFalse())
+			Expect(rules[1].Inputs).To(HaveKeyWithValue("feature", "TestPaidBundle"+paidFeatureSuffix))
+		})
+
+		DescribeTable("should explain where the feature data came from",
+			func(res FeatureResponse, source string) {
+				// when
+				_, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, res))
+
+				// then
+				Expect(body["TestBundle1"].Explanation.Source).To(Equal(source))
+			},
+			Entry("from the cache", FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}, CacheHit: true, Outcome: FeatureOutcomeSuccess}, sourceCache),
+			Entry("from the cache for an org without features", FeatureResponse{StatusCode: 200, CacheHit: true, Outcome: FeatureOutcomeSuccess}, sourceCache),
+			Entry("from a stale result", FeatureResponse{StatusCode: 200, Data: FeatureStatus{Features: []Feature{{Name: "TestBundle1"}}}, CacheHit: true, Stale: true, Outcome: FeatureOutcomeNon200}, sourceStale),
+			Entry("from a cached failure", FeatureResponse{StatusCode: 200, CacheHit: true, Outcome: FeatureOutcomeUpstreamError}, sourceFailClosed),
+			Entry("from a failed call", FeatureResponse{StatusCode: 503}, sourceDegraded),
+		)
+
+		It("should explain entitle all", func() {
+			// given
+			configOptions.Set(config.Keys.EntitleAll, true)
+			DeferCleanup(configOptions.Set, config.Keys.EntitleAll, false)
+
+			// when
+			_, body, _ := testRequest("GET", "/services?explain=true", DEFAULT_ACCOUNT_NUMBER, DEFAULT_ORG_ID, true, DEFAULT_EMAIL, fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+
+			// then
+			Expect(body["TestBundle2"].IsEntitled).To(BeTrue())
+			Expect(body["TestBundle2"].Explanation.DecidedBy).To(Equal(ruleEntitleAll))
+			Expect(body["TestBundle2"].Explanation.Source).To(Equal(sourceEntitleAll))
+		})
+	})
 })
 
 func BenchmarkRequest(b *testing.B) {
@@ -789,6 +1590,6 @@ func BenchmarkRequest(b *testing.B) {
 			CacheHit:   false,
 		}
 
-		testRequestWithDefaultOrgId("GET", "/", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
+		testRequestWithDefaultOrgId("GET", "/services", fakeGetFeatureStatus(DEFAULT_ORG_ID, fakeResponse))
 	}
 }
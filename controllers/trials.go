@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/api"
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/karlseguin/ccache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// trialActivatedRefreshes records orgs whose cache was recently bypassed for trial_activated=true
+var trialActivatedRefreshes = ccache.New(ccache.Configure[struct{}]())
+var trialActivatedMu sync.Mutex
+
+var trialActivations = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "entitlements_trial_activations_total",
+		Help: "Total number of POST /trials/{bundle} requests by bundle and result",
+	},
+	[]string{"bundle", "result"},
+)
+var trialActivatedLimited = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "entitlements_trial_activated_limited_total",
+	Help: "Total number of trial_activated=true requests served from the cache because the org bypassed it recently",
+})
+
+// forceFreshData reports whether a request with trial_activated=true may bypass the cache for an org.
+// Each org may bypass it once per ENT_TRIAL_ACTIVATED_INTERVAL_SECONDS, POST /trials/{bundle} is the
+// way to refresh an org after activating a trial.
+func forceFreshData(orgID string, trialActivated bool) bool {
+	if !trialActivated {
+		return false
+	}
+
+	interval := time.Second * time.Duration(configOptions.GetInt64(config.Keys.TrialActivatedInterval))
+	if interval <= 0 {
+		return true
+	}
+
+	trialActivatedMu.Lock()
+	defer trialActivatedMu.Unlock()
+	if item := trialActivatedRefreshes.Get(orgID); item != nil && !item.Expired() {
+		trialActivatedLimited.Inc()
+		return false
+	}
+	trialActivatedRefreshes.Set(orgID, struct{}{}, interval)
+	return true
+}
+
+// activateTrial asks the feature service to start a trial of a bundle for an org
+func activateTrial(ctx context.Context, features featureservice.FeatureService, orgID string, bundle string) error {
+	done, err := featureServiceBreaker.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = features.ActivateTrial(ctx, orgID, bundle)
+	done(isFeatureServiceFailure(err))
+	return err
+}
+
+// featureServiceStatus is the status the feature service failed with, 0 if it could not be reached
+func featureServiceStatus(err error) int {
+	var statusErr *featureservice.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// PostTrialsBundle activates a trial of a bundle for the org of the user making the request. It is a 404
+// unless ENT_TRIALS_ENABLED is set, as the Feature Service trial path and body are not confirmed yet.
+func (s ServicesApi) PostTrialsBundle(ctx context.Context, request api.PostTrialsBundleRequestObject) (api.PostTrialsBundleResponseObject, error) {
+	if !configOptions.GetBool(config.Keys.TrialsEnabled) {
+		return api.PostTrialsBundle404JSONResponse(requestError(http.StatusNotFound, "Trial activation is not enabled")), nil
+	}
+
+	idObj := identity.GetIdentity(ctx).Identity
+	orgId := idObj.Internal.OrgID
+
+	if idObj.User == nil {
+		return api.PostTrialsBundle403JSONResponse(requestError(http.StatusForbidden, "Trials can only be activated by users")), nil
+	}
+
+	bundle, ok := findBundle(request.Bundle)
+	if !ok {
+		return api.PostTrialsBundle404JSONResponse(requestError(http.StatusNotFound, "Unknown bundle: "+request.Bundle)), nil
+	}
+	if !bundle.AppliesTo(idObj.Type) {
+		trialActivations.WithLabelValues(bundle.Name, "identity_type").Inc()
+		return api.PostTrialsBundle403JSONResponse(requestError(http.StatusForbidden, "Bundle "+bundle.Name+" does not apply to identity type "+idObj.Type)), nil
+	}
+	if !bundle.IsPaid() {
+		trialActivations.WithLabelValues(bundle.Name, "not_paid").Inc()
+		return api.PostTrialsBundle400JSONResponse(requestError(http.StatusBadRequest, "Bundle "+bundle.Name+" does not offer trials")), nil
+	}
+
+	if err := activateTrial(ctx, s.features, orgId, bundle.Name); err != nil {
+		trialActivations.WithLabelValues(bundle.Name, "failure").Inc()
+		l.Log.WithFields(logrus.Fields{"error": err, "org_id": orgId, "bundle": bundle.Name}).Error("Error activating trial with Feature Service")
+		sentry.CaptureException(err)
+		return api.PostTrialsBundle500JSONResponse(dependencyError("Unable to activate the trial with Feature Service", featureServiceStatus(err))), nil
+	}
+
+	// every user of the org has to see the trial, not just the one that activated it
+	if _, err := evictFeatureStatus(orgId); err != nil {
+		l.Log.WithFields(logrus.Fields{"error": err, "org_id": orgId, "bundle": bundle.Name}).Error("Error evicting the org after activating a trial, other replicas may not see the trial until the org's cached feature status expires")
+		sentry.CaptureException(err)
+	}
+	subscriptions := GetFeatureStatus(GetFeatureStatusParams{FeatureService: s.features, OrgId: orgId, ForceFreshData: true})
+	degraded := featureStatusDegraded(subscriptions)
+
+	inputs := identityInputs(idObj, subscriptions, degraded)
+	section := evaluateBundles(orgId, inputs, []string{bundle.Name}, nil, false)[bundle.Name]
+
+	obj, err := json.Marshal(section)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling bundle entitlement: %w", err)
+	}
+
+	trialActivations.WithLabelValues(bundle.Name, "success").Inc()
+	l.Log.WithFields(logrus.Fields{"org_id": orgId, "bundle": bundle.Name, "degraded": degraded}).Info("trial activated")
+
+	return newEntitlementsResponse(obj, idObj, subscriptions, degraded), nil
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	. "github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/getsentry/sentry-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/redhatinsights/platform-go-middlewares/v2/identity"
+	"github.com/redis/go-redis/v9"
+)
+
+func trialRequest(bundle string, user *identity.User) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/trials/"+bundle, nil)
+	Expect(err).To(BeNil(), "NewRequest error was not nil")
+	req = req.WithContext(identity.WithIdentity(context.Background(), identity.XRHID{
+		Identity: identity.Identity{
+			AccountNumber: DEFAULT_ACCOUNT_NUMBER,
+			User:          user,
+			Internal:      identity.Internal{OrgID: DEFAULT_ORG_ID},
+		},
+	}))
+
+	return serveApi(req)
+}
+
+var _ = Describe("Trials", func() {
+	var subsServer *ghttp.Server
+	user := &identity.User{Email: DEFAULT_EMAIL}
+
+	BeforeEach(func() {
+		GetFeatureStatus = realGetFeatureStatus
+		configOptions.Set(config.Keys.TrialsEnabled, true)
+		DeferCleanup(configOptions.Set, config.Keys.TrialsEnabled, false)
+		configOptions.Set(config.Keys.Features, "TestPaidBundle,TestBundle1")
+		DeferCleanup(configOptions.Set, config.Keys.Features, "")
+		Expect(storeBundleInfo([]Bundle{
+			{Name: "TestPaidBundle", EvalSkus: []string{"EVAL1"}, PaidSkus: []string{"PAID1"}},
+			{Name: "TestBundle1", Skus: []string{"SVC123"}},
+		}, "")).To(Succeed())
+		DeferCleanup(func() {
+			storeBundleInfo([]Bundle{}, "")
+			Expect(SetBundleInfo("../test_data/test_bundle.yml")).To(Succeed())
+		})
+		cache.Clear()
+		lastKnownGood.Clear()
+
+		subsServer = ghttp.NewServer()
+		subsServer.Writer = GinkgoWriter
+		configOptions.SetDefault(config.Keys.SubsHost, subsServer.URL())
+		DeferCleanup(subsServer.Close)
+	})
+
+	It("should activate the trial, evict the org and return the refreshed bundle", func() {
+		// given
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{Outcome: FeatureOutcomeSuccess}, time.Hour)
+		cache.Set(bundleCacheKey(DEFAULT_ORG_ID, "TestBundle1"), featurecache.Entry{Outcome: FeatureOutcomeSuccess}, time.Hour)
+		subsServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", configOptions.GetString(config.Keys.FeatureTrialAPIPath)),
+				ghttp.VerifyJSONRepresenting(TrialActivationRequest{AccountID: DEFAULT_ORG_ID, Feature: "TestPaidBundle"}),
+				ghttp.RespondWith(http.StatusNoContent, nil),
+			),
+			ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestPaidBundle"}]}`),
+		)
+
+		// when
+		rr := trialRequest("TestPaidBundle", user)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+		var section EntitlementsSection
+		Expect(json.Unmarshal(rr.Body.Bytes(), &section)).To(Succeed())
+		Expect(section.IsEntitled).To(BeTrue())
+		Expect(section.IsTrial).To(BeTrue())
+		Expect(subsServer.ReceivedRequests()).To(HaveLen(2))
+		Expect(cache.Get(DEFAULT_ORG_ID).Status.Features).To(HaveExactElements(HaveField("Name", "TestPaidBundle")))
+		Expect(cache.Get(bundleCacheKey(DEFAULT_ORG_ID, "TestBundle1"))).To(BeNil())
+	})
+
+	It("should report an eviction that did not reach the shared cache to Sentry", func() {
+		// given
+		var events []*sentry.Event
+		hub := sentry.CurrentHub()
+		DeferCleanup(hub.BindClient, hub.Client())
+		client, err := sentry.NewClient(sentry.ClientOptions{BeforeSend: func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			events = append(events, event)
+			return nil
+		}})
+		Expect(err).To(BeNil())
+		hub.BindClient(client)
+
+		server := miniredis.RunT(GinkgoT())
+		redisClient := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+		DeferCleanup(redisClient.Close)
+		local := cache
+		cache = featurecache.NewRedis("trials_test", redisClient, featurecache.NewMemory("trials_test", 100, 10))
+		DeferCleanup(func() { cache = local })
+		server.Close()
+
+		subsServer.AppendHandlers(
+			ghttp.RespondWith(http.StatusNoContent, nil),
+			ghttp.RespondWith(http.StatusOK, `{"features": [{"name":"TestPaidBundle"}]}`),
+		)
+
+		// when
+		rr := trialRequest("TestPaidBundle", user)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(events).To(HaveLen(1))
+	})
+
+	It("should return 404 without calling the feature service when trials are not enabled", func() {
+		// given
+		configOptions.Set(config.Keys.TrialsEnabled, false)
+
+		// when
+		rr := trialRequest("TestPaidBundle", user)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+		var errorResp RequestErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+		Expect(errorResp.Error.Message).To(Equal("Trial activation is not enabled"))
+		Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("should reject bundles without trials", func() {
+		rr := trialRequest("TestBundle1", user)
+
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		var errorResp RequestErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+		Expect(errorResp.Error.Message).To(ContainSubstring("TestBundle1"))
+		Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("should return 404 for bundles that are not in the bundle config", func() {
+		rr := trialRequest("NotABundle", user)
+
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+		Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("should not let service accounts activate trials", func() {
+		rr := trialRequest("TestPaidBundle", nil)
+
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+		Expect(subsServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("should return a dependency error and keep the cache when the feature service rejects the activation", func() {
+		// given
+		cache.Set(DEFAULT_ORG_ID, featurecache.Entry{Outcome: FeatureOutcomeSuccess}, time.Hour)
+		subsServer.AppendHandlers(ghttp.RespondWith(http.StatusConflict, `{"message": "trial already used"}`))
+
+		// when
+		rr := trialRequest("TestPaidBundle", user)
+
+		// then
+		Expect(rr.Code).To(Equal(http.StatusInternalServerError))
+		var errorResp DependencyErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &errorResp)).To(Succeed())
+		Expect(errorResp.Error.DependencyFailure).To(BeTrue())
+		Expect(errorResp.Error.Status).To(Equal(http.StatusConflict))
+		Expect(cache.Get(DEFAULT_ORG_ID)).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,209 @@
+package evaluator
+
+import "slices"
+
+// Facts are what a request knows about the caller and their org, rules are evaluated against them
+type Facts struct {
+	// Features holds the names of the features the Feature Service returned for the org
+	Features     map[string]bool
+	IdentityType string
+	OrgID        string
+	// Email is the user's email address, empty for identities without a user. RedHatEmail is whether
+	// it is in one of the default internal policy's domains.
+	Email string
+
+	ValidAccountNumber bool
+	ValidOrgID         bool
+	Internal           bool
+	RedHatEmail        bool
+	OrgAdmin           bool
+
+	// AnyUser decides for an org rather than a request: every attribute of the user holds and any
+	// email domain is allowed, so a bundle is entitled when some user of the org could be entitled
+	// to it. Rules about the org still apply.
+	AnyUser bool
+}
+
+func (f Facts) attribute(name string) bool {
+	if f.AnyUser && name != AttributeValidOrgID {
+		return true
+	}
+
+	switch name {
+	case AttributeValidAccountNumber:
+		return f.ValidAccountNumber
+	case AttributeValidOrgID:
+		return f.ValidOrgID
+	case AttributeInternal:
+		return f.Internal
+	case AttributeRedHatEmail:
+		return f.RedHatEmail
+	case AttributeOrgAdmin:
+		return f.OrgAdmin
+	default:
+		return false
+	}
+}
+
+// attributeInputs names attributes the way explanations have always reported them
+var attributeInputs = map[string]string{
+	AttributeValidAccountNumber: "valid_account_number",
+	AttributeValidOrgID:         "valid_org_id",
+	AttributeInternal:           "is_internal",
+	AttributeRedHatEmail:        "redhat_email",
+	AttributeOrgAdmin:           "org_admin",
+}
+
+// Step records a rule that was evaluated, the inputs it looked at and its result
+type Step struct {
+	Rule   string
+	Inputs map[string]any
+	Result bool
+}
+
+// Condition is a compiled rule tree. The zero Condition is always true.
+type Condition struct {
+	root node
+}
+
+// Evaluate reports whether the facts satisfy the condition
+func (c Condition) Evaluate(facts Facts) bool {
+	if c.root == nil {
+		return true
+	}
+	return c.root.evaluate(facts, nil)
+}
+
+// Explain evaluates the condition like Evaluate and also returns every rule that was evaluated.
+// Unlike Evaluate it does not stop at the first rule that decides all or any, so the explanation
+// shows every input.
+func (c Condition) Explain(facts Facts) (bool, []Step) {
+	if c.root == nil {
+		return true, nil
+	}
+	var steps []Step
+	result := c.root.evaluate(facts, &steps)
+	return result, steps
+}
+
+// node is a compiled rule. Leaf nodes append a Step when steps is not nil.
+type node interface {
+	evaluate(facts Facts, steps *[]Step) bool
+}
+
+func record(steps *[]Step, rule string, inputs map[string]any, result bool) bool {
+	if steps != nil {
+		*steps = append(*steps, Step{Rule: rule, Inputs: inputs, Result: result})
+	}
+	return result
+}
+
+type allNode struct {
+	children []node
+}
+
+func (n allNode) evaluate(facts Facts, steps *[]Step) bool {
+	result := true
+	for _, child := range n.children {
+		result = child.evaluate(facts, steps) && result
+		if !result && steps == nil {
+			return false
+		}
+	}
+	return result
+}
+
+type anyNode struct {
+	children []node
+}
+
+func (n anyNode) evaluate(facts Facts, steps *[]Step) bool {
+	result := false
+	for _, child := range n.children {
+		result = child.evaluate(facts, steps) || result
+		if result && steps == nil {
+			return true
+		}
+	}
+	return result
+}
+
+type notNode struct {
+	child node
+}
+
+func (n notNode) evaluate(facts Facts, steps *[]Step) bool {
+	return record(steps, RuleNot, nil, !n.child.evaluate(facts, steps))
+}
+
+type featureNode struct {
+	name string
+}
+
+func (n featureNode) evaluate(facts Facts, steps *[]Step) bool {
+	found := facts.Features[n.name]
+	if steps == nil {
+		return found
+	}
+	return record(steps, RuleFeature, map[string]any{"feature": n.name, "feature_found": found}, found)
+}
+
+type attributeNode struct {
+	name string
+}
+
+func (n attributeNode) evaluate(facts Facts, steps *[]Step) bool {
+	value := facts.attribute(n.name)
+	if steps == nil {
+		return value
+	}
+	return record(steps, RuleIs, map[string]any{attributeInputs[n.name]: value}, value)
+}
+
+type orgIDNode struct {
+	orgIDs []string
+}
+
+func (n orgIDNode) evaluate(facts Facts, steps *[]Step) bool {
+	listed := slices.Contains(n.orgIDs, facts.OrgID)
+	if steps == nil {
+		return listed
+	}
+	return record(steps, RuleOrgID, map[string]any{"org_id": facts.OrgID}, listed)
+}
+
+type identityTypeNode struct {
+	identityTypes []string
+}
+
+func (n identityTypeNode) evaluate(facts Facts, steps *[]Step) bool {
+	listed := slices.Contains(n.identityTypes, facts.IdentityType)
+	if steps == nil {
+		return listed
+	}
+	return record(steps, RuleIdentityType, map[string]any{"identity_type": facts.IdentityType}, listed)
+}
+
+// namedNode reports its child as a single step under its own name, merging the inputs of every
+// step the child recorded. It keeps explanations of shorthand flags in terms of the flag.
+type namedNode struct {
+	name  string
+	child node
+}
+
+func (n namedNode) evaluate(facts Facts, steps *[]Step) bool {
+	if steps == nil {
+		return n.child.evaluate(facts, nil)
+	}
+
+	var childSteps []Step
+	result := n.child.evaluate(facts, &childSteps)
+
+	inputs := make(map[string]any)
+	for _, step := range childSteps {
+		for key, value := range step.Inputs {
+			inputs[key] = value
+		}
+	}
+	return record(steps, n.name, inputs, result)
+}
@@ -0,0 +1,268 @@
+package evaluator
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+func parseRule(ruleYaml string) Rule {
+	var rule Rule
+	decoder := yaml.NewDecoder(strings.NewReader(ruleYaml))
+	decoder.KnownFields(true)
+	Expect(decoder.Decode(&rule)).To(Succeed())
+	return rule
+}
+
+var testPolicies = InternalPolicies{
+	DefaultInternalPolicy: NewInternalPolicy(DefaultInternalPolicy, []string{"redhat.com"}, nil),
+	"partners":            NewInternalPolicy("partners", []string{"@Example.com", "redhat.com"}, []string{"12345"}),
+}
+
+func compileRule(ruleYaml string) Condition {
+	condition, err := Compile("entitled_when", parseRule(ruleYaml), testPolicies)
+	Expect(err).To(BeNil())
+	return condition
+}
+
+func compileProblems(ruleYaml string) []string {
+	_, err := Compile("entitled_when", parseRule(ruleYaml), testPolicies)
+	Expect(err).To(HaveOccurred())
+
+	var ruleErr *RuleError
+	Expect(errors.As(err, &ruleErr)).To(BeTrue())
+	return ruleErr.Problems
+}
+
+var _ = Describe("Evaluator", func() {
+	var facts Facts
+
+	BeforeEach(func() {
+		facts = Facts{
+			Features:           map[string]bool{"ansible": true},
+			IdentityType:       "User",
+			OrgID:              "12345",
+			ValidAccountNumber: true,
+			ValidOrgID:         true,
+		}
+	})
+
+	Describe("Compile", func() {
+		It("should compile every kind of rule", func() {
+			condition := compileRule(`
+any:
+  - is: internal
+  - all:
+      - feature: ansible
+      - not:
+          org_id: ["666"]
+      - identity_type: [User, ServiceAccount]
+`)
+
+			Expect(condition.Evaluate(facts)).To(BeTrue())
+		})
+
+		It("should reject a rule without an operator", func() {
+			problems := compileProblems(`any: [{}]`)
+
+			Expect(problems).To(HaveExactElements(ContainSubstring("entitled_when.any[0]: must set one of")))
+		})
+
+		It("should reject a rule with more than one operator", func() {
+			problems := compileProblems(`{is: internal, feature: ansible}`)
+
+			Expect(problems).To(HaveExactElements("entitled_when: must set only one of feature, is, use all or any to combine them"))
+		})
+
+		It("should reject unknown attributes and identity types", func() {
+			problems := compileProblems(`
+all:
+  - is: intrnal
+  - identity_type: [Usr]
+`)
+
+			Expect(problems).To(HaveExactElements(
+				ContainSubstring(`entitled_when.all[0]: unknown attribute "intrnal"`),
+				ContainSubstring(`entitled_when.all[1]: unknown identity type "Usr"`),
+			))
+		})
+
+		It("should reject empty org ids", func() {
+			problems := compileProblems(`org_id: ["12345", " "]`)
+
+			Expect(problems).To(HaveExactElements("entitled_when: org_id must not contain empty values"))
+		})
+
+		It("should list the features a rule refers to", func() {
+			rule := parseRule(`
+any:
+  - feature: ansible
+  - not:
+      feature: openshift
+`)
+
+			Expect(rule.Features()).To(HaveExactElements("ansible", "openshift"))
+		})
+	})
+
+	Describe("Evaluate", func() {
+		DescribeTable("should evaluate rules against the facts",
+			func(ruleYaml string, update func(*Facts), expected bool) {
+				// given
+				condition := compileRule(ruleYaml)
+				if update != nil {
+					update(&facts)
+				}
+
+				// then
+				Expect(condition.Evaluate(facts)).To(Equal(expected))
+				result, _ := condition.Explain(facts)
+				Expect(result).To(Equal(expected))
+			},
+			Entry("feature found", `feature: ansible`, nil, true),
+			Entry("feature missing", `feature: openshift`, nil, false),
+			Entry("attribute true", `is: valid_account_number`, nil, true),
+			Entry("attribute false", `is: internal`, nil, false),
+			Entry("org on the allowlist", `org_id: ["12345", "67890"]`, nil, true),
+			Entry("org not on the allowlist", `org_id: ["67890"]`, nil, false),
+			Entry("service accounts allowed", `identity_type: [ServiceAccount]`, func(f *Facts) { f.IdentityType = "ServiceAccount" }, true),
+			Entry("identity type not listed", `identity_type: [ServiceAccount]`, nil, false),
+			Entry("not", `not: {is: internal}`, nil, true),
+			Entry("all true", `all: [{feature: ansible}, {is: valid_org_id}]`, nil, true),
+			Entry("all with a false rule", `all: [{feature: ansible}, {is: org_admin}]`, nil, false),
+			Entry("internal or has sku, internal", `any: [{is: internal}, {feature: openshift}]`, func(f *Facts) { f.Internal = true }, true),
+			Entry("internal or has sku, has sku", `any: [{is: internal}, {feature: ansible}]`, nil, true),
+			Entry("internal or has sku, neither", `any: [{is: internal}, {feature: openshift}]`, nil, false),
+		)
+
+		It("should treat the zero condition as true", func() {
+			Expect(Condition{}.Evaluate(facts)).To(BeTrue())
+		})
+	})
+
+	Describe("Explain", func() {
+		It("should record every rule that was evaluated with its inputs", func() {
+			// given
+			condition := compileRule(`
+any:
+  - feature: ansible
+  - is: internal
+  - not: {identity_type: [User]}
+`)
+
+			// when
+			result, steps := condition.Explain(facts)
+
+			// then
+			Expect(result).To(BeTrue())
+			Expect(steps).To(HaveExactElements(
+				Step{Rule: RuleFeature, Inputs: map[string]any{"feature": "ansible", "feature_found": true}, Result: true},
+				Step{Rule: RuleIs, Inputs: map[string]any{"is_internal": false}, Result: false},
+				Step{Rule: RuleIdentityType, Inputs: map[string]any{"identity_type": "User"}, Result: true},
+				Step{Rule: RuleNot, Result: false},
+			))
+		})
+	})
+
+	Describe("Shorthand", func() {
+		DescribeTable("should keep the behavior of the bundle flags",
+			func(feature string, useValidAccNum bool, useValidOrgId bool, internal *InternalPolicy, update func(*Facts), expected bool) {
+				// given
+				condition := Shorthand(feature, useValidAccNum, useValidOrgId, internal)
+				if update != nil {
+					update(&facts)
+				}
+
+				// then
+				Expect(condition.Evaluate(facts)).To(Equal(expected))
+			},
+			Entry("no flags", "", false, false, nil, func(f *Facts) { f.ValidAccountNumber = false }, true),
+			Entry("sku found", "ansible", false, false, nil, nil, true),
+			Entry("sku missing", "openshift", false, false, nil, nil, false),
+			Entry("valid account number", "", true, false, nil, nil, true),
+			Entry("invalid account number", "ansible", true, false, nil, func(f *Facts) { f.ValidAccountNumber = false }, false),
+			Entry("invalid org id", "", false, true, nil, func(f *Facts) { f.ValidOrgID = false }, false),
+			Entry("internal user", "", false, false, testPolicies[DefaultInternalPolicy], func(f *Facts) { f.Internal = true; f.Email = "test@redhat.com" }, true),
+			Entry("internal user without a red hat email", "", false, false, testPolicies[DefaultInternalPolicy], func(f *Facts) { f.Internal = true; f.Email = "test@example.com" }, false),
+			Entry("internal user without a valid account number", "", false, false, testPolicies[DefaultInternalPolicy], func(f *Facts) { f.Internal = true; f.Email = "test@redhat.com"; f.ValidAccountNumber = false }, false),
+			Entry("internal user of another policy's domain", "", false, false, testPolicies["partners"], func(f *Facts) { f.Internal = true; f.Email = "test@EXAMPLE.com" }, true),
+			Entry("internal user outside the policy's orgs", "", false, false, testPolicies["partners"], func(f *Facts) { f.Internal = true; f.Email = "test@example.com"; f.OrgID = "1" }, false),
+			Entry("any user, valid account number", "", true, false, nil, func(f *Facts) { f.ValidAccountNumber = false; f.AnyUser = true }, true),
+			Entry("any user, invalid org id", "", false, true, nil, func(f *Facts) { f.ValidOrgID = false; f.AnyUser = true }, false),
+			Entry("any user, internal user", "", false, false, testPolicies[DefaultInternalPolicy], func(f *Facts) { f.AnyUser = true }, true),
+			Entry("any user outside the policy's orgs", "", false, false, testPolicies["partners"], func(f *Facts) { f.AnyUser = true; f.OrgID = "1" }, false),
+		)
+
+		It("should explain flags by their names", func() {
+			// given
+			condition := Shorthand("ansible", true, false, nil)
+
+			// when
+			_, steps := condition.Explain(facts)
+
+			// then
+			Expect(steps).To(HaveExactElements(
+				Step{Rule: RuleSkus, Inputs: map[string]any{"feature": "ansible", "feature_found": true}, Result: true},
+				Step{Rule: RuleUseValidAccNum, Inputs: map[string]any{"valid_account_number": true}, Result: true},
+			))
+		})
+
+		It("should explain use_is_internal as a single rule with the conditions that failed", func() {
+			// given
+			facts.Email = "test@example.com"
+
+			// when
+			_, steps := Shorthand("", false, false, testPolicies["partners"]).Explain(facts)
+
+			// then
+			Expect(steps).To(HaveExactElements(
+				Step{Rule: RuleUseIsInternal, Inputs: map[string]any{
+					"policy":               "partners",
+					"email_domain":         "example.com",
+					"failed":               []string{"is_internal"},
+					"is_internal":          false,
+					"valid_account_number": true,
+					"email_domain_allowed": true,
+					"org_allowed":          true,
+				}, Result: false},
+			))
+		})
+	})
+
+	Describe("InternalPolicy", func() {
+		It("should match email domains case insensitively", func() {
+			policy := NewInternalPolicy("test", []string{"@RedHat.com"}, nil)
+
+			Expect(policy.EmailAllowed("someone@redhat.COM")).To(BeTrue())
+			Expect(policy.EmailAllowed("someone@notredhat.com")).To(BeFalse())
+			Expect(policy.EmailAllowed("redhat.com")).To(BeFalse())
+		})
+
+		It("should compile internal_user rules against the named policy", func() {
+			// given
+			condition := compileRule(`internal_user: partners`)
+			facts.Internal = true
+			facts.Email = "test@example.com"
+
+			// when
+			result, steps := condition.Explain(facts)
+
+			// then
+			Expect(result).To(BeTrue())
+			Expect(steps).To(HaveExactElements(HaveField("Rule", RuleInternalUser)))
+		})
+
+		It("should reject internal_user rules for unknown policies", func() {
+			Expect(compileProblems(`internal_user: nope`)).To(ConsistOf(ContainSubstring(`unknown internal policy "nope"`)))
+		})
+
+		It("should accept any policy name when no policies are loaded", func() {
+			_, err := Compile("entitled_when", parseRule(`internal_user: nope`), nil)
+
+			Expect(err).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package evaluator
+
+import "strings"
+
+// DefaultInternalPolicy is the policy used by use_is_internal bundles that do not choose one
+const DefaultInternalPolicy = "default"
+
+// Names of the conditions of an internal policy as they appear in an explanation
+const (
+	internalConditionInternal    = "is_internal"
+	internalConditionAccount     = "valid_account_number"
+	internalConditionEmailDomain = "email_domain_allowed"
+	internalConditionOrg         = "org_allowed"
+)
+
+// InternalPolicy decides whether a user counts as internal: the identity has to be marked internal,
+// have a valid account number and an email in one of the policy's domains, and when the policy lists
+// orgs, belong to one of them. It is built once when the policies are loaded.
+type InternalPolicy struct {
+	Name    string
+	domains map[string]bool
+	orgIDs  map[string]bool
+}
+
+// InternalPolicies holds the loaded internal policies by name
+type InternalPolicies map[string]*InternalPolicy
+
+// NewInternalPolicy builds an internal policy. Domains are matched case insensitively and may be
+// given with or without a leading @. An empty orgIDs allows every org.
+func NewInternalPolicy(name string, emailDomains []string, orgIDs []string) *InternalPolicy {
+	policy := &InternalPolicy{Name: name, domains: make(map[string]bool), orgIDs: make(map[string]bool)}
+	for _, domain := range emailDomains {
+		policy.domains[strings.ToLower(strings.TrimPrefix(domain, "@"))] = true
+	}
+	for _, orgID := range orgIDs {
+		policy.orgIDs[orgID] = true
+	}
+	return policy
+}
+
+// EmailAllowed reports whether an email address is in one of the policy's domains
+func (p *InternalPolicy) EmailAllowed(email string) bool {
+	return p.domains[emailDomain(email)]
+}
+
+// emailDomain returns the lower cased domain of an email address, or "" if it has none
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// Lookup returns the named policy. Without loaded policies any name is accepted and the returned
+// policy never allows anyone, so rules can be validated without the policies of an environment.
+func (p InternalPolicies) Lookup(name string) (*InternalPolicy, bool) {
+	if p == nil {
+		return NewInternalPolicy(name, nil, nil), true
+	}
+	policy, ok := p[name]
+	return policy, ok
+}
+
+type internalPolicyNode struct {
+	policy *InternalPolicy
+}
+
+type policyCondition struct {
+	name   string
+	result bool
+}
+
+func (n internalPolicyNode) evaluate(facts Facts, steps *[]Step) bool {
+	domain := emailDomain(facts.Email)
+	conditions := []policyCondition{
+		{internalConditionInternal, facts.attribute(AttributeInternal)},
+		{internalConditionAccount, facts.attribute(AttributeValidAccountNumber)},
+		{internalConditionEmailDomain, facts.AnyUser || n.policy.domains[domain]},
+	}
+	if len(n.policy.orgIDs) > 0 {
+		conditions = append(conditions, policyCondition{internalConditionOrg, n.policy.orgIDs[facts.OrgID]})
+	}
+
+	result := true
+	failed := []string{}
+	for _, condition := range conditions {
+		if !condition.result {
+			result = false
+			failed = append(failed, condition.name)
+		}
+	}
+	if steps == nil {
+		return result
+	}
+
+	inputs := map[string]any{
+		"policy":       n.policy.Name,
+		"email_domain": domain,
+		"failed":       failed,
+	}
+	for _, condition := range conditions {
+		inputs[condition.name] = condition.result
+	}
+	return record(steps, RuleInternalUser, inputs, result)
+}
@@ -0,0 +1,193 @@
+// Package evaluator compiles and evaluates the conditions that decide whether a request is entitled
+// to a bundle. Conditions are written as a rule tree in bundles.yml and compiled once when the
+// bundle config is loaded, so mistakes are reported at load time instead of on every request.
+package evaluator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Rule is a node of a rule tree as written in bundles.yml. Exactly one field must be set.
+//
+//	entitled_when:
+//	  any:
+//	    - internal_user: default
+//	    - all:
+//	        - feature: ansible
+//	        - identity_type: [User, ServiceAccount]
+type Rule struct {
+	// All is true when every rule in it is true
+	All []Rule `yaml:"all,omitempty"`
+	// Any is true when at least one rule in it is true
+	Any []Rule `yaml:"any,omitempty"`
+	// Not is true when the rule in it is false
+	Not *Rule `yaml:"not,omitempty"`
+	// Feature is true when the Feature Service returned the named feature for the org
+	Feature string `yaml:"feature,omitempty"`
+	// Is is true when the named identity attribute is true, see Attributes
+	Is string `yaml:"is,omitempty"`
+	// OrgID is true when the request's org is one of the listed orgs
+	OrgID []string `yaml:"org_id,omitempty"`
+	// IdentityType is true when the request's identity type is one of the listed types
+	IdentityType []string `yaml:"identity_type,omitempty"`
+	// InternalUser is true when the user is internal under the named internal policy
+	InternalUser string `yaml:"internal_user,omitempty"`
+}
+
+// Names of the leaf rules as they appear in an explanation
+const (
+	RuleFeature      = "feature"
+	RuleIs           = "is"
+	RuleOrgID        = "org_id"
+	RuleIdentityType = "identity_type"
+	RuleInternalUser = "internal_user"
+	RuleNot          = "not"
+)
+
+// Attributes are the identity attributes that can be used with `is`
+var Attributes = []string{
+	AttributeValidAccountNumber,
+	AttributeValidOrgID,
+	AttributeInternal,
+	AttributeRedHatEmail,
+	AttributeOrgAdmin,
+}
+
+const (
+	AttributeValidAccountNumber = "valid_account_number"
+	AttributeValidOrgID         = "valid_org_id"
+	AttributeInternal           = "internal"
+	AttributeRedHatEmail        = "redhat_email"
+	AttributeOrgAdmin           = "org_admin"
+)
+
+// IdentityTypes are the identity types that can be used with `identity_type`
+var IdentityTypes = []string{"User", "ServiceAccount", "Associate", "System", "X509"}
+
+// RuleError lists every problem found in a rule tree so they can all be fixed at once
+type RuleError struct {
+	Problems []string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("invalid rule: %s", strings.Join(e.Problems, "; "))
+}
+
+// Features returns the names of every feature the rule tree refers to
+func (r Rule) Features() []string {
+	var features []string
+	if r.Feature != "" {
+		features = append(features, r.Feature)
+	}
+	for _, child := range slices.Concat(r.All, r.Any) {
+		features = append(features, child.Features()...)
+	}
+	if r.Not != nil {
+		features = append(features, r.Not.Features()...)
+	}
+	return features
+}
+
+// operators returns the names of the fields that are set on a rule
+func (r Rule) operators() []string {
+	var set []string
+	if len(r.All) > 0 {
+		set = append(set, "all")
+	}
+	if len(r.Any) > 0 {
+		set = append(set, "any")
+	}
+	if r.Not != nil {
+		set = append(set, "not")
+	}
+	if r.Feature != "" {
+		set = append(set, "feature")
+	}
+	if r.Is != "" {
+		set = append(set, "is")
+	}
+	if len(r.OrgID) > 0 {
+		set = append(set, "org_id")
+	}
+	if len(r.IdentityType) > 0 {
+		set = append(set, "identity_type")
+	}
+	if r.InternalUser != "" {
+		set = append(set, "internal_user")
+	}
+	return set
+}
+
+// Compile validates a rule tree and turns it into a Condition. Problems are reported with their
+// path in the tree, starting at name. The returned error is a *RuleError. internal_user rules are
+// compiled with the named policy from policies; with nil policies the names are not checked, for
+// validating a rule tree without the policies of an environment.
+func Compile(name string, rule Rule, policies InternalPolicies) (Condition, error) {
+	var problems []string
+	root := compile(name, rule, policies, &problems)
+	if len(problems) > 0 {
+		return Condition{}, &RuleError{Problems: problems}
+	}
+	return Condition{root: root}, nil
+}
+
+func compile(path string, rule Rule, policies InternalPolicies, problems *[]string) node {
+	problem := func(format string, args ...any) node {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+		return nil
+	}
+
+	switch set := rule.operators(); len(set) {
+	case 0:
+		return problem("must set one of all, any, not, feature, is, org_id, identity_type or internal_user")
+	case 1:
+	default:
+		return problem("must set only one of %s, use all or any to combine them", strings.Join(set, ", "))
+	}
+
+	switch {
+	case len(rule.All) > 0:
+		return allNode{children: compileChildren(path+".all", rule.All, policies, problems)}
+	case len(rule.Any) > 0:
+		return anyNode{children: compileChildren(path+".any", rule.Any, policies, problems)}
+	case rule.Not != nil:
+		return notNode{child: compile(path+".not", *rule.Not, policies, problems)}
+	case rule.Feature != "":
+		return featureNode{name: rule.Feature}
+	case rule.Is != "":
+		if !slices.Contains(Attributes, rule.Is) {
+			return problem("unknown attribute %q, must be one of %s", rule.Is, strings.Join(Attributes, ", "))
+		}
+		return attributeNode{name: rule.Is}
+	case len(rule.OrgID) > 0:
+		for _, orgID := range rule.OrgID {
+			if strings.TrimSpace(orgID) == "" {
+				return problem("org_id must not contain empty values")
+			}
+		}
+		return orgIDNode{orgIDs: rule.OrgID}
+	case rule.InternalUser != "":
+		policy, ok := policies.Lookup(rule.InternalUser)
+		if !ok {
+			return problem("unknown internal policy %q", rule.InternalUser)
+		}
+		return internalPolicyNode{policy: policy}
+	default:
+		for _, identityType := range rule.IdentityType {
+			if !slices.Contains(IdentityTypes, identityType) {
+				return problem("unknown identity type %q, must be one of %s", identityType, strings.Join(IdentityTypes, ", "))
+			}
+		}
+		return identityTypeNode{identityTypes: rule.IdentityType}
+	}
+}
+
+func compileChildren(path string, rules []Rule, policies InternalPolicies, problems *[]string) []node {
+	children := make([]node, len(rules))
+	for i, rule := range rules {
+		children[i] = compile(fmt.Sprintf("%s[%d]", path, i), rule, policies, problems)
+	}
+	return children
+}
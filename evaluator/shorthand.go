@@ -0,0 +1,34 @@
+package evaluator
+
+// Names of the rules the bundle shorthand flags compile to, as they appear in an explanation
+const (
+	RuleSkus           = "skus"
+	RuleUseValidAccNum = "use_valid_acc_num"
+	RuleUseValidOrgId  = "use_valid_org_id"
+	RuleUseIsInternal  = "use_is_internal"
+)
+
+// Shorthand compiles the boolean flags of a bundle without a rule tree. The bundle is entitled when
+// the org has feature, if one is given, and every flag that is set holds. An internal policy, given
+// for use_is_internal, replaces all of that with a check for a user that is internal under it.
+func Shorthand(feature string, useValidAccNum bool, useValidOrgId bool, internal *InternalPolicy) Condition {
+	if internal != nil {
+		return Condition{root: namedNode{name: RuleUseIsInternal, child: internalPolicyNode{policy: internal}}}
+	}
+
+	var rules []node
+	if feature != "" {
+		rules = append(rules, namedNode{name: RuleSkus, child: featureNode{name: feature}})
+	}
+	if useValidAccNum {
+		rules = append(rules, namedNode{name: RuleUseValidAccNum, child: attributeNode{name: AttributeValidAccountNumber}})
+	}
+	if useValidOrgId {
+		rules = append(rules, namedNode{name: RuleUseValidOrgId, child: attributeNode{name: AttributeValidOrgID}})
+	}
+
+	if len(rules) == 0 {
+		return Condition{}
+	}
+	return Condition{root: allNode{children: rules}}
+}
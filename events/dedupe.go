@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+// Dedupe remembers the last change published for each org, so the same change seen again within the
+// dedupe window, by another request or another replica, is not published twice. A different change in
+// between, like a bundle flipping back, is published and becomes the last change.
+type Dedupe interface {
+	// Claim reports whether a change with fingerprint should be published for the org, and if so
+	// records it as the org's last change for window
+	Claim(ctx context.Context, orgID string, fingerprint string, window time.Duration) (bool, error)
+	// Release forgets fingerprint as the org's last change if it still is, so a retry is published
+	Release(ctx context.Context, orgID string, fingerprint string) error
+	Close() error
+}
+
+// MemoryDedupe is a Dedupe local to a replica
+type MemoryDedupe struct {
+	mu   sync.Mutex
+	last *ccache.Cache[string]
+}
+
+var _ Dedupe = &MemoryDedupe{}
+
+func NewMemoryDedupe() *MemoryDedupe {
+	return &MemoryDedupe{last: ccache.New(ccache.Configure[string]())}
+}
+
+func (d *MemoryDedupe) Claim(ctx context.Context, orgID string, fingerprint string, window time.Duration) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if item := d.last.Get(orgID); item != nil && !item.Expired() && item.Value() == fingerprint {
+		return false, nil
+	}
+	d.last.Set(orgID, fingerprint, window)
+	return true, nil
+}
+
+func (d *MemoryDedupe) Release(ctx context.Context, orgID string, fingerprint string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if item := d.last.Get(orgID); item != nil && item.Value() == fingerprint {
+		d.last.Delete(orgID)
+	}
+	return nil
+}
+
+func (d *MemoryDedupe) Close() error {
+	d.last.Stop()
+	return nil
+}
+
+const dedupeKeyPrefix = "entitlements:events:"
+
+var claimScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisDedupe is a Dedupe shared between replicas through a Redis-protocol store
+type RedisDedupe struct {
+	client *redis.Client
+}
+
+var _ Dedupe = &RedisDedupe{}
+
+func NewRedisDedupe(client *redis.Client) *RedisDedupe {
+	return &RedisDedupe{client: client}
+}
+
+func (d *RedisDedupe) Claim(ctx context.Context, orgID string, fingerprint string, window time.Duration) (bool, error) {
+	claimed, err := claimScript.Run(ctx, d.client, []string{dedupeKeyPrefix + orgID}, fingerprint, window.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+func (d *RedisDedupe) Release(ctx context.Context, orgID string, fingerprint string) error {
+	return releaseScript.Run(ctx, d.client, []string{dedupeKeyPrefix + orgID}, fingerprint).Err()
+}
+
+// Close does nothing, the client is shared with the feature status caches
+func (d *RedisDedupe) Close() error {
+	return nil
+}
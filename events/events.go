@@ -0,0 +1,97 @@
+// Package events publishes changes to org entitlements so other services can react to them without
+// polling /services.
+//
+// Events are handed to a Publisher. The default is Noop, which drops them; Kafka writes them to a topic
+// keyed by org so the events of an org stay in order.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// TypeOrgEntitlementsChanged is the type of the event published when the bundles an org is entitled
+// to change
+const TypeOrgEntitlementsChanged = "org_entitlements_changed"
+
+// BundleState is whether an org is entitled to a bundle
+type BundleState struct {
+	IsEntitled bool `json:"is_entitled"`
+	IsTrial    bool `json:"is_trial"`
+}
+
+// BundleChange is the state of a bundle before and after a change. Before is nil for bundles the org
+// was not decided for before, After for bundles it is no longer decided for.
+type BundleChange struct {
+	Before *BundleState `json:"before"`
+	After  *BundleState `json:"after"`
+}
+
+// Event is a change to the entitlements of an org
+type Event struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	OrgID      string                  `json:"org_id"`
+	OccurredAt time.Time               `json:"occurred_at"`
+	Bundles    map[string]BundleChange `json:"bundles"`
+}
+
+// Publisher delivers events. Publish may be called concurrently.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// Noop is the Publisher used when no message bus is configured, it drops every event
+type Noop struct{}
+
+func (Noop) Publish(ctx context.Context, event Event) error { return nil }
+func (Noop) Close() error                                   { return nil }
+
+// Changes returns the bundles whose state differs between before and after
+func Changes(before map[string]BundleState, after map[string]BundleState) map[string]BundleChange {
+	changes := make(map[string]BundleChange)
+	for name, state := range after {
+		previous, ok := before[name]
+		if ok && previous == state {
+			continue
+		}
+
+		change := BundleChange{After: &state}
+		if ok {
+			change.Before = &previous
+		}
+		changes[name] = change
+	}
+
+	for name, state := range before {
+		if _, ok := after[name]; !ok {
+			changes[name] = BundleChange{Before: &state}
+		}
+	}
+	return changes
+}
+
+// NewOrgEntitlementsChanged returns the event for changes to the bundles of an org
+func NewOrgEntitlementsChanged(orgID string, changes map[string]BundleChange, at time.Time) Event {
+	return Event{
+		ID:         rand.Text(),
+		Type:       TypeOrgEntitlementsChanged,
+		OrgID:      orgID,
+		OccurredAt: at.UTC(),
+		Bundles:    changes,
+	}
+}
+
+// Fingerprint identifies the change an event describes regardless of its ID and time, events with the
+// same fingerprint are duplicates
+func (e Event) Fingerprint() string {
+	// map keys are marshalled in order, so the same changes always hash the same
+	bundles, _ := json.Marshal(e.Bundles)
+	sum := sha256.Sum256(append([]byte(e.Type+"\x00"+e.OrgID+"\x00"), bundles...))
+	return hex.EncodeToString(sum[:])
+}
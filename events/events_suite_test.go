@@ -0,0 +1,15 @@
+package events_test
+
+import (
+	"testing"
+
+	. "github.com/RedHatInsights/entitlements-api-go/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestEvents(t *testing.T) {
+	InitLogger()
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Events Suite")
+}
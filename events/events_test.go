@@ -0,0 +1,295 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/events"
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+// broker is an in-process stand-in for Kafka brokers that keeps the records written to each topic
+type broker struct {
+	mu      sync.Mutex
+	topics  map[string][]events.Message
+	failing error
+	closed  bool
+}
+
+func newBroker() *broker {
+	return &broker{topics: make(map[string][]events.Message)}
+}
+
+func (b *broker) WriteMessages(ctx context.Context, msgs ...events.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failing != nil {
+		return b.failing
+	}
+	for _, msg := range msgs {
+		b.topics[msg.Topic] = append(b.topics[msg.Topic], msg)
+	}
+	return nil
+}
+
+func (b *broker) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (b *broker) records(topic string) []events.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.topics[topic]
+}
+
+var entitled = events.BundleState{IsEntitled: true}
+var notEntitled = events.BundleState{}
+
+var _ = Describe("Changes", func() {
+	It("should only include the bundles whose state changed", func() {
+		// given
+		before := map[string]events.BundleState{"rhel": entitled, "ansible": notEntitled, "gone": entitled}
+		after := map[string]events.BundleState{"rhel": entitled, "ansible": entitled, "new": notEntitled}
+
+		// when
+		changes := events.Changes(before, after)
+
+		// then
+		Expect(changes).To(Equal(map[string]events.BundleChange{
+			"ansible": {Before: &notEntitled, After: &entitled},
+			"gone":    {Before: &entitled},
+			"new":     {After: &notEntitled},
+		}))
+	})
+
+	It("should be empty when nothing changed", func() {
+		state := map[string]events.BundleState{"rhel": entitled}
+
+		Expect(events.Changes(state, state)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Event", func() {
+	It("should have the same fingerprint for the same change of an org", func() {
+		// given
+		changes := map[string]events.BundleChange{"rhel": {Before: &notEntitled, After: &entitled}}
+
+		// when
+		first := events.NewOrgEntitlementsChanged("123", changes, time.Now())
+		second := events.NewOrgEntitlementsChanged("123", changes, time.Now().Add(time.Minute))
+		otherOrg := events.NewOrgEntitlementsChanged("456", changes, time.Now())
+
+		// then
+		Expect(first.ID).NotTo(Equal(second.ID))
+		Expect(first.Fingerprint()).To(Equal(second.Fingerprint()))
+		Expect(first.Fingerprint()).NotTo(Equal(otherOrg.Fingerprint()))
+	})
+})
+
+var _ = Describe("Kafka", func() {
+	var b *broker
+	var event events.Event
+
+	BeforeEach(func() {
+		b = newBroker()
+		event = events.NewOrgEntitlementsChanged("123", map[string]events.BundleChange{
+			"rhel": {Before: &notEntitled, After: &entitled},
+		}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	})
+
+	It("should write the event to the topic keyed by org", func() {
+		// given
+		publisher := events.NewKafka(b, "platform.entitlements.events", events.NewMemoryDedupe(), time.Minute)
+
+		// when
+		err := publisher.Publish(context.Background(), event)
+
+		// then
+		Expect(err).To(BeNil())
+		records := b.records("platform.entitlements.events")
+		Expect(records).To(HaveLen(1))
+		Expect(string(records[0].Key)).To(Equal("123"))
+		Expect(records[0].Headers).To(ContainElement(events.Header{Key: "event_type", Value: []byte(events.TypeOrgEntitlementsChanged)}))
+
+		var body map[string]any
+		Expect(json.Unmarshal(records[0].Value, &body)).To(Succeed())
+		Expect(body).To(HaveKeyWithValue("type", "org_entitlements_changed"))
+		Expect(body).To(HaveKeyWithValue("org_id", "123"))
+		Expect(body).To(HaveKeyWithValue("occurred_at", "2026-01-02T03:04:05Z"))
+		Expect(body).To(HaveKeyWithValue("bundles", map[string]any{
+			"rhel": map[string]any{
+				"before": map[string]any{"is_entitled": false, "is_trial": false},
+				"after":  map[string]any{"is_entitled": true, "is_trial": false},
+			},
+		}))
+	})
+
+	dedupeBehavior := func(newDedupe func() events.Dedupe) {
+		var dedupe events.Dedupe
+
+		BeforeEach(func() {
+			dedupe = newDedupe()
+		})
+
+		It("should drop the same change within the dedupe window", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", dedupe, time.Minute)
+			duplicate := events.NewOrgEntitlementsChanged(event.OrgID, event.Bundles, time.Now())
+
+			// when
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+			Expect(publisher.Publish(context.Background(), duplicate)).To(Succeed())
+
+			// then
+			Expect(b.records("topic")).To(HaveLen(1))
+		})
+
+		It("should publish a change again after a different change of the org", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", dedupe, time.Minute)
+			flipBack := events.NewOrgEntitlementsChanged(event.OrgID, map[string]events.BundleChange{
+				"rhel": {Before: &entitled, After: &notEntitled},
+			}, time.Now())
+
+			// when
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+			Expect(publisher.Publish(context.Background(), flipBack)).To(Succeed())
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+
+			// then
+			Expect(b.records("topic")).To(HaveLen(3))
+		})
+
+		It("should not drop the same change of another org", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", dedupe, time.Minute)
+			otherOrg := events.NewOrgEntitlementsChanged("456", event.Bundles, time.Now())
+
+			// when
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+			Expect(publisher.Publish(context.Background(), otherOrg)).To(Succeed())
+
+			// then
+			Expect(b.records("topic")).To(HaveLen(2))
+		})
+
+		It("should return the broker's error and let the change be retried", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", dedupe, time.Minute)
+			b.failing = errors.New("leader not available")
+
+			// when
+			err := publisher.Publish(context.Background(), event)
+			b.failing = nil
+			retry := publisher.Publish(context.Background(), event)
+
+			// then
+			Expect(err).To(MatchError(ContainSubstring("leader not available")))
+			Expect(retry).To(Succeed())
+			Expect(b.records("topic")).To(HaveLen(1))
+		})
+	}
+
+	Context("with a memory dedupe", func() {
+		dedupeBehavior(func() events.Dedupe {
+			dedupe := events.NewMemoryDedupe()
+			DeferCleanup(dedupe.Close)
+			return dedupe
+		})
+
+		It("should publish the same change again after the dedupe window", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", events.NewMemoryDedupe(), 10*time.Millisecond)
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+			time.Sleep(20 * time.Millisecond)
+
+			// when
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+
+			// then
+			Expect(b.records("topic")).To(HaveLen(2))
+		})
+	})
+
+	Context("with a redis dedupe", func() {
+		var server *miniredis.Miniredis
+		var client *redis.Client
+
+		BeforeEach(func() {
+			server = miniredis.RunT(GinkgoT())
+			client = redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+			DeferCleanup(client.Close)
+		})
+
+		dedupeBehavior(func() events.Dedupe {
+			return events.NewRedisDedupe(client)
+		})
+
+		It("should share the dedupe between publishers", func() {
+			// given
+			first := events.NewKafka(b, "topic", events.NewRedisDedupe(client), time.Minute)
+			second := events.NewKafka(b, "topic", events.NewRedisDedupe(client), time.Minute)
+
+			// when
+			Expect(first.Publish(context.Background(), event)).To(Succeed())
+			Expect(second.Publish(context.Background(), event)).To(Succeed())
+
+			// then
+			Expect(b.records("topic")).To(HaveLen(1))
+		})
+
+		It("should publish the same change again after the dedupe window", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", events.NewRedisDedupe(client), time.Minute)
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+			server.FastForward(2 * time.Minute)
+
+			// when
+			Expect(publisher.Publish(context.Background(), event)).To(Succeed())
+
+			// then
+			Expect(b.records("topic")).To(HaveLen(2))
+		})
+
+		It("should publish when redis cannot be reached", func() {
+			// given
+			publisher := events.NewKafka(b, "topic", events.NewRedisDedupe(client), time.Minute)
+			server.Close()
+
+			// when
+			err := publisher.Publish(context.Background(), event)
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(b.records("topic")).To(HaveLen(1))
+		})
+	})
+
+	It("should close the writer", func() {
+		publisher := events.NewKafka(b, "topic", events.NewMemoryDedupe(), time.Minute)
+
+		Expect(publisher.Close()).To(Succeed())
+		Expect(b.closed).To(BeTrue())
+	})
+})
+
+var _ = Describe("NewKafkaWriter", func() {
+	It("should require brokers", func() {
+		_, err := events.NewKafkaWriter(events.KafkaWriterConfig{})
+
+		Expect(err).To(MatchError(ContainSubstring("no kafka brokers")))
+	})
+
+	It("should reject a CA certificate that is not PEM", func() {
+		_, err := events.NewKafkaWriter(events.KafkaWriterConfig{Brokers: []string{"localhost:9092"}, CACert: "not a certificate"})
+
+		Expect(err).To(MatchError(ContainSubstring("CA certificate")))
+	})
+})
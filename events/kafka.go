@@ -0,0 +1,151 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+var published = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Total number of events delivered to the message bus by type",
+	},
+	[]string{"type"},
+)
+var publishFailed = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "events_publish_failed_total",
+		Help: "Total number of events the message bus did not accept by type",
+	},
+	[]string{"type"},
+)
+var deduplicated = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "events_deduplicated_total",
+		Help: "Total number of events not published because the same change was published within the dedupe window, by type",
+	},
+	[]string{"type"},
+)
+var publishTimeHistogram = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "events_publish_time_taken",
+		Help:    "Message bus publish latency distributions by type",
+		Buckets: prometheus.LinearBuckets(0.25, 0.25, 20),
+	},
+	[]string{"type"},
+)
+
+// Header is a Kafka record header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a Kafka record
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// MessageWriter writes records to Kafka brokers. NewKafkaWriter returns one backed by kafka-go, tests
+// use an in-process broker stand-in.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Kafka publishes events as JSON records to a topic, keyed by org ID. An event describing the same
+// change as the last one published for the org within the dedupe window is dropped.
+type Kafka struct {
+	writer       MessageWriter
+	topic        string
+	dedupe       Dedupe
+	dedupeWindow time.Duration
+}
+
+// NewKafka returns a Kafka publisher writing to topic through writer. A dedupe window of 0 publishes
+// every event.
+func NewKafka(writer MessageWriter, topic string, dedupe Dedupe, dedupeWindow time.Duration) *Kafka {
+	return &Kafka{
+		writer:       writer,
+		topic:        topic,
+		dedupe:       dedupe,
+		dedupeWindow: dedupeWindow,
+	}
+}
+
+func (k *Kafka) Publish(ctx context.Context, event Event) error {
+	fingerprint := event.Fingerprint()
+	if !k.claim(ctx, event, fingerprint) {
+		deduplicated.WithLabelValues(event.Type).Inc()
+		return nil
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		k.release(ctx, event, fingerprint)
+		return fmt.Errorf("error marshalling %s event: %w", event.Type, err)
+	}
+
+	start := time.Now()
+	err = k.writer.WriteMessages(ctx, Message{
+		Topic: k.topic,
+		Key:   []byte(event.OrgID),
+		Value: value,
+		Headers: []Header{
+			{Key: "event_type", Value: []byte(event.Type)},
+			{Key: "event_id", Value: []byte(event.ID)},
+		},
+	})
+	publishTimeHistogram.WithLabelValues(event.Type).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		// let a retry of the same change through
+		k.release(ctx, event, fingerprint)
+		publishFailed.WithLabelValues(event.Type).Inc()
+		return fmt.Errorf("error publishing %s event to %s: %w", event.Type, k.topic, err)
+	}
+
+	published.WithLabelValues(event.Type).Inc()
+	return nil
+}
+
+// claim reports whether the event should be published. When the dedupe store cannot be reached the
+// event is published, a duplicate is better than a lost change.
+func (k *Kafka) claim(ctx context.Context, event Event, fingerprint string) bool {
+	if k.dedupeWindow <= 0 {
+		return true
+	}
+
+	claimed, err := k.dedupe.Claim(ctx, event.OrgID, fingerprint, k.dedupeWindow)
+	if err != nil {
+		l.Log.WithFields(logrus.Fields{"error": err, "org_id": event.OrgID, "event_id": event.ID}).Warn("Error deduplicating event, publishing it")
+		return true
+	}
+	return claimed
+}
+
+func (k *Kafka) release(ctx context.Context, event Event, fingerprint string) {
+	if k.dedupeWindow <= 0 {
+		return
+	}
+
+	if err := k.dedupe.Release(ctx, event.OrgID, fingerprint); err != nil {
+		l.Log.WithFields(logrus.Fields{"error": err, "org_id": event.OrgID, "event_id": event.ID}).Warn("Error releasing deduplicated event")
+	}
+}
+
+func (k *Kafka) Close() error {
+	return errors.Join(k.writer.Close(), k.dedupe.Close())
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASL mechanisms a KafkaWriterConfig may use
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// KafkaWriterConfig is how to reach the Kafka brokers
+type KafkaWriterConfig struct {
+	Brokers []string
+	// CACert is a PEM encoded CA bundle. Connections use TLS when it is set.
+	CACert string
+	// Username and Password authenticate with SASLMechanism when Username is set, PLAIN if it is empty
+	SASLMechanism string
+	Username      string
+	Password      string
+}
+
+// kafkaWriter is a MessageWriter backed by a kafka-go Writer
+type kafkaWriter struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaWriter returns a MessageWriter for the brokers. Records with the same key go to the same
+// partition, and a write returns once every in-sync replica has the records.
+func NewKafkaWriter(cfg KafkaWriterConfig) (MessageWriter, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("no kafka brokers configured")
+	}
+
+	transport := &kafka.Transport{}
+	if cfg.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, fmt.Errorf("error parsing kafka CA certificate")
+		}
+		transport.TLS = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	if cfg.Username != "" {
+		mechanism, err := saslMechanism(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = mechanism
+	}
+
+	return &kafkaWriter{writer: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+		Transport:    transport,
+	}}, nil
+}
+
+func saslMechanism(cfg KafkaWriterConfig) (sasl.Mechanism, error) {
+	switch cfg.SASLMechanism {
+	case SASLPlain, "":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported kafka SASL mechanism [%s], must be one of [%s, %s, %s]", cfg.SASLMechanism, SASLPlain, SASLScramSHA256, SASLScramSHA512)
+	}
+}
+
+func (w *kafkaWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	records := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		headers := make([]kafka.Header, 0, len(msg.Headers))
+		for _, header := range msg.Headers {
+			headers = append(headers, kafka.Header{Key: header.Key, Value: header.Value})
+		}
+		records = append(records, kafka.Message{Topic: msg.Topic, Key: msg.Key, Value: msg.Value, Headers: headers})
+	}
+	return w.writer.WriteMessages(ctx, records...)
+}
+
+func (w *kafkaWriter) Close() error {
+	return w.writer.Close()
+}
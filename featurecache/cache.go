@@ -0,0 +1,190 @@
+package featurecache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+var lookups = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "feature_status_cache_lookups_total",
+		Help: "Total number of feature status cache lookups by cache, backend and result",
+	},
+	[]string{"cache", "backend", "result"},
+)
+var backendErrors = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "feature_status_cache_errors_total",
+		Help: "Total number of failed feature status cache operations by cache, backend and operation",
+	},
+	[]string{"cache", "backend", "operation"},
+)
+var fallbacks = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "feature_status_cache_fallback_total",
+		Help: "Total number of feature status cache lookups answered from local memory because the shared store was unreachable",
+	},
+	[]string{"cache"},
+)
+
+// Entry is a feature status result for an org as it is held in a cache
+type Entry struct {
+	Status types.FeatureStatus `json:"status"`
+	// Stale is set when Status is the org's last known good result, cached because the feature service failed
+	Stale bool `json:"stale,omitempty"`
+	// StoredAt is when Status was fetched from the feature service
+	StoredAt  time.Time `json:"storedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Outcome is the outcome of the lookup the entry was cached for, one of the types.FeatureOutcome constants
+	Outcome string `json:"outcome,omitempty"`
+	// FeatureSet identifies the features that were requested for the entry, see RequestedFor
+	FeatureSet string `json:"featureSet,omitempty"`
+}
+
+// RequestedFor reports whether the entry was requested for the given feature set. An entry requested
+// before a bundle config reload changed the features does not hold the new ones. Entries cached before
+// feature sets were recorded have none, those are used until they expire.
+func (e *Entry) RequestedFor(featureSet string) bool {
+	return e.FeatureSet == "" || e.FeatureSet == featureSet
+}
+
+// LookupOutcome returns the outcome of the lookup the entry was cached for. Entries cached before
+// outcomes were recorded have none, for those an empty feature list means the lookup failed.
+func (e *Entry) LookupOutcome() string {
+	if e.Outcome != "" {
+		return e.Outcome
+	}
+	if !e.Stale && len(e.Status.Features) == 0 {
+		return types.FeatureOutcomeUpstreamError
+	}
+	return types.FeatureOutcomeSuccess
+}
+
+// FailClosed reports whether the entry is an empty result cached because the lookup failed
+func (e *Entry) FailClosed() bool {
+	return !e.Stale && e.LookupOutcome() != types.FeatureOutcomeSuccess
+}
+
+// Expired reports whether the entry is past its expiry
+func (e *Entry) Expired() bool {
+	return !time.Now().Before(e.ExpiresAt)
+}
+
+// TTL returns how long the entry has left before it expires
+func (e *Entry) TTL() time.Duration {
+	return time.Until(e.ExpiresAt)
+}
+
+// Cache stores feature status results per org
+type Cache interface {
+	// Get returns the entry for an org, or nil if there is none or it has expired
+	Get(orgID string) *Entry
+	// Set stores an entry for an org for ttl. StoredAt is set to now when it is empty.
+	Set(orgID string, entry Entry, ttl time.Duration)
+	// Delete removes the entry for an org, returning whether there was one
+	Delete(orgID string) bool
+	// DeletePrefix removes every entry whose key starts with prefix, returning how many were removed
+	DeletePrefix(prefix string) (int, error)
+	// Clear removes every entry, returning how many were removed
+	Clear() (int, error)
+	// Stats describes the current contents and usage of the cache
+	Stats() (Stats, error)
+	// Backend is the name of the storage backend, used as a metric label
+	Backend() string
+}
+
+// Stats describes a cache. Entries counts what is stored in the backend, shared by all replicas
+// for shared backends, while Hits and Misses only count lookups made by this replica since it started.
+type Stats struct {
+	Name    string `json:"name"`
+	Backend string `json:"backend"`
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// lookupCounts tracks the lookups a cache answered, for Stats
+type lookupCounts struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func (c *lookupCounts) record(name, backend string, hit bool) {
+	if hit {
+		c.hits.Add(1)
+		lookups.WithLabelValues(name, backend, "hit").Inc()
+		return
+	}
+	c.misses.Add(1)
+	lookups.WithLabelValues(name, backend, "miss").Inc()
+}
+
+// newEntry fills in the timestamps for an entry about to be stored
+func newEntry(entry Entry, ttl time.Duration) Entry {
+	now := time.Now()
+	if entry.StoredAt.IsZero() {
+		entry.StoredAt = now
+	}
+	entry.ExpiresAt = now.Add(ttl)
+	return entry
+}
+
+var redisClient *redis.Client
+var redisClientOnce sync.Once
+
+func getRedisClient() *redis.Client {
+	redisClientOnce.Do(func() {
+		options := config.GetConfig().Options
+		timeout := time.Millisecond * time.Duration(options.GetInt64(config.Keys.SubsCacheRedisTimeoutMs))
+
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:         options.GetString(config.Keys.SubsCacheRedisAddr),
+			Password:     options.GetString(config.Keys.SubsCacheRedisPassword),
+			DB:           options.GetInt(config.Keys.SubsCacheRedisDB),
+			DialTimeout:  timeout,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		})
+	})
+
+	return redisClient
+}
+
+// RedisClient returns the client of the shared store configured by SUBS_CACHE_REDIS_*, for caches
+// other than feature status results that are shared between replicas with the redis backend
+func RedisClient() *redis.Client {
+	return getRedisClient()
+}
+
+// New returns the named cache using the backend selected by the SUBS_CACHE_BACKEND config.
+// Every backend keeps a local in-memory cache sized by SUBS_CACHE_MAX_SIZE and SUBS_CACHE_ITEM_PRUNE.
+func New(name string) (Cache, error) {
+	options := config.GetConfig().Options
+	local := NewMemory(name,
+		options.GetInt64(config.Keys.SubsCacheMaxSize),
+		uint8(options.GetUint32(config.Keys.SubsCacheItemPrune)),
+	)
+
+	switch backend := options.GetString(config.Keys.SubsCacheBackend); backend {
+	case BackendMemory, "":
+		return local, nil
+	case BackendRedis:
+		return NewRedis(name, getRedisClient(), local), nil
+	default:
+		return nil, fmt.Errorf("unsupported feature status cache backend [%s], must be one of [%s, %s]", backend, BackendMemory, BackendRedis)
+	}
+}
@@ -0,0 +1,339 @@
+package featurecache_test
+
+import (
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/featurecache"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var testStatus = types.FeatureStatus{
+	Features: []types.Feature{
+		{Name: "ansible", StartDate: "2024-01-01T00:00:00.000Z", EndDate: "2025-01-01T00:00:00.000Z"},
+		{Name: "ansible_paid"},
+	},
+}
+
+// cacheBehavior covers the behavior every backend must share
+func cacheBehavior(newCache func() featurecache.Cache) {
+	var c featurecache.Cache
+
+	BeforeEach(func() {
+		c = newCache()
+	})
+
+	It("should return nil for an org that was never set", func() {
+		Expect(c.Get("12345")).To(BeNil())
+	})
+
+	It("should return what was set", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+
+		// when
+		entry := c.Get("12345")
+
+		// then
+		Expect(entry).ToNot(BeNil())
+		Expect(entry.Status).To(Equal(testStatus))
+		Expect(entry.Stale).To(BeFalse())
+		Expect(entry.StoredAt).To(BeTemporally("~", time.Now(), time.Second))
+		Expect(entry.TTL()).To(BeNumerically("~", time.Minute, time.Second))
+	})
+
+	It("should keep the outcome of the lookup", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Outcome: types.FeatureOutcomeNon200}, time.Minute)
+
+		// when
+		entry := c.Get("12345")
+
+		// then
+		Expect(entry.LookupOutcome()).To(Equal(types.FeatureOutcomeNon200))
+		Expect(entry.FailClosed()).To(BeTrue())
+	})
+
+	It("should keep the feature set the entry was requested for", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus, FeatureSet: "abc"}, time.Minute)
+
+		// when
+		entry := c.Get("12345")
+
+		// then
+		Expect(entry.RequestedFor("abc")).To(BeTrue())
+		Expect(entry.RequestedFor("def")).To(BeFalse())
+	})
+
+	It("should keep StoredAt when it is provided", func() {
+		// given
+		storedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		c.Set("12345", featurecache.Entry{Status: testStatus, Stale: true, StoredAt: storedAt}, time.Minute)
+
+		// when
+		entry := c.Get("12345")
+
+		// then
+		Expect(entry.Stale).To(BeTrue())
+		Expect(entry.StoredAt).To(BeTemporally("==", storedAt))
+	})
+
+	It("should not return expired entries", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus}, time.Millisecond)
+
+		// then
+		Eventually(func() *featurecache.Entry { return c.Get("12345") }).Should(BeNil())
+	})
+
+	It("should delete entries", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+
+		// when
+		deleted := c.Delete("12345")
+
+		// then
+		Expect(deleted).To(BeTrue())
+		Expect(c.Get("12345")).To(BeNil())
+		Expect(c.Delete("12345")).To(BeFalse())
+	})
+
+	It("should delete entries by prefix", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+		c.Set("12345/bundle1", featurecache.Entry{Status: testStatus}, time.Minute)
+		c.Set("12345/bundle2", featurecache.Entry{Status: testStatus}, time.Minute)
+		c.Set("123456/bundle1", featurecache.Entry{Status: testStatus}, time.Minute)
+
+		// when
+		deleted, err := c.DeletePrefix("12345/")
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(deleted).To(Equal(2))
+		Expect(c.Get("12345/bundle1")).To(BeNil())
+		Expect(c.Get("12345/bundle2")).To(BeNil())
+		Expect(c.Get("12345")).ToNot(BeNil())
+		Expect(c.Get("123456/bundle1")).ToNot(BeNil())
+	})
+
+	It("should clear every entry", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+		c.Set("67890", featurecache.Entry{Status: testStatus}, time.Minute)
+
+		// when
+		cleared, err := c.Clear()
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(cleared).To(Equal(2))
+		Expect(c.Get("12345")).To(BeNil())
+		Expect(c.Get("67890")).To(BeNil())
+	})
+
+	It("should report entries, hits and misses", func() {
+		// given
+		c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+		c.Set("67890", featurecache.Entry{Status: testStatus}, time.Minute)
+		c.Get("12345")
+		c.Get("12345")
+		c.Get("00000")
+
+		// when
+		stats, err := c.Stats()
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(stats.Name).To(Equal("test"))
+		Expect(stats.Backend).To(Equal(c.Backend()))
+		Expect(stats.Entries).To(Equal(2))
+		Expect(stats.Hits).To(BeEquivalentTo(2))
+		Expect(stats.Misses).To(BeEquivalentTo(1))
+	})
+}
+
+var _ = Describe("Feature Cache", func() {
+	Describe("Memory", func() {
+		cacheBehavior(func() featurecache.Cache {
+			return featurecache.NewMemory("test", 100, 10)
+		})
+	})
+
+	Describe("Redis", func() {
+		var server *miniredis.Miniredis
+		var client *redis.Client
+
+		BeforeEach(func() {
+			server = miniredis.RunT(GinkgoT())
+			client = redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+			DeferCleanup(client.Close)
+		})
+
+		cacheBehavior(func() featurecache.Cache {
+			return featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+		})
+
+		It("should share entries between replicas", func() {
+			// given
+			replica1 := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			replica2 := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+
+			// when
+			replica1.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+
+			// then
+			entry := replica2.Get("12345")
+			Expect(entry).ToNot(BeNil())
+			Expect(entry.Status).To(Equal(testStatus))
+		})
+
+		It("should store entries under a key namespaced by cache name with a ttl", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+
+			// when
+			c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+
+			// then
+			Expect(server.Exists("entitlements:test:12345")).To(BeTrue())
+			Expect(server.TTL("entitlements:test:12345")).To(Equal(time.Minute))
+		})
+
+		It("should treat an undecodable entry as a miss", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			Expect(server.Set("entitlements:test:12345", "not json")).To(Succeed())
+
+			// then
+			Expect(c.Get("12345")).To(BeNil())
+		})
+
+		It("should fall back to local memory when the shared store is unreachable", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+			server.Close()
+
+			// when
+			entry := c.Get("12345")
+
+			// then
+			Expect(entry).ToNot(BeNil())
+			Expect(entry.Status).To(Equal(testStatus))
+		})
+
+		It("should only clear its own keys", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			other := featurecache.NewRedis("other", client, featurecache.NewMemory("other", 100, 10))
+			c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+			other.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+
+			// when
+			cleared, err := c.Clear()
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(cleared).To(Equal(1))
+			Expect(server.Exists("entitlements:other:12345")).To(BeTrue())
+		})
+
+		It("should match a prefix literally", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			c.Set("1*/bundle1", featurecache.Entry{Status: testStatus}, time.Minute)
+			c.Set("12/bundle1", featurecache.Entry{Status: testStatus}, time.Minute)
+
+			// when
+			deleted, err := c.DeletePrefix("1*/")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(deleted).To(Equal(1))
+			Expect(server.Exists("entitlements:test:12/bundle1")).To(BeTrue())
+		})
+
+		It("should report an error when stats cannot be read from the shared store", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			server.Close()
+
+			// when
+			_, err := c.Stats()
+
+			// then
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should keep working against local memory while the shared store is unreachable", func() {
+			// given
+			c := featurecache.NewRedis("test", client, featurecache.NewMemory("test", 100, 10))
+			server.Close()
+
+			// when
+			c.Set("12345", featurecache.Entry{Status: testStatus}, time.Minute)
+
+			// then
+			Expect(c.Get("12345")).ToNot(BeNil())
+			Expect(c.Delete("12345")).To(BeTrue())
+			Expect(c.Get("12345")).To(BeNil())
+		})
+	})
+
+	DescribeTable("should infer the outcome of entries cached without one",
+		func(entry featurecache.Entry, outcome string, failClosed bool) {
+			Expect(entry.LookupOutcome()).To(Equal(outcome))
+			Expect(entry.FailClosed()).To(Equal(failClosed))
+		},
+		Entry("with features", featurecache.Entry{Status: testStatus}, types.FeatureOutcomeSuccess, false),
+		Entry("without features", featurecache.Entry{}, types.FeatureOutcomeUpstreamError, true),
+		Entry("stale", featurecache.Entry{Status: testStatus, Stale: true}, types.FeatureOutcomeSuccess, false),
+	)
+
+	DescribeTable("should only use entries requested for the feature set",
+		func(entry featurecache.Entry, requestedFor bool) {
+			Expect(entry.RequestedFor("abc")).To(Equal(requestedFor))
+		},
+		Entry("the same feature set", featurecache.Entry{FeatureSet: "abc"}, true),
+		Entry("another feature set", featurecache.Entry{FeatureSet: "def"}, false),
+		Entry("cached without one", featurecache.Entry{}, true),
+	)
+
+	Describe("New", func() {
+		AfterEach(func() {
+			config.GetConfig().Options.Set(config.Keys.SubsCacheBackend, featurecache.BackendMemory)
+		})
+
+		It("should default to the memory backend", func() {
+			c, err := featurecache.New("test")
+
+			Expect(err).To(BeNil())
+			Expect(c.Backend()).To(Equal(featurecache.BackendMemory))
+		})
+
+		It("should construct the redis backend", func() {
+			config.GetConfig().Options.Set(config.Keys.SubsCacheBackend, featurecache.BackendRedis)
+
+			c, err := featurecache.New("test")
+
+			Expect(err).To(BeNil())
+			Expect(c.Backend()).To(Equal(featurecache.BackendRedis))
+		})
+
+		It("should reject unknown backends", func() {
+			config.GetConfig().Options.Set(config.Keys.SubsCacheBackend, "memcached")
+
+			_, err := featurecache.New("test")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("memcached"))
+		})
+	})
+})
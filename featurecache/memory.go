@@ -0,0 +1,71 @@
+package featurecache
+
+import (
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+)
+
+// Memory is a Cache local to this process
+type Memory struct {
+	name   string
+	cache  *ccache.Cache[Entry]
+	counts lookupCounts
+}
+
+var _ Cache = &Memory{}
+
+func NewMemory(name string, maxSize int64, percentToPrune uint8) *Memory {
+	return &Memory{
+		name: name,
+		cache: ccache.New(
+			ccache.Configure[Entry]().
+				MaxSize(maxSize).
+				PercentToPrune(percentToPrune),
+		),
+	}
+}
+
+func (m *Memory) Get(orgID string) *Entry {
+	item := m.cache.Get(orgID)
+	if item == nil || item.Expired() {
+		m.counts.record(m.name, BackendMemory, false)
+		return nil
+	}
+
+	m.counts.record(m.name, BackendMemory, true)
+	entry := item.Value()
+	return &entry
+}
+
+func (m *Memory) Set(orgID string, entry Entry, ttl time.Duration) {
+	m.cache.Set(orgID, newEntry(entry, ttl), ttl)
+}
+
+func (m *Memory) Delete(orgID string) bool {
+	return m.cache.Delete(orgID)
+}
+
+func (m *Memory) DeletePrefix(prefix string) (int, error) {
+	return m.cache.DeletePrefix(prefix), nil
+}
+
+func (m *Memory) Clear() (int, error) {
+	count := m.cache.ItemCount()
+	m.cache.Clear()
+	return count, nil
+}
+
+func (m *Memory) Stats() (Stats, error) {
+	return Stats{
+		Name:    m.name,
+		Backend: BackendMemory,
+		Entries: m.cache.ItemCount(),
+		Hits:    m.counts.hits.Load(),
+		Misses:  m.counts.misses.Load(),
+	}, nil
+}
+
+func (m *Memory) Backend() string {
+	return BackendMemory
+}
@@ -0,0 +1,172 @@
+package featurecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	l "github.com/RedHatInsights/entitlements-api-go/logger"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const keyPrefix = "entitlements:"
+
+// scanBatchSize is how many keys are requested per SCAN and removed per DEL when listing or clearing a cache
+const scanBatchSize = 500
+
+// Redis is a Cache shared between replicas through a Redis-protocol store. Entries are also written
+// to a local Memory cache, which answers lookups while the shared store is unreachable.
+type Redis struct {
+	name   string
+	client *redis.Client
+	local  *Memory
+	counts lookupCounts
+}
+
+var _ Cache = &Redis{}
+
+func NewRedis(name string, client *redis.Client, local *Memory) *Redis {
+	return &Redis{
+		name:   name,
+		client: client,
+		local:  local,
+	}
+}
+
+func (r *Redis) key(orgID string) string {
+	return keyPrefix + r.name + ":" + orgID
+}
+
+func (r *Redis) logError(operation string, orgID string, err error) {
+	backendErrors.WithLabelValues(r.name, BackendRedis, operation).Inc()
+	l.Log.WithFields(logrus.Fields{"error": err, "cache": r.name, "org_id": orgID, "operation": operation}).Warn("feature status cache operation failed")
+}
+
+func (r *Redis) Get(orgID string) *Entry {
+	data, err := r.client.Get(context.Background(), r.key(orgID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		r.counts.record(r.name, BackendRedis, false)
+		return nil
+	}
+
+	if err != nil {
+		r.logError("get", orgID, err)
+		fallbacks.WithLabelValues(r.name).Inc()
+		return r.local.Get(orgID)
+	}
+
+	var entry Entry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		r.logError("decode", orgID, err)
+		return nil
+	}
+
+	if entry.Expired() {
+		r.counts.record(r.name, BackendRedis, false)
+		return nil
+	}
+
+	r.counts.record(r.name, BackendRedis, true)
+	return &entry
+}
+
+func (r *Redis) Set(orgID string, entry Entry, ttl time.Duration) {
+	entry = newEntry(entry, ttl)
+	r.local.Set(orgID, entry, ttl)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		r.logError("encode", orgID, err)
+		return
+	}
+
+	if err = r.client.Set(context.Background(), r.key(orgID), data, ttl).Err(); err != nil {
+		r.logError("set", orgID, err)
+	}
+}
+
+func (r *Redis) Delete(orgID string) bool {
+	deletedLocal := r.local.Delete(orgID)
+
+	deleted, err := r.client.Del(context.Background(), r.key(orgID)).Result()
+	if err != nil {
+		r.logError("delete", orgID, err)
+		return deletedLocal
+	}
+
+	return deleted > 0 || deletedLocal
+}
+
+// globEscaper escapes the characters SCAN MATCH patterns give a meaning to
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// keys lists every key of this cache in the shared store that starts with prefix
+func (r *Redis) keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, r.key(globEscaper.Replace(prefix)+"*"), scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// deleteKeys removes every key of this cache in the shared store that starts with prefix
+func (r *Redis) deleteKeys(operation string, prefix string) (int, error) {
+	ctx := context.Background()
+	keys, err := r.keys(ctx, prefix)
+	if err != nil {
+		r.logError(operation, prefix, err)
+		return 0, err
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += scanBatchSize {
+		count, err := r.client.Del(ctx, keys[start:min(start+scanBatchSize, len(keys))]...).Result()
+		deleted += int(count)
+		if err != nil {
+			r.logError(operation, prefix, err)
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeletePrefix removes the entries whose key starts with prefix from the shared store and from local memory
+func (r *Redis) DeletePrefix(prefix string) (int, error) {
+	r.local.DeletePrefix(prefix)
+	return r.deleteKeys("delete_prefix", prefix)
+}
+
+// Clear removes every entry of this cache from the shared store and from local memory.
+// Entries held in the local memory of other replicas are only used while the shared store is unreachable.
+func (r *Redis) Clear() (int, error) {
+	r.local.Clear()
+	return r.deleteKeys("clear", "")
+}
+
+func (r *Redis) Stats() (Stats, error) {
+	stats := Stats{
+		Name:    r.name,
+		Backend: BackendRedis,
+		Hits:    r.counts.hits.Load(),
+		Misses:  r.counts.misses.Load(),
+	}
+
+	keys, err := r.keys(context.Background(), "")
+	if err != nil {
+		r.logError("stats", "", err)
+		return stats, err
+	}
+
+	stats.Entries = len(keys)
+	return stats, nil
+}
+
+func (r *Redis) Backend() string {
+	return BackendRedis
+}
@@ -0,0 +1,217 @@
+package featureservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+)
+
+// FeatureService looks up and activates the features of orgs in the IT Feature Service
+type FeatureService interface {
+	// GetFeatureStatus returns which of the given features the org has
+	GetFeatureStatus(ctx context.Context, orgID string, features []string) (types.FeatureStatus, error)
+	// ActivateTrial starts a trial of a feature for the org
+	ActivateTrial(ctx context.Context, orgID string, feature string) error
+}
+
+// TransportError is returned when the Feature Service could not be reached or did not answer before
+// the deadline
+type TransportError struct {
+	URL string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("feature service request to [%s] failed: %s", e.URL, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when the Feature Service answered with a status other than the one expected
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("feature service request to [%s] failed with http status code [%d]: %s", e.URL, e.StatusCode, e.Body)
+}
+
+// DecodeError is returned when the body of a successful Feature Service response could not be decoded,
+// or does not answer the request it was sent for
+type DecodeError struct {
+	URL  string
+	Body string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("feature service response from [%s] could not be decoded: %s", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Client calls the Feature Service configured by ENT_SUBS_HOST with the mutual TLS certs of the service
+type Client struct {
+	httpClient *http.Client
+	host       string
+	statusPath string
+	trialPath  string
+	timeout    time.Duration
+}
+
+var _ FeatureService = &Client{}
+
+// NewClient builds a client from the current config. Every call is given ENT_IT_SERVICES_TIMEOUT_SECONDS
+// to complete unless the context passed to it has an earlier deadline.
+func NewClient() *Client {
+	cfg := config.GetConfig()
+	options := cfg.Options
+
+	var certs []tls.Certificate
+	if cfg.Certs != nil {
+		certs = []tls.Certificate{*cfg.Certs}
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      cfg.RootCAs,
+					Certificates: certs,
+				},
+			},
+		},
+		host:       options.GetString(config.Keys.SubsHost),
+		statusPath: options.GetString(config.Keys.FeatureStatusAPIPath),
+		trialPath:  options.GetString(config.Keys.FeatureTrialAPIPath),
+		timeout:    time.Duration(options.GetInt(config.Keys.ITServicesTimeoutSeconds)) * time.Second,
+	}
+}
+
+// featureStatusURL builds the feature status URL for an org. The features are listed before the
+// accountId as the Feature Service has always been called that way.
+func (c *Client) featureStatusURL(orgID string, features []string) string {
+	escaped := make([]string, len(features))
+	for i, feature := range features {
+		escaped[i] = url.QueryEscape(feature)
+	}
+	return fmt.Sprintf("%s%s?features=%s&accountId=%s",
+		c.host,
+		c.statusPath,
+		strings.Join(escaped, "&features="),
+		url.QueryEscape(orgID),
+	)
+}
+
+func (c *Client) GetFeatureStatus(ctx context.Context, orgID string, features []string) (types.FeatureStatus, error) {
+	reqURL := c.featureStatusURL(orgID, features)
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return types.FeatureStatus{}, &TransportError{URL: reqURL, Err: err}
+	}
+
+	body, err := c.do(ctx, req, http.StatusOK)
+	if err != nil {
+		return types.FeatureStatus{}, err
+	}
+
+	status, err := decodeFeatureStatus(body, features)
+	if err != nil {
+		return types.FeatureStatus{}, &DecodeError{URL: reqURL, Body: string(body), Err: err}
+	}
+	return status, nil
+}
+
+// featureStatusPayload is the body of a feature status response. Features is a pointer so a body
+// without it is told apart from an org without features.
+type featureStatusPayload struct {
+	Features *[]types.Feature `json:"features"`
+}
+
+// decodeFeatureStatus decodes a feature status response to the given features. The fields we read
+// have to be present and of the right type, a body that is truncated or has trailing data fails, and
+// every feature returned has to be one that was requested. Fields we do not read, such as isEval, are
+// ignored as the Feature Service adds them over time.
+func decodeFeatureStatus(body []byte, requested []string) (types.FeatureStatus, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var payload featureStatusPayload
+	if err := decoder.Decode(&payload); err != nil {
+		return types.FeatureStatus{}, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return types.FeatureStatus{}, fmt.Errorf("unexpected data after the feature status")
+	}
+	if payload.Features == nil {
+		return types.FeatureStatus{}, fmt.Errorf("feature status has no features")
+	}
+
+	for i, feature := range *payload.Features {
+		if feature.Name == "" {
+			return types.FeatureStatus{}, fmt.Errorf("feature %d has no name", i)
+		}
+		if !slices.Contains(requested, feature.Name) {
+			return types.FeatureStatus{}, fmt.Errorf("feature %q was not requested", feature.Name)
+		}
+	}
+	return types.FeatureStatus{Features: *payload.Features}, nil
+}
+
+func (c *Client) ActivateTrial(ctx context.Context, orgID string, feature string) error {
+	reqURL := c.host + c.trialPath
+	reqBody, err := json.Marshal(types.TrialActivationRequest{AccountID: orgID, Feature: feature})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, reqURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return &TransportError{URL: reqURL, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.do(ctx, req, 0)
+	return err
+}
+
+// do sends req and returns the body of the response. A response with a status other than expected,
+// or outside of 2xx when expected is 0, is returned as a StatusError.
+func (c *Client) do(ctx context.Context, req *http.Request, expected int) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, &TransportError{URL: req.URL.String(), Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &TransportError{URL: req.URL.String(), Err: err}
+	}
+
+	if (expected != 0 && resp.StatusCode != expected) || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
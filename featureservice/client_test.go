@@ -0,0 +1,213 @@
+package featureservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/config"
+	"github.com/RedHatInsights/entitlements-api-go/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Feature Service client", func() {
+	var server *ghttp.Server
+	var client *Client
+	options := config.GetConfig().Options
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		DeferCleanup(server.Close)
+		client = NewClient()
+		client.host = server.URL()
+	})
+
+	Describe("GetFeatureStatus", func() {
+		It("should ask for the features before the org", func() {
+			// given
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", options.GetString(config.Keys.FeatureStatusAPIPath), "features=Bundle1&features=Bundle1_paid&accountId=540155"),
+				ghttp.RespondWith(http.StatusOK, `{"features": [{"name": "Bundle1"}]}`),
+			))
+
+			// when
+			status, err := client.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1", "Bundle1_paid"})
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(status.Features).To(HaveLen(1))
+			Expect(status.Features[0].Name).To(Equal("Bundle1"))
+		})
+
+		It("should return a StatusError for a non 200", func() {
+			// given
+			server.AppendHandlers(ghttp.RespondWith(http.StatusBadGateway, "upstream is down"))
+
+			// when
+			_, err := client.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1"})
+
+			// then
+			var statusErr *StatusError
+			Expect(errors.As(err, &statusErr)).To(BeTrue())
+			Expect(statusErr.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(statusErr.Body).To(Equal("upstream is down"))
+			Expect(statusErr.URL).To(ContainSubstring("accountId=540155"))
+		})
+
+		It("should return a DecodeError for a body that is not a feature status", func() {
+			// given
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": "none"}`))
+
+			// when
+			_, err := client.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1"})
+
+			// then
+			var decodeErr *DecodeError
+			Expect(errors.As(err, &decodeErr)).To(BeTrue())
+			Expect(decodeErr.Body).To(Equal(`{"features": "none"}`))
+		})
+
+		DescribeTable("should return a DecodeError for a feature status that cannot be trusted",
+			func(body string, reason string) {
+				// given
+				server.AppendHandlers(ghttp.RespondWith(http.StatusOK, body))
+
+				// when
+				_, err := client.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1", "Bundle1_paid"})
+
+				// then
+				var decodeErr *DecodeError
+				Expect(errors.As(err, &decodeErr)).To(BeTrue())
+				Expect(decodeErr.Err).To(MatchError(ContainSubstring(reason)))
+			},
+			Entry("truncated", `{"features": [{"name": "Bundle1"`, "unexpected EOF"),
+			Entry("without features", `{"feature": []}`, "has no features"),
+			Entry("with null features", `{"features": null}`, "has no features"),
+			Entry("with a feature without a name", `{"features": [{"startDate": "2024-01-01"}]}`, "feature 0 has no name"),
+			Entry("with a feature that was not requested", `{"features": [{"name": "Bundle2"}]}`, `feature "Bundle2" was not requested`),
+			Entry("with a name of the wrong type", `{"features": [{"name": 1}]}`, "cannot unmarshal number"),
+			Entry("with trailing data", `{"features": []} {"features": []}`, "unexpected data"),
+		)
+
+		It("should ignore the fields it does not read", func() {
+			// given
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"features": [{"name": "Bundle1_paid", "isEval": false, "entitled": true}]}`))
+
+			// when
+			status, err := client.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1", "Bundle1_paid"})
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(status.Features).To(Equal([]types.Feature{{Name: "Bundle1_paid"}}))
+		})
+
+		It("should return a TransportError when the deadline passes", func() {
+			// given
+			server.AppendHandlers(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			// when
+			_, err := client.GetFeatureStatus(ctx, "540155", []string{"Bundle1"})
+
+			// then
+			var transportErr *TransportError
+			Expect(errors.As(err, &transportErr)).To(BeTrue())
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		})
+	})
+
+	Describe("ActivateTrial", func() {
+		It("should post the trial for the org", func() {
+			// given
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", options.GetString(config.Keys.FeatureTrialAPIPath)),
+				ghttp.VerifyJSONRepresenting(types.TrialActivationRequest{AccountID: "540155", Feature: "Bundle1"}),
+				ghttp.RespondWith(http.StatusNoContent, nil),
+			))
+
+			// when
+			err := client.ActivateTrial(context.Background(), "540155", "Bundle1")
+
+			// then
+			Expect(err).To(BeNil())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("should return a StatusError when the trial is rejected", func() {
+			// given
+			server.AppendHandlers(ghttp.RespondWith(http.StatusConflict, "already active"))
+
+			// when
+			err := client.ActivateTrial(context.Background(), "540155", "Bundle1")
+
+			// then
+			var statusErr *StatusError
+			Expect(errors.As(err, &statusErr)).To(BeTrue())
+			Expect(statusErr.StatusCode).To(Equal(http.StatusConflict))
+		})
+	})
+})
+
+var _ = Describe("Fake Feature Service", func() {
+	It("should only return the features that were asked for", func() {
+		// given
+		fake := NewFake()
+		fake.SetFeatures("540155", types.Feature{Name: "Bundle1"}, types.Feature{Name: "Bundle2"})
+
+		// when
+		status, err := fake.GetFeatureStatus(context.Background(), "540155", []string{"Bundle2", "Bundle3"})
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(status.Features).To(Equal([]types.Feature{{Name: "Bundle2"}}))
+		Expect(fake.Calls()).To(Equal([]Call{{OrgID: "540155", Features: []string{"Bundle2", "Bundle3"}}}))
+	})
+
+	It("should fail with the error set for the org", func() {
+		// given
+		fake := NewFake()
+		fake.SetError("540155", &StatusError{StatusCode: http.StatusInternalServerError})
+
+		// when
+		_, err := fake.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1"})
+		_, otherErr := fake.GetFeatureStatus(context.Background(), "1111", []string{"Bundle1"})
+
+		// then
+		var statusErr *StatusError
+		Expect(errors.As(err, &statusErr)).To(BeTrue())
+		Expect(otherErr).To(BeNil())
+	})
+
+	It("should give up on the latency when the context is done", func() {
+		// given
+		fake := NewFake()
+		fake.SetLatency(time.Hour)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// when
+		_, err := fake.GetFeatureStatus(ctx, "540155", []string{"Bundle1"})
+
+		// then
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	})
+
+	It("should give the org the feature of an activated trial", func() {
+		// given
+		fake := NewFake()
+
+		// when
+		err := fake.ActivateTrial(context.Background(), "540155", "Bundle1")
+		status, _ := fake.GetFeatureStatus(context.Background(), "540155", []string{"Bundle1"})
+
+		// then
+		Expect(err).To(BeNil())
+		Expect(status.Features).To(Equal([]types.Feature{{Name: "Bundle1"}}))
+	})
+})
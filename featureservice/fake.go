@@ -0,0 +1,134 @@
+package featureservice
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/types"
+)
+
+// Call is a request a Fake received
+type Call struct {
+	OrgID string
+	// Features are the features asked for by GetFeatureStatus, or the feature of a trial
+	Features []string
+	Trial    bool
+}
+
+// Fake is an in-memory Feature Service. It answers with the features set for each org, orgs without
+// features have none. It is safe for concurrent use.
+type Fake struct {
+	mu          sync.Mutex
+	features    map[string][]types.Feature
+	errors      map[string]error
+	trialErrors map[string]error
+	latency     time.Duration
+	calls       []Call
+}
+
+var _ FeatureService = &Fake{}
+
+func NewFake() *Fake {
+	return &Fake{
+		features:    map[string][]types.Feature{},
+		errors:      map[string]error{},
+		trialErrors: map[string]error{},
+	}
+}
+
+// SetFeatures replaces the features of an org
+func (f *Fake) SetFeatures(orgID string, features ...types.Feature) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.features[orgID] = features
+}
+
+// SetError makes GetFeatureStatus fail with err for an org, nil clears it
+func (f *Fake) SetError(orgID string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[orgID] = err
+}
+
+// SetTrialError makes ActivateTrial fail with err for an org, nil clears it
+func (f *Fake) SetTrialError(orgID string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.trialErrors[orgID] = err
+}
+
+// SetLatency delays every answer by d, or until the context of the call is done
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// Calls returns the requests received so far, in the order they were received
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// wait records a call and sleeps for the configured latency
+func (f *Fake) wait(ctx context.Context, call Call) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	latency := f.latency
+	f.mu.Unlock()
+
+	if latency <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return &TransportError{URL: "fake", Err: ctx.Err()}
+	}
+}
+
+// GetFeatureStatus returns the org's features that were asked for, as the Feature Service does
+func (f *Fake) GetFeatureStatus(ctx context.Context, orgID string, features []string) (types.FeatureStatus, error) {
+	if err := f.wait(ctx, Call{OrgID: orgID, Features: slices.Clone(features)}); err != nil {
+		return types.FeatureStatus{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errors[orgID]; err != nil {
+		return types.FeatureStatus{}, err
+	}
+
+	status := types.FeatureStatus{Features: []types.Feature{}}
+	for _, feature := range f.features[orgID] {
+		if slices.Contains(features, feature.Name) {
+			status.Features = append(status.Features, feature)
+		}
+	}
+	return status, nil
+}
+
+// ActivateTrial gives the org the feature, unless it already has it
+func (f *Fake) ActivateTrial(ctx context.Context, orgID string, feature string) error {
+	if err := f.wait(ctx, Call{OrgID: orgID, Features: []string{feature}, Trial: true}); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.trialErrors[orgID]; err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(f.features[orgID], func(existing types.Feature) bool { return existing.Name == feature }) {
+		f.features[orgID] = append(f.features[orgID], types.Feature{Name: feature})
+	}
+	return nil
+}
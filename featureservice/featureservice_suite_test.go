@@ -0,0 +1,15 @@
+package featureservice
+
+import (
+	"testing"
+
+	. "github.com/RedHatInsights/entitlements-api-go/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFeatureService(t *testing.T) {
+	InitLogger()
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Feature Service Suite")
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
 	"time"
 
@@ -35,11 +37,53 @@ func main() {
 	}
 
 	// init config here
-	if err := controllers.SetBundleInfo(config.GetConfig().Options.GetString(config.Keys.BundleInfoYaml)); err != nil {
+	options := config.GetConfig().Options
+
+	// bundles are compiled against the internal policies, so they are loaded first
+	internalPoliciesYaml := options.GetString(config.Keys.InternalPoliciesYaml)
+	if err := controllers.SetInternalPolicies(internalPoliciesYaml); errors.Is(err, os.ErrNotExist) {
+		logger.Log.WithFields(logrus.Fields{"path": internalPoliciesYaml}).Info("No internal policies config found, the default internal policy allows @redhat.com users")
+	} else if err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Fatal("Error reading internal policies config")
+	}
+
+	bundleInfoYaml := options.GetString(config.Keys.BundleInfoYaml)
+	if err := controllers.SetBundleInfo(bundleInfoYaml); err != nil {
 		sentry.CaptureException(err)
 		logger.Log.WithFields(logrus.Fields{"error": err}).Fatal("Error reading bundles.yml")
 	}
 
+	overridesYaml := options.GetString(config.Keys.OverridesYaml)
+	if err := controllers.SetOverrides(overridesYaml); errors.Is(err, os.ErrNotExist) {
+		logger.Log.WithFields(logrus.Fields{"path": overridesYaml}).Info("No overrides config found, no overrides will be applied")
+	} else if err != nil {
+		logger.Log.WithFields(logrus.Fields{"error": err}).Fatal("Error reading overrides config")
+	}
+
+	if options.GetBool(config.Keys.BundleInfoWatch) {
+		if err := controllers.WatchBundleInfo(context.Background(), bundleInfoYaml); err != nil {
+			sentry.CaptureException(err)
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Error watching bundles.yml, changes will not be reloaded")
+		}
+		if err := controllers.WatchOverrides(context.Background(), overridesYaml); err != nil {
+			sentry.CaptureException(err)
+			logger.Log.WithFields(logrus.Fields{"error": err}).Error("Error watching overrides config, changes will not be reloaded")
+		}
+	}
+
+	if options.GetString(config.Keys.EventsKafkaBrokers) != "" {
+		publisher, err := controllers.NewKafkaEventPublisher()
+		if err != nil {
+			sentry.CaptureException(err)
+			logger.Log.WithFields(logrus.Fields{"error": err}).Fatal("Error configuring entitlement change events")
+		}
+		controllers.SetEventPublisher(publisher)
+		defer publisher.Close()
+		logger.Log.WithFields(logrus.Fields{"topic": options.GetString(config.Keys.EventsTopic)}).Info("Publishing entitlement change events")
+	} else {
+		logger.Log.Info("ENT_EVENTS_KAFKA_BROKERS was not set, entitlement change events will not be published")
+	}
+
 	server.Launch()
 
 	// Flush buffered events before the program terminates.
@@ -6,11 +6,11 @@ import (
 
 	chilogger "github.com/766b/chi-logger"
 	"github.com/RedHatInsights/entitlements-api-go/ams"
-	"github.com/RedHatInsights/entitlements-api-go/api"
 	"github.com/RedHatInsights/entitlements-api-go/apispec"
 	"github.com/RedHatInsights/entitlements-api-go/bop"
 	"github.com/RedHatInsights/entitlements-api-go/config"
 	"github.com/RedHatInsights/entitlements-api-go/controllers"
+	"github.com/RedHatInsights/entitlements-api-go/featureservice"
 	log "github.com/RedHatInsights/entitlements-api-go/logger"
 	sentryhttp "github.com/getsentry/sentry-go/http"
 	"github.com/go-chi/chi/v5"
@@ -49,8 +49,10 @@ func DoRoutes() chi.Router {
 
 	// This is odd, but the generated code will register handlers
 	// and return a http.Handler.  This is normally used with .Mount,
-	// but since only part of the server is using code gen this is
-	// a way to hack it in
+	// but since the lubdub and openapi.json routes are not in
+	// the generated server this is a way to hack it in. The seats
+	// endpoints answer with a 404 when the seat manager is disabled.
+	var seatManagerApi controllers.SeatsServer
 	if !configOptions.GetBool(config.Keys.DisableSeatManager) {
 		debug := configOptions.GetBool(config.Keys.Debug)
 
@@ -64,15 +66,19 @@ func DoRoutes() chi.Router {
 			panic(fmt.Sprintf("Error constructing bop client: [%s]", err))
 		}
 
-		seatManagerApi := controllers.NewSeatManagerApi(amsClient, bopClient)
-		api.HandlerFromMuxWithBaseURL(seatManagerApi, r.With(enforceIdentity), "/api/entitlements/v1")
+		seatManagerApi = controllers.NewSeatManagerApi(amsClient, bopClient)
 	}
+	featureService := featureservice.NewClient()
+	apiServer := controllers.NewApiServer(
+		seatManagerApi,
+		controllers.NewServicesBatchApi(featureService),
+		controllers.NewServicesApi(featureService),
+	)
+	controllers.NewApiHandler(apiServer, r.With(enforceIdentity), "/api/entitlements/v1")
 
 	r.Route("/api/entitlements/v1", func(r chi.Router) {
 		r.With(enforceIdentity).Route("/", controllers.LubDub)
 		r.Route("/openapi.json", apispec.OpenAPISpec)
-		r.With(enforceIdentity).Get("/services", controllers.Services())
-		r.With(enforceIdentity).Get("/compliance", controllers.Compliance())
 	})
 
 	r.Route("/status", controllers.Status)
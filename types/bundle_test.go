@@ -1,6 +1,10 @@
 package types
 
 import (
+	"errors"
+
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -126,3 +130,189 @@ var _ = Describe("Bundle", func() {
 		})
 	})
 })
+
+var _ = Describe("Bundle config", func() {
+	Describe("ParseBundles", func() {
+		It("should parse a valid bundle config", func() {
+			bundles, err := ParseBundles([]byte(`
+- name: TestBundle1
+  skus: [SKU1]
+- name: TestBundle2
+  use_valid_org_id: true
+`))
+			Expect(err).To(BeNil())
+			Expect(bundles).To(HaveLen(2))
+			Expect(bundles[1].UseValidOrgId).To(BeTrue())
+		})
+
+		It("should reject unknown keys", func() {
+			_, err := ParseBundles([]byte(`
+- name: TestBundle1
+  use_valid_orgid: true
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("field use_valid_orgid not found"))
+		})
+
+		It("should reject a config that is not a list of bundles", func() {
+			_, err := ParseBundles([]byte(`foo: TestBundle1`))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should parse entitled_when rules", func() {
+			bundles, err := ParseBundles([]byte(`
+- name: TestBundle1
+  skus: [SKU1]
+  entitled_when:
+    any:
+      - is: internal
+      - feature: TestBundle1
+`))
+			Expect(err).To(BeNil())
+			Expect(bundles[0].EntitledWhen.Any).To(HaveLen(2))
+			Expect(bundles[0].EntitledWhen.Any[0].Is).To(Equal("internal"))
+		})
+
+		It("should reject unknown keys in entitled_when rules", func() {
+			_, err := ParseBundles([]byte(`
+- name: TestBundle1
+  entitled_when:
+    is_internal: true
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("field is_internal not found"))
+		})
+
+		It("should reject an empty config", func() {
+			_, err := ParseBundles([]byte(``))
+
+			var validationErr *BundleValidationError
+			Expect(err).To(BeAssignableToTypeOf(validationErr))
+			Expect(err.Error()).To(ContainSubstring("no bundles are defined"))
+		})
+	})
+
+	Describe("ValidateBundles", func() {
+		It("should accept valid entitled_when rules", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", Skus: []string{"SKU1"}, EntitledWhen: &evaluator.Rule{
+					Any: []evaluator.Rule{{Is: "internal"}, {Feature: "TestBundle1"}},
+				}},
+				{Name: "TestBundle2", EntitledWhen: &evaluator.Rule{IdentityType: []string{"ServiceAccount"}}},
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("should reject invalid entitled_when rules", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", EntitledWhen: &evaluator.Rule{All: []evaluator.Rule{{Is: "intrnal"}}}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`bundle #1 ("TestBundle1"): entitled_when.all[0]: unknown attribute "intrnal"`))
+		})
+
+		It("should reject entitled_when combined with use_* flags", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", UseValidAccNum: true, EntitledWhen: &evaluator.Rule{Is: "internal"}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("entitled_when cannot be combined with use_valid_acc_num"))
+		})
+
+		It("should reject entitled_when rules on features that are never requested", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", UseValidAccNum: true},
+				{Name: "TestBundle2", EntitledWhen: &evaluator.Rule{Any: []evaluator.Rule{{Feature: "TestBundle1"}, {Feature: "TestBundle3"}}}},
+			})
+			Expect(err).To(HaveOccurred())
+
+			var validationErr *BundleValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Problems).To(HaveLen(2))
+			Expect(validationErr.Problems[0]).To(ContainSubstring(`refers to feature "TestBundle1"`))
+			Expect(validationErr.Problems[1]).To(ContainSubstring(`refers to feature "TestBundle3"`))
+		})
+
+		It("should accept bundles that combine flags with skus", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", Skus: []string{"SKU1"}, UseValidAccNum: true},
+				{Name: "TestBundle2", PaidSkus: []string{"PAID1"}, EvalSkus: []string{"EVAL1"}, UseValidOrgId: true},
+				{Name: "TestBundle3", UseIsInternal: true},
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("should reject duplicate bundle names", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1"},
+				{Name: "TestBundle1", UseValidAccNum: true},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`bundle #2 ("TestBundle1"): name is already used by bundle #1`))
+		})
+
+		It("should reject missing and padded names", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: ""},
+				{Name: " TestBundle1"},
+			})
+
+			var validationErr *BundleValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Problems).To(HaveExactElements(
+				"bundle #1: name is required",
+				`bundle #2 (" TestBundle1"): name has leading or trailing whitespace`,
+			))
+		})
+
+		It("should reject a sku listed in both eval_skus and paid_skus", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", EvalSkus: []string{"SKU1", "SKU2"}, PaidSkus: []string{"SKU2"}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`sku "SKU2" is listed in both eval_skus and paid_skus`))
+		})
+
+		It("should reject use_is_internal combined with skus", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", UseIsInternal: true, Skus: []string{"SKU1"}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("use_is_internal cannot be combined with skus"))
+		})
+
+		It("should reject internal_policy without use_is_internal", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", InternalPolicy: "partners"},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("internal_policy is only used with use_is_internal"))
+		})
+
+		It("should reject unknown identity types and disallowed results", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", IdentityTypes: []string{"User", "Robot"}, DisallowedIdentityResult: "hide"},
+				{Name: "TestBundle2", DisallowedIdentityResult: IdentityResultOmit},
+			})
+
+			var validationErr *BundleValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Problems).To(ConsistOf(
+				ContainSubstring(`identity_types has unknown identity type "Robot"`),
+				ContainSubstring(`disallowed_identity_result must be not_entitled, entitled or omit, got "hide"`),
+				ContainSubstring("disallowed_identity_result is only used with identity_types"),
+			))
+		})
+
+		It("should report every problem at once", func() {
+			err := ValidateBundles([]Bundle{
+				{Name: "TestBundle1", UseIsInternal: true, PaidSkus: []string{"SKU1"}, EvalSkus: []string{"SKU1"}},
+				{Name: "TestBundle1"},
+			})
+
+			var validationErr *BundleValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Problems).To(HaveLen(3))
+		})
+	})
+})
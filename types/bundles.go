@@ -0,0 +1,157 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleValidationError lists every problem found in a bundle config so they can all be fixed at once
+type BundleValidationError struct {
+	Problems []string
+}
+
+func (e *BundleValidationError) Error() string {
+	return fmt.Sprintf("invalid bundle config: %s", strings.Join(e.Problems, "; "))
+}
+
+// ParseBundles strictly decodes a bundle config and validates it.
+// Unknown keys are rejected so that typos such as `use_valid_orgid` are not silently ignored.
+func ParseBundles(data []byte) ([]Bundle, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var bundles []Bundle
+	if err := decoder.Decode(&bundles); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid bundle config: %w", err)
+	}
+
+	if err := ValidateBundles(bundles); err != nil {
+		return nil, err
+	}
+
+	return bundles, nil
+}
+
+// ValidateBundles checks a decoded bundle config for settings that yaml accepts but that the service
+// would silently misinterpret. It returns a *BundleValidationError listing every problem found.
+func ValidateBundles(bundles []Bundle) error {
+	var problems []string
+
+	if len(bundles) == 0 {
+		problems = append(problems, "no bundles are defined")
+	}
+
+	// features are only requested from the Feature Service for SKU based bundles
+	skuBased := make(map[string]bool)
+	for _, bundle := range bundles {
+		if bundle.IsSkuBased() {
+			skuBased[bundle.Name] = true
+		}
+	}
+
+	seen := make(map[string]int)
+	for i, bundle := range bundles {
+		name := strings.TrimSpace(bundle.Name)
+		label := fmt.Sprintf("bundle #%d (%q)", i+1, bundle.Name)
+
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("bundle #%d: name is required", i+1))
+		} else if name != bundle.Name {
+			problems = append(problems, fmt.Sprintf("%s: name has leading or trailing whitespace", label))
+		}
+
+		if first, exists := seen[name]; exists && name != "" {
+			problems = append(problems, fmt.Sprintf("%s: name is already used by bundle #%d", label, first))
+		} else {
+			seen[name] = i + 1
+		}
+
+		for _, sku := range bundle.EvalSkus {
+			for _, paidSku := range bundle.PaidSkus {
+				if sku == paidSku {
+					problems = append(problems, fmt.Sprintf("%s: sku %q is listed in both eval_skus and paid_skus", label, sku))
+				}
+			}
+		}
+
+		if bundle.UseIsInternal && bundle.IsSkuBased() {
+			problems = append(problems, fmt.Sprintf("%s: use_is_internal cannot be combined with skus, eval_skus or paid_skus", label))
+		}
+
+		if bundle.InternalPolicy != "" && !bundle.UseIsInternal {
+			problems = append(problems, fmt.Sprintf("%s: internal_policy is only used with use_is_internal", label))
+		}
+
+		problems = append(problems, validateIdentityTypes(label, bundle)...)
+
+		if bundle.EntitledWhen != nil {
+			problems = append(problems, validateEntitledWhen(label, bundle, skuBased)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &BundleValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// validateEntitledWhen checks that a bundle's rule tree compiles and that it only refers to features
+// the Feature Service is asked for
+func validateEntitledWhen(label string, bundle Bundle, skuBased map[string]bool) []string {
+	var problems []string
+
+	if bundle.UseValidAccNum || bundle.UseValidOrgId || bundle.UseIsInternal {
+		problems = append(problems, fmt.Sprintf("%s: entitled_when cannot be combined with use_valid_acc_num, use_valid_org_id or use_is_internal, add them to the rule instead", label))
+	}
+
+	// the internal policies of the environment are checked when the bundles are loaded
+	if _, err := bundle.Condition(nil); err != nil {
+		var ruleErr *evaluator.RuleError
+		if !errors.As(err, &ruleErr) {
+			return append(problems, fmt.Sprintf("%s: %s", label, err))
+		}
+		for _, problem := range ruleErr.Problems {
+			problems = append(problems, fmt.Sprintf("%s: %s", label, problem))
+		}
+	}
+
+	for _, feature := range bundle.EntitledWhen.Features() {
+		if !skuBased[feature] {
+			problems = append(problems, fmt.Sprintf("%s: entitled_when refers to feature %q, which is not the name of a bundle with skus, eval_skus or paid_skus", label, feature))
+		}
+	}
+
+	return problems
+}
+
+// validateIdentityTypes checks that identity_types only lists known identity types and that
+// disallowed_identity_result is only set alongside it
+func validateIdentityTypes(label string, bundle Bundle) []string {
+	var problems []string
+
+	for _, identityType := range bundle.IdentityTypes {
+		if !slices.Contains(evaluator.IdentityTypes, identityType) {
+			problems = append(problems, fmt.Sprintf("%s: identity_types has unknown identity type %q, expected one of %s", label, identityType, strings.Join(evaluator.IdentityTypes, ", ")))
+		}
+	}
+
+	switch bundle.DisallowedIdentityResult {
+	case "", IdentityResultNotEntitled, IdentityResultEntitled, IdentityResultOmit:
+	default:
+		problems = append(problems, fmt.Sprintf("%s: disallowed_identity_result must be %s, %s or %s, got %q", label, IdentityResultNotEntitled, IdentityResultEntitled, IdentityResultOmit, bundle.DisallowedIdentityResult))
+	}
+	if bundle.DisallowedIdentityResult != "" && len(bundle.IdentityTypes) == 0 {
+		problems = append(problems, fmt.Sprintf("%s: disallowed_identity_result is only used with identity_types", label))
+	}
+
+	return problems
+}
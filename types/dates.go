@@ -0,0 +1,62 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FeatureDate is a date as the Feature Service sends it. It is kept as the original string so
+// cached feature status round trips unchanged, use Time to parse it.
+type FeatureDate string
+
+// iso8601Layouts are the ISO 8601 variants we accept, tried in order. time.RFC3339 alone rejects
+// offsets without a colon (+0000), hour only offsets (+00), missing offsets and dates without a time.
+// Fractional seconds are accepted by every layout with seconds, see time.Parse.
+var iso8601Layouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05Z07",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"20060102T150405Z0700",
+	"20060102T150405",
+	"20060102",
+}
+
+// Time parses the date, see ParseISO8601. The zero time and false are returned for an empty date.
+func (d FeatureDate) Time() (time.Time, bool, error) {
+	if strings.TrimSpace(string(d)) == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := ParseISO8601(string(d))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// ParseISO8601 parses the ISO 8601 date and time representations the Feature Service is known to
+// send. Values without an offset are taken to be UTC, as are dates without a time.
+func ParseISO8601(value string) (time.Time, error) {
+	normalized := strings.TrimSpace(value)
+	// ISO 8601 allows a lowercase designator, a space between the date and time when both sides
+	// agree on it, and a comma before fractional seconds, time.Parse allows none of them
+	if len(normalized) > 10 && (normalized[10] == ' ' || normalized[10] == 't') {
+		normalized = normalized[:10] + "T" + normalized[11:]
+	}
+	if strings.HasSuffix(normalized, "z") {
+		normalized = strings.TrimSuffix(normalized, "z") + "Z"
+	}
+	normalized = strings.Replace(normalized, ",", ".", 1)
+
+	for _, layout := range iso8601Layouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not an ISO 8601 date", value)
+}
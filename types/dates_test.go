@@ -0,0 +1,60 @@
+package types
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseISO8601", func() {
+	DescribeTable("should parse the ISO 8601 variants the Feature Service sends",
+		func(value string, expected time.Time) {
+			parsed, err := ParseISO8601(value)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(BeTemporally("==", expected))
+		},
+		Entry("RFC 3339", "2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("fractional seconds", "2024-01-02T03:04:05.123Z", time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)),
+		Entry("offset without a colon", "2024-01-02T03:04:05.000+0000", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("hour only offset", "2024-01-02T05:04:05+02", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("offset with a colon", "2024-01-01T22:04:05-05:00", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("no offset", "2024-01-02T03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("no seconds", "2024-01-02T03:04Z", time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)),
+		Entry("date only", "2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+		Entry("space separator", "2024-01-02 03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("lowercase designators", "2024-01-02t03:04:05z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("comma before fractional seconds", "2024-01-02T03:04:05,5Z", time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC)),
+		Entry("basic format", "20240102T030405Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Entry("basic format date", "20240102", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+		Entry("surrounding whitespace", " 2024-01-02T03:04:05Z\n", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	)
+
+	DescribeTable("should reject values that are not dates",
+		func(value string) {
+			_, err := ParseISO8601(value)
+
+			Expect(err).To(MatchError(ContainSubstring("is not an ISO 8601 date")))
+		},
+		Entry("text", "tomorrow"),
+		Entry("invalid month", "2024-13-01"),
+		Entry("empty", ""),
+	)
+})
+
+var _ = Describe("FeatureDate", func() {
+	It("should report an empty date as missing", func() {
+		_, ok, err := FeatureDate("").Time()
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should return an error for a date it can't parse", func() {
+		_, ok, err := FeatureDate("2024-02-30").Time()
+
+		Expect(err).To(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InternalPolicyValidationError lists every problem found in an internal policies file so they can all be fixed at once
+type InternalPolicyValidationError struct {
+	Problems []string
+}
+
+func (e *InternalPolicyValidationError) Error() string {
+	return fmt.Sprintf("invalid internal policies config: %s", strings.Join(e.Problems, "; "))
+}
+
+// InternalPolicy decides who use_is_internal bundles treat as an internal user in an environment
+type InternalPolicy struct {
+	Name string `yaml:"name"`
+	// EmailDomains lists the domains an internal user's email has to be in, e.g. redhat.com
+	EmailDomains []string `yaml:"email_domains"`
+	// OrgIDs optionally restricts internal users to these orgs, every org is allowed when empty
+	OrgIDs []string `yaml:"org_ids"`
+}
+
+// ParseInternalPolicies strictly decodes an internal policies file and validates it. An empty file has no policies.
+func ParseInternalPolicies(data []byte) ([]InternalPolicy, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var policies []InternalPolicy
+	if err := decoder.Decode(&policies); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid internal policies config: %w", err)
+	}
+
+	if err := ValidateInternalPolicies(policies); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// ValidateInternalPolicies checks decoded internal policies for missing or duplicate names and blank
+// domains or orgs. It returns an *InternalPolicyValidationError listing every problem found.
+func ValidateInternalPolicies(policies []InternalPolicy) error {
+	var problems []string
+
+	seen := make(map[string]int)
+	for i, policy := range policies {
+		name := strings.TrimSpace(policy.Name)
+		label := fmt.Sprintf("internal policy #%d (%q)", i+1, policy.Name)
+
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("internal policy #%d: name is required", i+1))
+		} else if name != policy.Name {
+			problems = append(problems, fmt.Sprintf("%s: name has leading or trailing whitespace", label))
+		}
+
+		if first, exists := seen[name]; exists && name != "" {
+			problems = append(problems, fmt.Sprintf("%s: name is already used by internal policy #%d", label, first))
+		} else {
+			seen[name] = i + 1
+		}
+
+		if len(policy.EmailDomains) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: email_domains is required", label))
+		}
+		for _, domain := range policy.EmailDomains {
+			trimmed := strings.TrimPrefix(domain, "@")
+			if trimmed == "" || strings.TrimSpace(trimmed) != trimmed {
+				problems = append(problems, fmt.Sprintf("%s: email domain %q is blank or has whitespace", label, domain))
+			}
+		}
+		for _, orgID := range policy.OrgIDs {
+			if orgID == "" || strings.TrimSpace(orgID) != orgID {
+				problems = append(problems, fmt.Sprintf("%s: org id %q is blank or has whitespace", label, orgID))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &InternalPolicyValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// Compile builds the evaluator's policy, which matches email domains case insensitively
+func (p InternalPolicy) Compile() *evaluator.InternalPolicy {
+	return evaluator.NewInternalPolicy(p.Name, p.EmailDomains, p.OrgIDs)
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Internal policies config", func() {
+	Describe("ParseInternalPolicies", func() {
+		It("should parse internal policies", func() {
+			// given
+			data := []byte(`
+- name: default
+  email_domains: [redhat.com]
+- name: partners
+  email_domains: ["@example.com", redhat.com]
+  org_ids: ["12345"]
+`)
+
+			// when
+			policies, err := ParseInternalPolicies(data)
+
+			// then
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policies).To(HaveLen(2))
+			Expect(policies[1].OrgIDs).To(HaveExactElements("12345"))
+			Expect(policies[1].Compile().EmailAllowed("someone@Example.com")).To(BeTrue())
+		})
+
+		It("should accept an empty file", func() {
+			policies, err := ParseInternalPolicies([]byte(""))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policies).To(BeEmpty())
+		})
+
+		It("should reject unknown keys", func() {
+			_, err := ParseInternalPolicies([]byte("- name: default\n  email_domain: [redhat.com]\n"))
+
+			Expect(err).To(MatchError(ContainSubstring("field email_domain not found")))
+		})
+	})
+
+	Describe("ValidateInternalPolicies", func() {
+		It("should list every problem", func() {
+			// given
+			policies := []InternalPolicy{
+				{Name: "default", EmailDomains: []string{"redhat.com"}},
+				{Name: "default", EmailDomains: []string{" redhat.com"}},
+				{OrgIDs: []string{""}},
+			}
+
+			// when
+			err := ValidateInternalPolicies(policies)
+
+			// then
+			var validationErr *InternalPolicyValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Problems).To(ConsistOf(
+				ContainSubstring("name is already used by internal policy #1"),
+				ContainSubstring(`email domain " redhat.com" is blank or has whitespace`),
+				ContainSubstring("internal policy #3: name is required"),
+				ContainSubstring("email_domains is required"),
+				ContainSubstring(`org id "" is blank or has whitespace`),
+			))
+		})
+	})
+})
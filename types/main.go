@@ -1,11 +1,64 @@
 package types
 
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/RedHatInsights/entitlements-api-go/evaluator"
+)
+
 // EntitlementsSection is a struct representing { "is_entitled": bool, "is_trial": bool } on the SubscriptionsResponse
 type EntitlementsSection struct {
 	IsEntitled bool `json:"is_entitled"`
 	IsTrial    bool `json:"is_trial"`
+	// StartsAt and ExpiresAt are the dates of the subscription or trial the bundle is entitled by,
+	// when the Feature Service returned them
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TrialDaysRemaining is the number of days, rounded up, until a trial expires
+	TrialDaysRemaining *int `json:"trial_days_remaining,omitempty"`
+	// Explanation is only set when the caller asked for it with explain=true
+	Explanation *BundleExplanation `json:"explanation,omitempty"`
+}
+
+// BundleExplanation describes how the entitlement of a bundle was decided
+type BundleExplanation struct {
+	// Rules lists every rule that was evaluated for the bundle, in evaluation order
+	Rules []RuleEvaluation `json:"rules"`
+	// DecidedBy is the rule that determined is_entitled
+	DecidedBy string `json:"decided_by"`
+	// Source is where the feature data the rules were evaluated against came from
+	Source string `json:"source"`
+	// Override is set when an override decided the bundle instead of its rules
+	Override *OverrideExplanation `json:"override,omitempty"`
+}
+
+// OverrideExplanation describes the override that decided a bundle
+type OverrideExplanation struct {
+	Action    string     `json:"action"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
+// RuleEvaluation is the outcome of a single bundle rule and the inputs it was evaluated against
+type RuleEvaluation struct {
+	Rule   string         `json:"rule"`
+	Inputs map[string]any `json:"inputs,omitempty"`
+	Result bool           `json:"result"`
+}
+
+// Outcomes of a Feature Service lookup, cached along with its result
+const (
+	FeatureOutcomeSuccess = "success"
+	// FeatureOutcomeUpstreamError is a lookup that got no response, e.g. a timeout or an open circuit breaker
+	FeatureOutcomeUpstreamError = "upstream_error"
+	FeatureOutcomeNon200        = "non_200"
+	// FeatureOutcomeInvalidResponse is a lookup answered with a 200 that could not be decoded, or that
+	// held features that were not requested
+	FeatureOutcomeInvalidResponse = "invalid_response"
+)
+
 // FeatureResponse is a struct that is used to unmarshal the data that comes back from the
 // Feature Service
 type FeatureResponse struct {
@@ -14,24 +67,44 @@ type FeatureResponse struct {
 	Error      error
 	Data       FeatureStatus
 	CacheHit   bool
-	Url        string
+	// Stale is true when Data is the last known good result for the org, served because the
+	// Feature Service could not be reached or did not return a 200
+	Stale bool
+	Url   string
+	// Outcome is the outcome of the lookup that produced Data, one of the FeatureOutcome constants
+	Outcome string
+	// ExpiresAt is when the org's cached result expires, zero when the result was not cached
+	ExpiresAt time.Time
 }
 
 // Feature represents a feature as it exists in feature service
-// note: StartDate and EndDate are strings instead of time.Time since we don't use these fields
-// if that changes in the future, we should implement proper encoding and decoding
-// json encoder requires RFC3339 time spec, feature service uses ISO 8601 which should be compatible but there are some discrepancies
-// see here for more info: https://en.wikipedia.org/wiki/ISO_8601#Implementations, https://cs.opensource.google/go/go/+/go1.25.6:src/time/time.go;l=1600
+// StartDate and EndDate are ISO 8601, which time.Time can't always decode as it requires RFC 3339,
+// see FeatureDate and ParseISO8601 for the variants we accept.
 type Feature struct {
-	Name       	string	`json:"name"`
-	StartDate	string	`json:"startDate"`
-	EndDate		string	`json:"endDate"`
+	Name      string      `json:"name"`
+	StartDate FeatureDate `json:"startDate"`
+	EndDate   FeatureDate `json:"endDate"`
+}
+
+// TrialActivationRequest is the body of a trial activation sent to the feature service. It has not been
+// confirmed against the Feature Service yet, so trial activation is behind ENT_TRIALS_ENABLED.
+type TrialActivationRequest struct {
+	AccountID string `json:"accountId"`
+	Feature   string `json:"feature"`
 }
 
 type FeatureStatus struct {
 	Features []Feature `json:"features"`
 }
 
+// What a bundle with identity_types answers requests made by other identity types
+const (
+	IdentityResultNotEntitled = "not_entitled"
+	IdentityResultEntitled    = "entitled"
+	// IdentityResultOmit leaves the bundle out of the response
+	IdentityResultOmit = "omit"
+)
+
 // Bundle is a struct that is used to unmarshal the bundle info from bundles.yml
 type Bundle struct {
 	Name           string   `yaml:"name"`
@@ -41,16 +114,69 @@ type Bundle struct {
 	Skus           []string `yaml:"skus"`
 	EvalSkus       []string `yaml:"eval_skus"`
 	PaidSkus       []string `yaml:"paid_skus"`
+	// InternalPolicy names the internal policy use_is_internal checks, evaluator.DefaultInternalPolicy if empty
+	InternalPolicy string `yaml:"internal_policy"`
+	// IdentityTypes limits the bundle to requests made by these identity types, it applies to every type if empty
+	IdentityTypes []string `yaml:"identity_types"`
+	// DisallowedIdentityResult is what the bundle answers other identity types, IdentityResultNotEntitled if empty
+	DisallowedIdentityResult string `yaml:"disallowed_identity_result"`
+	// EntitledWhen replaces the SKU and use_* checks with a rule tree, see the evaluator package
+	EntitledWhen *evaluator.Rule `yaml:"entitled_when"`
 }
 
 func (b *Bundle) IsPaid() bool {
 	return (b.PaidSkus != nil && len(b.PaidSkus) > 0) || (b.EvalSkus != nil && len(b.EvalSkus) > 0)
 }
 
+// InternalPolicyName returns the name of the internal policy use_is_internal checks
+func (b *Bundle) InternalPolicyName() string {
+	if b.InternalPolicy == "" {
+		return evaluator.DefaultInternalPolicy
+	}
+	return b.InternalPolicy
+}
+
+// AppliesTo reports whether the bundle's rules decide requests made by an identity type
+func (b *Bundle) AppliesTo(identityType string) bool {
+	return len(b.IdentityTypes) == 0 || slices.Contains(b.IdentityTypes, identityType)
+}
+
+// IdentityResult returns what the bundle answers requests made by identity types it does not apply to
+func (b *Bundle) IdentityResult() string {
+	if b.DisallowedIdentityResult == "" {
+		return IdentityResultNotEntitled
+	}
+	return b.DisallowedIdentityResult
+}
+
 func (b *Bundle) IsSkuBased() bool {
 	return (b.Skus != nil && len(b.Skus) > 0) || b.IsPaid()
 }
 
+// Condition compiles the rules that decide whether a request is entitled to the bundle, with the
+// internal policies it refers to taken from policies, see evaluator.Compile.
+// Bundles without entitled_when get the condition described by their SKUs and use_* flags.
+func (b *Bundle) Condition(policies evaluator.InternalPolicies) (evaluator.Condition, error) {
+	if b.EntitledWhen != nil {
+		return evaluator.Compile("entitled_when", *b.EntitledWhen, policies)
+	}
+
+	var internal *evaluator.InternalPolicy
+	if b.UseIsInternal {
+		policy, ok := policies.Lookup(b.InternalPolicyName())
+		if !ok {
+			return evaluator.Condition{}, fmt.Errorf("unknown internal policy %q", b.InternalPolicyName())
+		}
+		internal = policy
+	}
+
+	feature := ""
+	if b.IsSkuBased() {
+		feature = b.Name
+	}
+	return evaluator.Shorthand(feature, b.UseValidAccNum, b.UseValidOrgId, internal), nil
+}
+
 // DependencyErrorDetails is a struct that is used to marshal failure details
 // from failed requests to external services
 type DependencyErrorDetails struct {
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override actions
+const (
+	OverrideGrant = "grant"
+	OverrideDeny  = "deny"
+)
+
+// OverrideValidationError lists every problem found in an overrides file so they can all be fixed at once
+type OverrideValidationError struct {
+	Problems []string
+}
+
+func (e *OverrideValidationError) Error() string {
+	return fmt.Sprintf("invalid overrides config: %s", strings.Join(e.Problems, "; "))
+}
+
+// Override force-grants or force-denies a bundle for an org regardless of what its rules decide,
+// it is used for partner demos, internal test orgs and emergency grants.
+type Override struct {
+	OrgID  string `yaml:"org_id"`
+	Bundle string `yaml:"bundle"`
+	// Action is either OverrideGrant or OverrideDeny
+	Action string `yaml:"action"`
+	// Reason says why the override exists, e.g. a ticket, and is shown in explanations
+	Reason string `yaml:"reason"`
+	// ExpiresAt is when the override stops applying, it applies until it is removed when not set
+	ExpiresAt *time.Time `yaml:"expires_at"`
+}
+
+// Expired reports whether the override no longer applies at the given time
+func (o Override) Expired(at time.Time) bool {
+	return o.ExpiresAt != nil && !at.Before(*o.ExpiresAt)
+}
+
+// ParseOverrides strictly decodes an overrides file and validates it. An empty file has no overrides.
+func ParseOverrides(data []byte) ([]Override, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var overrides []Override
+	if err := decoder.Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid overrides config: %w", err)
+	}
+
+	if err := ValidateOverrides(overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// ValidateOverrides checks decoded overrides for missing fields, unknown actions and orgs that have
+// more than one override for the same bundle. It returns an *OverrideValidationError listing every problem found.
+func ValidateOverrides(overrides []Override) error {
+	var problems []string
+
+	seen := make(map[string]int)
+	for i, override := range overrides {
+		label := fmt.Sprintf("override #%d", i+1)
+
+		if strings.TrimSpace(override.OrgID) == "" {
+			problems = append(problems, fmt.Sprintf("%s: org_id is required", label))
+		}
+		if strings.TrimSpace(override.Bundle) == "" {
+			problems = append(problems, fmt.Sprintf("%s: bundle is required", label))
+		}
+		if override.Action != OverrideGrant && override.Action != OverrideDeny {
+			problems = append(problems, fmt.Sprintf("%s: action must be %s or %s, got %q", label, OverrideGrant, OverrideDeny, override.Action))
+		}
+		if strings.TrimSpace(override.Reason) == "" {
+			problems = append(problems, fmt.Sprintf("%s: reason is required", label))
+		}
+
+		key := override.OrgID + "/" + override.Bundle
+		if first, exists := seen[key]; exists {
+			problems = append(problems, fmt.Sprintf("%s: org %q already has an override for bundle %q in override #%d", label, override.OrgID, override.Bundle, first))
+		} else {
+			seen[key] = i + 1
+		}
+	}
+
+	if len(problems) > 0 {
+		return &OverrideValidationError{Problems: problems}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Overrides config", func() {
+	Describe("ParseOverrides", func() {
+		It("should parse overrides", func() {
+			// given
+			data := []byte(`
+- org_id: "12345"
+  bundle: ansible
+  action: grant
+  reason: Partner demo
+  expires_at: 2026-12-31T00:00:00Z
+- org_id: "67890"
+  bundle: insights
+  action: deny
+  reason: Emergency revoke
+`)
+
+			// when
+			overrides, err := ParseOverrides(data)
+
+			// then
+			Expect(err).ToNot(HaveOccurred())
+			Expect(overrides).To(HaveLen(2))
+			Expect(overrides[0].OrgID).To(Equal("12345"))
+			Expect(*overrides[0].ExpiresAt).To(BeTemporally("==", time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)))
+			Expect(overrides[1].Action).To(Equal(OverrideDeny))
+			Expect(overrides[1].ExpiresAt).To(BeNil())
+		})
+
+		It("should accept an empty file", func() {
+			overrides, err := ParseOverrides([]byte(""))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(overrides).To(BeEmpty())
+		})
+
+		It("should reject unknown keys", func() {
+			_, err := ParseOverrides([]byte("- org_id: \"1\"\n  bundle: ansible\n  action: grant\n  reason: demo\n  expires: 2026-01-01\n"))
+
+			Expect(err).To(MatchError(ContainSubstring("field expires not found")))
+		})
+	})
+
+	Describe("ValidateOverrides", func() {
+		It("should list every problem", func() {
+			// given
+			overrides := []Override{
+				{OrgID: "1", Bundle: "ansible", Action: OverrideGrant, Reason: "demo"},
+				{Action: "allow"},
+				{OrgID: "1", Bundle: "ansible", Action: OverrideDeny, Reason: "revoke"},
+			}
+
+			// when
+			err := ValidateOverrides(overrides)
+
+			// then
+			var validationErr *OverrideValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Problems).To(ConsistOf(
+				"override #2: org_id is required",
+				"override #2: bundle is required",
+				`override #2: action must be grant or deny, got "allow"`,
+				"override #2: reason is required",
+				`override #3: org "1" already has an override for bundle "ansible" in override #1`,
+			))
+		})
+	})
+
+	Describe("Expired", func() {
+		It("should not expire without an expiry", func() {
+			Expect(Override{}.Expired(time.Now())).To(BeFalse())
+		})
+
+		It("should expire once the expiry has passed", func() {
+			expiresAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			override := Override{ExpiresAt: &expiresAt}
+
+			Expect(override.Expired(expiresAt.Add(-time.Second))).To(BeFalse())
+			Expect(override.Expired(expiresAt)).To(BeTrue())
+		})
+	})
+})